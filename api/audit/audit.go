@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"eirevpn/api/logger"
+	"eirevpn/api/models"
+	"eirevpn/api/requestid"
+	"eirevpn/api/util/clientip"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redacted replaces the values of sensitive fields so the audit log
+// shows they changed without storing them
+const redacted = "[redacted]"
+
+// sensitive lists substrings of the field names which are redacted
+var sensitive = []string{"password", "secret", "token", "hash"}
+
+// ignored lists fields which change on every update so are not worth
+// recording
+var ignored = map[string]bool{"updatedAt": true}
+
+// Entry describes an action to record. Before and After are the target
+// before and after the action, either may be nil when the target was
+// created or deleted. ActorID defaults to the logged in user.
+type Entry struct {
+	ActorID    uint
+	Action     models.AuditAction
+	TargetType string
+	TargetID   uint
+	Before     interface{}
+	After      interface{}
+}
+
+// Record writes the entry to the audit log. Failing to record an action
+// is logged but does not fail the request.
+func Record(c *gin.Context, e Entry) {
+	if e.ActorID == 0 {
+		if userID, exists := c.Get("UserID"); exists {
+			e.ActorID, _ = userID.(uint)
+		}
+	}
+	if err := Write(e, clientip.Get(c)); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "audit.Record()",
+			Code:      "AUDITFAILED",
+			Extra: map[string]interface{}{
+				"ActorID":    e.ActorID,
+				"Action":     e.Action,
+				"TargetType": e.TargetType,
+				"TargetID":   e.TargetID,
+			},
+			Err: err.Error(),
+		})
+	}
+}
+
+// Write saves the entry to the audit log with the IP the action came
+// from. Record should be used in request handlers, Write is for actions
+// taken outside of a request such as from the command line.
+func Write(e Entry, ip string) error {
+	log := models.AuditLog{
+		ActorID:    e.ActorID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         ip,
+	}
+	changes, err := Diff(e.Before, e.After)
+	if err != nil {
+		return err
+	}
+	log.Changes = changes
+	return log.Create()
+}
+
+// Diff compares the JSON encodings of before and after, returning the
+// fields which differ
+func Diff(before, after interface{}) (models.AuditChanges, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := models.AuditChanges{}
+	for key, bv := range b {
+		if av, ok := a[key]; !ok || !reflect.DeepEqual(bv, av) {
+			changes[key] = models.AuditChange{Before: bv, After: av}
+		}
+	}
+	for key, av := range a {
+		if _, ok := b[key]; !ok {
+			changes[key] = models.AuditChange{After: av}
+		}
+	}
+	for key, change := range changes {
+		if ignored[key] {
+			delete(changes, key)
+			continue
+		}
+		if isSensitive(key) {
+			if change.Before != nil {
+				change.Before = redacted
+			}
+			if change.After != nil {
+				change.After = redacted
+			}
+			changes[key] = change
+		}
+	}
+	return changes, nil
+}
+
+func fields(v interface{}) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if v == nil {
+		return m, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitive {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
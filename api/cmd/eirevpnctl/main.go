@@ -0,0 +1,141 @@
+// Command eirevpnctl operates the service from the command line. It works
+// directly against the database in the config, so it can be used before
+// any admin exists and while the API is down.
+package main
+
+import (
+	"eirevpn/api/audit"
+	cfg "eirevpn/api/config"
+	"eirevpn/api/db"
+	"eirevpn/api/migrations"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// auditIP is recorded as the IP of actions taken with eirevpnctl
+const auditIP = "eirevpnctl"
+
+// command is a top level command and its subcommands
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"user":    {"create users and promote them to admin", userCommand},
+	"plan":    {"grant users a plan or extend it", planCommand},
+	"server":  {"add, disable and enable servers and rotate their credentials", serverCommand},
+	"stats":   {"print connection stats", statsCommand},
+	"migrate": {"apply, roll back or list schema migrations", migrateCommand},
+}
+
+func main() {
+	appPath, _ := os.Getwd()
+	defaultConfig := os.Getenv(cfg.EnvPrefix + "_CONFIG")
+	if defaultConfig == "" {
+		defaultConfig = appPath + "/config.yaml"
+	}
+	configPath := flag.String("config", defaultConfig, "path to the config file")
+	flag.Usage = usage
+	flag.Parse()
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	filename, _ := filepath.Abs(*configPath)
+	if err := cfg.Init(filename); err != nil {
+		fatal(err)
+	}
+	if err := db.Init(cfg.Load(), false); err != nil {
+		fatal(err)
+	}
+	// every command but migrate needs the schema to match the models
+	if flag.Arg(0) != "migrate" {
+		if err := migrations.Check(db.GetDB()); err != nil {
+			db.CloseDB()
+			fatal(err)
+		}
+	}
+
+	err := cmd.run(flag.Args()[1:])
+	db.CloseDB()
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage: eirevpnctl [--config path] <command> <subcommand> [flags]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "eirevpnctl:", err)
+	os.Exit(1)
+}
+
+// runSubcommand runs the subcommand named by the first argument, or
+// returns an error listing those available
+func runSubcommand(name string, args []string, subcommands map[string]func(args []string) error) error {
+	names := make([]string, 0, len(subcommands))
+	for sub := range subcommands {
+		names = append(names, sub)
+	}
+	sort.Strings(names)
+	if len(args) == 0 {
+		return fmt.Errorf("%s needs a subcommand: %s", name, strings.Join(names, ", "))
+	}
+	run, ok := subcommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown %s subcommand %q, expected one of: %s", name, args[0], strings.Join(names, ", "))
+	}
+	return run(args[1:])
+}
+
+// required returns an error naming the first flag which was not given or
+// was given an empty or zero value, since the models Find methods skip
+// those and would match any row
+func required(fs *flag.FlagSet, names ...string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		set[f.Name] = value != "" && value != "0"
+	})
+	for _, name := range names {
+		if !set[name] {
+			return fmt.Errorf("%s: -%s is required", fs.Name(), name)
+		}
+	}
+	return nil
+}
+
+// record writes the action to the audit log. The action has already been
+// taken, so failing to record it is reported without failing the command.
+func record(e audit.Entry) {
+	if err := audit.Write(e, auditIP); err != nil {
+		fmt.Fprintln(os.Stderr, "eirevpnctl: failed to record the action in the audit log:", err)
+	}
+}
+
+func migrateCommand(args []string) error {
+	return migrations.Command(db.GetDB(), args, os.Stdout)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"eirevpn/api/audit"
+	"eirevpn/api/models"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+func planCommand(args []string) error {
+	return runSubcommand("plan", args, map[string]func(args []string) error{
+		"grant":  planGrant,
+		"extend": planExtend,
+	})
+}
+
+// planGrant gives the user a plan starting now, replacing any plan they
+// already have
+func planGrant(args []string) error {
+	fs := flag.NewFlagSet("plan grant", flag.ExitOnError)
+	email := fs.String("email", "", "email address of the user")
+	planID := fs.Uint("plan", 0, "ID of the plan to grant")
+	days := fs.Int("days", 30, "number of days the plan lasts")
+	fs.Parse(args)
+	if err := required(fs, "email", "plan"); err != nil {
+		return err
+	}
+	if *days < 1 {
+		return fmt.Errorf("-days must be at least 1")
+	}
+
+	user, err := findUser(*email)
+	if err != nil {
+		return err
+	}
+	var plan models.Plan
+	plan.ID = *planID
+	if err := plan.Find(); err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return fmt.Errorf("no plan with the ID %d", *planID)
+		}
+		return err
+	}
+
+	now := time.Now()
+	userplan := models.UserPlan{
+		UserID:     user.ID,
+		PlanID:     plan.ID,
+		Active:     true,
+		StartDate:  now,
+		ExpiryDate: now.AddDate(0, 0, *days),
+	}
+	if err := userplan.Create(); err != nil {
+		return err
+	}
+	if err := userplan.RefreshQuota(now); err != nil {
+		return err
+	}
+
+	record(audit.Entry{
+		Action:     models.AuditActionUserPlanCreate,
+		TargetType: "userplan",
+		TargetID:   userplan.ID,
+		After:      userplan,
+	})
+
+	fmt.Printf("Granted %s the plan %s until %s\n", user.Email, plan.Name, userplan.ExpiryDate.Format(time.RFC3339))
+	return nil
+}
+
+// planExtend adds days to the users plan, counting from now if it has
+// already expired, and reactivates it
+func planExtend(args []string) error {
+	fs := flag.NewFlagSet("plan extend", flag.ExitOnError)
+	email := fs.String("email", "", "email address of the user")
+	days := fs.Int("days", 30, "number of days to add")
+	fs.Parse(args)
+	if err := required(fs, "email"); err != nil {
+		return err
+	}
+	if *days < 1 {
+		return fmt.Errorf("-days must be at least 1")
+	}
+
+	user, err := findUser(*email)
+	if err != nil {
+		return err
+	}
+	var userplan models.UserPlan
+	userplan.UserID = user.ID
+	if err := userplan.Find(); err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return fmt.Errorf("%s has no plan to extend, grant one instead", user.Email)
+		}
+		return err
+	}
+
+	before := userplan
+	from := userplan.ExpiryDate
+	if now := time.Now(); from.Before(now) {
+		from = now
+	}
+	userplan.ExpiryDate = from.AddDate(0, 0, *days)
+	userplan.Active = true
+	if err := userplan.Save(); err != nil {
+		return err
+	}
+
+	record(audit.Entry{
+		Action:     models.AuditActionUserPlanUpdate,
+		TargetType: "userplan",
+		TargetID:   userplan.ID,
+		Before:     before,
+		After:      userplan,
+	})
+
+	fmt.Printf("Extended the plan of %s until %s\n", user.Email, userplan.ExpiryDate.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"eirevpn/api/audit"
+	"eirevpn/api/models"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jinzhu/gorm"
+)
+
+func serverCommand(args []string) error {
+	return runSubcommand("server", args, map[string]func(args []string) error{
+		"list":               serverList,
+		"add":                serverAdd,
+		"disable":            func(args []string) error { return serverSetDisabled("server disable", args, true) },
+		"enable":             func(args []string) error { return serverSetDisabled("server enable", args, false) },
+		"rotate-credentials": serverRotateCredentials,
+	})
+}
+
+func serverList(args []string) error {
+	fs := flag.NewFlagSet("server list", flag.ExitOnError)
+	fs.Parse(args)
+
+	var servers models.AllServers
+	if err := servers.FindAll(); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCOUNTRY\tIP\tPORT\tHEALTHY\tDISABLED\tUSERS\tCONNECTIONS")
+	for _, s := range servers {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%t\t%t\t%d\t%d\n", s.ID, s.Country, s.IP, s.Port, !s.Unhealthy(), s.Disabled, s.Users, s.Connections)
+	}
+	return w.Flush()
+}
+
+func serverAdd(args []string) error {
+	fs := flag.NewFlagSet("server add", flag.ExitOnError)
+	country := fs.String("country", "", "country the server is in")
+	countryCode := fs.String("country-code", "", "ISO code of the country")
+	ip := fs.String("ip", "", "IP address of the server")
+	port := fs.Int("port", 0, "port of the HTTP proxy")
+	socksPort := fs.Int("socks-port", 0, "port of the SOCKS5 proxy")
+	apiPort := fs.Int("api-port", 0, "port of the proxy control API")
+	apiSecret := fs.String("api-secret", "", "secret of the proxy control API")
+	username := fs.String("username", "", "username for free connections")
+	password := fs.String("password", "", "password for free connections")
+	maxUsers := fs.Int("max-users", 0, "most users connected at once, 0 for no limit")
+	disabled := fs.Bool("disabled", false, "add the server disabled, so users cannot connect until it is enabled")
+	fs.Parse(args)
+	if err := required(fs, "country", "country-code", "ip", "port"); err != nil {
+		return err
+	}
+
+	server := models.Server{
+		Country:     *country,
+		CountryCode: *countryCode,
+		Type:        models.ServerTypeProxy,
+		IP:          *ip,
+		Port:        *port,
+		SocksPort:   *socksPort,
+		APIPort:     *apiPort,
+		APISecret:   *apiSecret,
+		Username:    *username,
+		Password:    *password,
+		MaxUsers:    *maxUsers,
+		Disabled:    *disabled,
+	}
+	if err := server.Create(); err != nil {
+		return err
+	}
+
+	record(audit.Entry{
+		Action:     models.AuditActionServerCreate,
+		TargetType: "server",
+		TargetID:   server.ID,
+		After:      server,
+	})
+
+	fmt.Printf("Added server %d %s %s\n", server.ID, server.Country, server.IP)
+	return nil
+}
+
+// serverSetDisabled takes the server out of service or puts it back.
+// Users already connected keep their connection until it closes.
+func serverSetDisabled(name string, args []string, disabled bool) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	id := fs.Uint("id", 0, "ID of the server")
+	fs.Parse(args)
+	if err := required(fs, "id"); err != nil {
+		return err
+	}
+
+	server, err := findServer(*id)
+	if err != nil {
+		return err
+	}
+	before := *server
+	server.Disabled = disabled
+	if err := server.Save(); err != nil {
+		return err
+	}
+
+	record(audit.Entry{
+		Action:     models.AuditActionServerUpdate,
+		TargetType: "server",
+		TargetID:   server.ID,
+		Before:     before,
+		After:      server,
+	})
+
+	state := "enabled"
+	if disabled {
+		state = "disabled"
+	}
+	fmt.Printf("Server %d %s is %s\n", server.ID, server.Country, state)
+	return nil
+}
+
+// serverRotateCredentials gives every user of the server a new proxy
+// username and password. Clients pick them up the next time they connect.
+func serverRotateCredentials(args []string) error {
+	fs := flag.NewFlagSet("server rotate-credentials", flag.ExitOnError)
+	id := fs.Uint("id", 0, "ID of the server")
+	fs.Parse(args)
+	if err := required(fs, "id"); err != nil {
+		return err
+	}
+
+	server, err := findServer(*id)
+	if err != nil {
+		return err
+	}
+	var creds models.AllProxyCredentials
+	if err := creds.FindByServer(server.ID); err != nil {
+		return err
+	}
+	rotated := 0
+	var failed []uint
+	for _, cred := range creds {
+		if err := cred.Rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "eirevpnctl: failed to rotate the credential of user %d: %v\n", cred.UserID, err)
+			failed = append(failed, cred.UserID)
+			continue
+		}
+		rotated++
+	}
+
+	record(audit.Entry{
+		Action:     models.AuditActionServerRotate,
+		TargetType: "server",
+		TargetID:   server.ID,
+	})
+
+	fmt.Printf("Rotated %d of %d credentials on server %d %s\n", rotated, len(creds), server.ID, server.Country)
+	if len(failed) > 0 {
+		return fmt.Errorf("could not rotate the credentials of users %v", failed)
+	}
+	return nil
+}
+
+func findServer(id uint) (*models.Server, error) {
+	// Find skips zero fields so an ID of 0 would match any server
+	if id == 0 {
+		return nil, fmt.Errorf("a server ID is required to find a server")
+	}
+	var server models.Server
+	server.ID = id
+	if err := server.Find(); err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, fmt.Errorf("no server with the ID %d", id)
+		}
+		return nil, err
+	}
+	return &server, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"eirevpn/api/handlers/server"
+	"eirevpn/api/models"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// statsCommand prints the connections made to each server over a period
+// and the number of devices active now
+func statsCommand(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	since := fs.Duration("since", 24*time.Hour, "how far back to count connections")
+	fs.Parse(args)
+
+	var stats models.AllConnectionStats
+	if err := stats.Find(time.Now().Add(-*since)); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tCOUNTRY\tCONNECTIONS\tUSERS\tUP\tDOWN")
+	var total models.ConnectionStats
+	for _, s := range stats {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\t%s\n", s.ServerID, s.ServerCountry, s.Connections, s.Users, formatBytes(s.BytesUp), formatBytes(s.BytesDown))
+		total.Connections += s.Connections
+		total.BytesUp += s.BytesUp
+		total.BytesDown += s.BytesDown
+	}
+	fmt.Fprintf(w, "total\t\t%d\t\t%s\t%s\n", total.Connections, formatBytes(total.BytesUp), formatBytes(total.BytesDown))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nConnections in the last %s, %d devices active now\n", *since, int(server.ActiveDevices()))
+	return nil
+}
+
+// formatBytes formats n in the largest unit under 1024
+func formatBytes(n int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	f := float64(n)
+	i := 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f %s", f, units[i])
+}
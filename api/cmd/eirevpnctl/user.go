@@ -0,0 +1,135 @@
+package main
+
+import (
+	"eirevpn/api/audit"
+	"eirevpn/api/models"
+	"eirevpn/api/permissions"
+	"eirevpn/api/util/random"
+	"flag"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+func userCommand(args []string) error {
+	return runSubcommand("user", args, map[string]func(args []string) error{
+		"create":  userCreate,
+		"promote": userPromote,
+	})
+}
+
+// userCreate creates a user with a confirmed email, optionally as an admin.
+// A password is generated and printed when none is given.
+func userCreate(args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ExitOnError)
+	email := fs.String("email", "", "email address of the user")
+	password := fs.String("password", "", "password of the user, generated when empty")
+	firstName := fs.String("firstname", "", "first name of the user")
+	lastName := fs.String("lastname", "", "last name of the user")
+	role := fs.String("role", "", "make the user an admin with this role: superadmin, support or billing")
+	fs.Parse(args)
+	if err := required(fs, "email"); err != nil {
+		return err
+	}
+	if *role != "" && !permissions.ValidRole(models.UserRole(*role)) {
+		return fmt.Errorf("invalid role %q", *role)
+	}
+
+	var existing models.User
+	existing.Email = *email
+	if err := existing.Find(); err == nil {
+		return fmt.Errorf("a user with the email %s already exists", *email)
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	generated := *password == ""
+	if generated {
+		pw, err := random.GenerateRandomString(12)
+		if err != nil {
+			return err
+		}
+		*password = pw
+	}
+
+	user := models.User{
+		FirstName:      *firstName,
+		LastName:       *lastName,
+		Email:          *email,
+		Password:       *password,
+		Type:           models.UserTypeNormal,
+		EmailConfirmed: true,
+	}
+	if *role != "" {
+		user.Type = models.UserTypeAdmin
+		user.Role = models.UserRole(*role)
+	}
+	if err := user.Create(); err != nil {
+		return err
+	}
+
+	record(audit.Entry{
+		Action:     models.AuditActionUserCreate,
+		TargetType: "user",
+		TargetID:   user.ID,
+		After:      user,
+	})
+
+	fmt.Printf("Created user %d %s\n", user.ID, user.Email)
+	if generated {
+		fmt.Printf("Password: %s\n", *password)
+	}
+	return nil
+}
+
+// userPromote gives an existing user an admin role
+func userPromote(args []string) error {
+	fs := flag.NewFlagSet("user promote", flag.ExitOnError)
+	email := fs.String("email", "", "email address of the user")
+	role := fs.String("role", string(models.UserRoleSuperAdmin), "role to give the user: superadmin, support or billing")
+	fs.Parse(args)
+	if err := required(fs, "email"); err != nil {
+		return err
+	}
+	if !permissions.ValidRole(models.UserRole(*role)) {
+		return fmt.Errorf("invalid role %q", *role)
+	}
+
+	user, err := findUser(*email)
+	if err != nil {
+		return err
+	}
+	before := *user
+	user.Type = models.UserTypeAdmin
+	user.Role = models.UserRole(*role)
+	if err := user.Save(); err != nil {
+		return err
+	}
+
+	record(audit.Entry{
+		Action:     models.AuditActionUserRole,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Before:     before,
+		After:      user,
+	})
+
+	fmt.Printf("User %d %s is now a %s admin\n", user.ID, user.Email, user.Role)
+	return nil
+}
+
+func findUser(email string) (*models.User, error) {
+	// Find skips empty fields so an empty email would match any user
+	if email == "" {
+		return nil, fmt.Errorf("an email is required to find a user")
+	}
+	var user models.User
+	user.Email = email
+	if err := user.Find(); err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, fmt.Errorf("no user with the email %s", email)
+		}
+		return nil, err
+	}
+	return &user, nil
+}
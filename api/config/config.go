@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"reflect"
 
 	"gopkg.in/yaml.v2"
 )
@@ -11,28 +13,69 @@ var configFilename string
 
 type Config struct {
 	App struct {
-		Port                string   `yaml:"Port"`
-		Domain              string   `yaml:"Domain"`
-		JWTSecret           string   `yaml:"JWTSecret"`
-		AllowedOrigins      []string `yaml:"AllowedOrigins"`
-		EnableCSRF          bool     `yaml:"EnableCSRF"`
-		EnableSubscriptions bool     `yaml:"EnableSubscriptions"`
-		EnableAuth          bool     `yaml:"EnableAuth"`
-		AuthCookieAge       int      `yaml:"AuthCookieAge"`
-		RefreshCookieAge    int      `yaml:"RefreshCookieAge"`
-		AuthCookieName      string   `yaml:"AuthCookieName"`
-		RefreshCookieName   string   `yaml:"RefreshCookieName"`
-		AuthTokenExpiry     int      `yaml:"AuthTokenExpiry"`
-		RefreshTokenExpiry  int      `yaml:"RefreshTokenExpiry"`
-		TestMode            bool     `yaml:"TestMode"`
+		Port                   string   `yaml:"Port"`
+		Domain                 string   `yaml:"Domain"`
+		JWTSecret              string   `yaml:"JWTSecret"`
+		AllowedOrigins         []string `yaml:"AllowedOrigins"`
+		EnableCSRF             bool     `yaml:"EnableCSRF"`
+		EnableSubscriptions    bool     `yaml:"EnableSubscriptions"`
+		EnableAuth             bool     `yaml:"EnableAuth"`
+		AuthCookieAge          int      `yaml:"AuthCookieAge"`
+		RefreshCookieAge       int      `yaml:"RefreshCookieAge"`
+		AuthCookieName         string   `yaml:"AuthCookieName"`
+		RefreshCookieName      string   `yaml:"RefreshCookieName"`
+		AuthTokenExpiry        int      `yaml:"AuthTokenExpiry"`
+		RefreshTokenExpiry     int      `yaml:"RefreshTokenExpiry"`
+		TestMode               bool     `yaml:"TestMode"`
+		HealthCheckInterval    int      `yaml:"HealthCheckInterval"`
+		HealthCheckMaxFailures int      `yaml:"HealthCheckMaxFailures"`
+		HideUnhealthyServers   bool     `yaml:"HideUnhealthyServers"`
+		DeviceSessionTimeout   int      `yaml:"DeviceSessionTimeout"`
+		AdminTwoFactorRequired bool     `yaml:"AdminTwoFactorRequired"`
+		LoginDelayAfter        int      `yaml:"LoginDelayAfter"`
+		LoginLockoutAfter      int      `yaml:"LoginLockoutAfter"`
+		LoginLockoutMinutes    int      `yaml:"LoginLockoutMinutes"`
+		IPLockoutAfter         int      `yaml:"IPLockoutAfter"`
+		ShutdownTimeout        int      `yaml:"ShutdownTimeout"`
+		// TrustedProxies are the addresses or CIDR ranges of the load
+		// balancers in front of the API. The client IP is only read from
+		// forwarding headers on requests which come through them.
+		TrustedProxies []string `yaml:"TrustedProxies"`
 	} `yaml:"App"`
 
+	Logging struct {
+		Level      string `yaml:"Level"`
+		Format     string `yaml:"Format"`
+		File       string `yaml:"File"`
+		MaxSizeMB  int    `yaml:"MaxSizeMB"`
+		MaxAgeDays int    `yaml:"MaxAgeDays"`
+		MaxBackups int    `yaml:"MaxBackups"`
+	} `yaml:"Logging"`
+
+	Health struct {
+		CheckIntegrations bool `yaml:"CheckIntegrations"`
+	} `yaml:"Health"`
+
+	Metrics struct {
+		Enabled bool   `yaml:"Enabled"`
+		Token   string `yaml:"Token"`
+	} `yaml:"Metrics"`
+
+	RateLimit struct {
+		Enabled bool                      `yaml:"Enabled"`
+		Groups  map[string]RateLimitGroup `yaml:"Groups"`
+	} `yaml:"RateLimit"`
+
 	DB struct {
 		User     string `yaml:"User"`
 		Password string `yaml:"Password"`
 		Database string `yaml:"Database"`
 		Host     string `yaml:"Host"`
 		Port     int    `yaml:"Port"`
+		// ConnectAttempts is how many times startup tries to connect
+		// before giving up, waiting up to ConnectMaxWait seconds between
+		ConnectAttempts int `yaml:"ConnectAttempts"`
+		ConnectMaxWait  int `yaml:"ConnectMaxWait"`
 	} `yaml:"DB"`
 
 	Stripe struct {
@@ -50,35 +93,99 @@ type Config struct {
 			Registration   string `yaml:"Registration"`
 			SupportRequest string `yaml:"SupportRequest"`
 			ForgotPassword string `yaml:"ForgotPassword"`
+			AccountLocked  string `yaml:"AccountLocked"`
 		} `yaml:"Templates"`
 	} `yaml:"SendGrid"`
 }
 
-func Init(filename string) {
+// RateLimitGroup is the number of requests allowed to a group of routes
+// within Window seconds
+type RateLimitGroup struct {
+	Requests int `yaml:"Requests"`
+	Window   int `yaml:"Window"`
+}
+
+// Init loads and validates the config file, which Load then returns
+// until it is reloaded
+func Init(filename string) error {
 	configFilename = filename
+	return Reload()
+}
+
+// Filename returns the path of the config file being used
+func Filename() string {
+	return configFilename
 }
 
+// Load returns the current config. It is only read from the file when
+// Init has not loaded it yet.
 func Load() Config {
-	conf := Config{}
-	yamlFile, err := ioutil.ReadFile(configFilename)
-	if err != nil {
-		fmt.Println(err)
+	if conf, ok := current.Load().(*Config); ok {
+		return *conf
 	}
-	err = yaml.Unmarshal(yamlFile, &conf)
+	conf, err := read()
 	if err != nil {
 		fmt.Println(err)
 	}
 	return conf
 }
 
+// read reads the config file then applies any environment overrides
+func read() (Config, error) {
+	conf, err := readFile()
+	if err != nil {
+		return conf, err
+	}
+	if _, err := applyEnv(&conf); err != nil {
+		return conf, err
+	}
+	return conf, nil
+}
+
+// readFile reads the config file without environment overrides
+func readFile() (Config, error) {
+	conf := Config{}
+	yamlFile, err := ioutil.ReadFile(configFilename)
+	if err != nil {
+		return conf, err
+	}
+	if err := yaml.Unmarshal(yamlFile, &conf); err != nil {
+		return conf, fmt.Errorf("%s: %v", configFilename, err)
+	}
+	return conf, nil
+}
+
+// SaveConfig writes the config back to the file it was loaded from. Fields
+// set by environment variables keep the value already in the file so
+// secrets provided that way are never written to disk. The saved config
+// is then reloaded so it takes effect straight away.
 func (c *Config) SaveConfig() error {
-	newConf, err := yaml.Marshal(&c)
+	onDisk, err := readFile()
+	if err != nil {
+		return err
+	}
+	overridden, err := applyEnv(&Config{})
+	if err != nil {
+		return err
+	}
+	save := *c
+	dst := reflect.ValueOf(&save).Elem()
+	src := reflect.ValueOf(&onDisk).Elem()
+	for _, index := range overridden {
+		dst.FieldByIndex(index).Set(src.FieldByIndex(index))
+	}
+
+	newConf, err := yaml.Marshal(&save)
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile("config.yaml", newConf, 0644)
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(configFilename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	err = ioutil.WriteFile(configFilename, newConf, mode)
 	if err != nil {
 		return err
 	}
-	return nil
+	return Reload()
 }
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the name of every environment variable which overrides
+// a config field. The rest of the name is the yaml keys of the field in
+// upper case joined by underscores, so DB.Password is EIREVPN_DB_PASSWORD.
+// Appending _FILE reads the value from the named file instead, for secrets
+// mounted by the container runtime. Lists are comma separated and maps,
+// such as the rate limit groups, can only be set in the file.
+const EnvPrefix = "EIREVPN"
+
+// applyEnv sets every field of conf which has an environment variable,
+// returning the index path of each field it set
+func applyEnv(conf *Config) ([][]int, error) {
+	var set [][]int
+	err := walkEnv(reflect.ValueOf(conf).Elem(), EnvPrefix, nil, &set)
+	return set, err
+}
+
+func walkEnv(v reflect.Value, prefix string, index []int, set *[][]int) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(key)
+		fieldIndex := append(append([]int{}, index...), i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := walkEnv(v.Field(i), name, fieldIndex, set); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok, err := lookupEnv(name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		*set = append(*set, fieldIndex)
+	}
+	return nil
+}
+
+// lookupEnv returns the value of the variable, or the contents of the
+// file named by its _FILE variant
+func lookupEnv(name string) (string, bool, error) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true, nil
+	}
+	if path, ok := os.LookupEnv(name + "_FILE"); ok {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_FILE: %v", name, err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), true, nil
+	}
+	return "", false, nil
+}
+
+func setField(f reflect.Value, value string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.ParseInt(value, 10, 0)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint:
+		n, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Slice:
+		if f.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", f.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// watchInterval is how often Watch checks the config file for changes
+const watchInterval = 5 * time.Second
+
+var (
+	// current holds a *Config, swapped whole on each reload so readers
+	// never see a config part way through being replaced
+	current atomic.Value
+
+	reloadMu    sync.Mutex
+	lastErr     error
+	lastModTime time.Time
+	subscribers []func(prev, next Config)
+)
+
+// Reload reads and validates the config file. The current config is only
+// replaced, and subscribers notified, when the new one is valid.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if info, err := os.Stat(configFilename); err == nil {
+		lastModTime = info.ModTime()
+	}
+	conf, err := read()
+	if err == nil {
+		err = conf.Validate()
+	}
+	lastErr = err
+	if err != nil {
+		return err
+	}
+
+	prev := Load()
+	current.Store(&conf)
+	for _, fn := range subscribers {
+		fn(prev, conf)
+	}
+	return nil
+}
+
+// Check returns the error of the last reload, if it failed. The previous
+// config stays in use until the file is fixed.
+func Check() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return lastErr
+}
+
+// Subscribe calls fn with the previous and new config after every successful
+// reload. Reloads wait for fn to return so it must not call Reload.
+func Subscribe(fn func(prev, next Config)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+// Validate reports every required field which is missing and any trusted
+// proxy which is not an address or range
+func (c Config) Validate() error {
+	required := []struct {
+		name string
+		set  bool
+	}{
+		{"App.JWTSecret", c.App.JWTSecret != ""},
+		{"App.AuthCookieName", c.App.AuthCookieName != ""},
+		{"App.RefreshCookieName", c.App.RefreshCookieName != ""},
+		{"DB.User", c.DB.User != ""},
+		{"DB.Database", c.DB.Database != ""},
+		{"DB.Host", c.DB.Host != ""},
+		{"DB.Port", c.DB.Port != 0},
+	}
+	var missing []string
+	for _, field := range required {
+		if !field.set {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: missing required fields: %s", configFilename, strings.Join(missing, ", "))
+	}
+	for _, entry := range c.App.TrustedProxies {
+		if net.ParseIP(entry) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil {
+			return fmt.Errorf("%s: App.TrustedProxies: %q is not an IP address or CIDR range", configFilename, entry)
+		}
+	}
+	return nil
+}
+
+// Watch reloads the config when the file changes or the process receives
+// SIGHUP, until stop is closed. Failed reloads are printed and the
+// previous config kept.
+func Watch(stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+		case <-ticker.C:
+			if !changed() {
+				continue
+			}
+		}
+		if err := Reload(); err != nil {
+			fmt.Println("Config reload failed, keeping the previous config:", err)
+		}
+	}
+}
+
+// changed reports whether the file has been modified since it was last read
+func changed() bool {
+	info, err := os.Stat(configFilename)
+	if err != nil {
+		return false
+	}
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	return !info.ModTime().Equal(lastModTime)
+}
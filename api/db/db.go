@@ -1,9 +1,13 @@
 package db
 
 import (
+	"context"
 	"eirevpn/api/config"
+	"eirevpn/api/metrics"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
@@ -13,9 +17,17 @@ import (
 var db *gorm.DB
 var err error
 
-// Init creates a connection to postgres database and
-// migrates any new models
-func Init(config config.Config, debug bool, models []interface{}) {
+const (
+	defaultConnectAttempts = 10
+	defaultConnectMaxWait  = 30
+	pingTimeout            = 2 * time.Second
+)
+
+// Init creates a connection to postgres database. The
+// connection is retried with backoff so the API can start
+// before the database is ready. The schema is managed by
+// the migrations package.
+func Init(config config.Config, debug bool) error {
 
 	dbinfo := fmt.Sprintf("user=%s password=%s host=%s port=%v dbname=%s sslmode=disable",
 		config.DB.User,
@@ -25,27 +37,46 @@ func Init(config config.Config, debug bool, models []interface{}) {
 		config.DB.Database,
 	)
 
-	fmt.Println(dbinfo)
-
-	db, err = gorm.Open("postgres", dbinfo)
-	db.LogMode(debug)
+	attempts := config.DB.ConnectAttempts
+	if attempts <= 0 {
+		attempts = defaultConnectAttempts
+	}
+	maxWait := time.Duration(config.DB.ConnectMaxWait) * time.Second
+	if maxWait <= 0 {
+		maxWait = defaultConnectMaxWait * time.Second
+	}
 
-	if err != nil {
-		log.Println("Failed to connect to database")
-		panic(err.Error())
+	wait := time.Second
+	for attempt := 1; ; attempt++ {
+		db, err = gorm.Open("postgres", dbinfo)
+		if err == nil {
+			break
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("failed to connect to database after %d attempts: %v", attempt, err)
+		}
+		log.Printf("Failed to connect to database (attempt %d of %d), retrying in %s: %v", attempt, attempts, wait, err)
+		time.Sleep(wait)
+		wait *= 2
+		if wait > maxWait {
+			wait = maxWait
+		}
 	}
+	db.LogMode(debug)
 	log.Println("Database connected")
 
-	for _, model := range models {
-		if !db.HasTable(model) {
-			err := db.CreateTable(model).Error
-			if err != nil {
-				log.Fatal(err)
-			}
-			log.Println("Table Created")
-		}
-		db.AutoMigrate(model)
+	metrics.RegisterCallbacks(db)
+	return nil
+}
+
+// Ping checks the database can still be reached
+func Ping() error {
+	if db == nil {
+		return errors.New("database not connected")
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	return db.DB().PingContext(ctx)
 }
 
 //GetDB ...
@@ -43,6 +43,7 @@ var (
 	TokenInvalid                = APIError{403, "TOKENINVALID", "Token Invalid", "Authorisation token invalid"}
 	InvalidIdentifier           = APIError{403, "INVIDENTIFIER", "Invlaid identifier", "Invlaid identifier"}
 	UserSessionDelete           = APIError{403, "FAILEDUSERSESS", "Failed To Delete User Session", "Failed To Delete User Session"}
+	SessionNotFound             = APIError{400, "SESSIONNOTFND", "Session Not Found", "No session was found matching the queried id"}
 	CSRFTokenInvalid            = APIError{403, "CSRFTOKEN", "CSRF Token", "CSRF token is invalid"}
 	ProtectedRouted             = APIError{403, "PROTECTROUTE", "Protected Route", "You do not have the correct permissions to access this route."}
 	UserPlanExpired             = APIError{401, "EXPIREDPLAN", "User Plan Expired", "You do not have an active plan."}
@@ -50,8 +51,32 @@ var (
 	SettingsUpdateFailed        = APIError{401, "SETTINGSUPFAILED", "Settings Update Failed", "Failed to update the systems settings."}
 	MsgBindingFailed            = APIError{401, "MSGBINDINGFAILED", "Message Binding Failed", "Message binding failed."}
 	BindingFailed               = APIError{401, "BINDINGFAILED", "Binding Failed", "Binding failed."}
+	ProxyCredentialIssue        = APIError{500, "PROXYCREDISSUE", "Proxy Credential Issue Failed", "Failed to issue proxy credentials for the server."}
+	ProxyCredentialRevoke       = APIError{500, "PROXYCREDREVOKE", "Proxy Credential Revoke Failed", "Failed to revoke proxy credentials for the user."}
+	ProxyNodeUnreachable        = APIError{502, "PROXYNODEUNREACH", "Proxy Node Unreachable", "Failed to fetch the status of the proxy node."}
+	NodeUnauthorised            = APIError{401, "NODEUNAUTH", "Node Unauthorised", "The proxy node secret is missing or incorrect."}
+	NoServerAvailable           = APIError{400, "NOSERVERAVAIL", "No Server Available", "There are no healthy servers available in the requested country."}
+	ServerFull                  = APIError{503, "SERVERFULL", "Server Full", "The server is at capacity, please try another server."}
+	ServerDisabled              = APIError{503, "SERVERDISABLED", "Server Disabled", "The server has been taken out of service, please try another server."}
+	DeviceLimitReached          = APIError{403, "DEVICELIMIT", "Device Limit Reached", "Your plan does not allow any more devices to connect at the same time."}
+	TwoFactorRequired           = APIError{403, "2FAREQUIRED", "Two Factor Required", "Two factor authentication must be enabled on your account to access this route."}
+	TwoFactorCodeInvalid        = APIError{401, "2FACODEINVALID", "Two Factor Code Invalid", "The authentication or recovery code is incorrect."}
+	TwoFactorTokenInvalid       = APIError{401, "2FATOKENINVALID", "Two Factor Token Invalid", "Your login has expired, please enter your email and password again."}
+	TwoFactorNotEnabled         = APIError{400, "2FANOTENABLED", "Two Factor Not Enabled", "Two factor authentication is not enabled on your account."}
+	TwoFactorAlreadyEnabled     = APIError{400, "2FAENABLED", "Two Factor Already Enabled", "Two factor authentication is already enabled on your account."}
+	AccountLocked               = APIError{429, "ACCOUNTLOCKED", "Account Locked", "Too many failed attempts, please wait before trying again."}
+	LockoutNotFound             = APIError{400, "LOCKOUTNOTFND", "Lockout Not Found", "No lockout was found matching the queried id"}
+	RateLimited                 = APIError{429, "RATELIMITED", "Too Many Requests", "You have made too many requests, please wait before trying again."}
+	APITokenInvalid             = APIError{401, "APITOKENINVALID", "API Token Invalid", "The API token is invalid, expired or has been revoked."}
+	APITokenScope               = APIError{403, "APITOKENSCOPE", "API Token Scope", "The API token does not have the scope required for this route."}
+	APITokenNotFound            = APIError{400, "APITOKENNOTFND", "API Token Not Found", "No API token was found matching the queried id"}
+	InvalidRole                 = APIError{400, "INVALIDROLE", "Invalid Role", "The role does not exist or cannot be assigned to this user."}
+	DataQuotaExceeded           = APIError{403, "DATAQUOTAEXCEEDED", "Data Quota Exceeded", "You have used all of the data included in your plan for this billing period."}
+	MetricsDisabled             = APIError{404, "METRICSDISABLED", "Metrics Disabled", "Metrics are not enabled or have no token configured on this server."}
+	MetricsTokenInvalid         = APIError{401, "METRICSTOKENINVALID", "Metrics Token Invalid", "The metrics token is missing or incorrect."}
+	NotReady                    = APIError{503, "NOTREADY", "Not Ready", "A dependency of the API is unavailable."}
 )
 
 func (err *APIError) Error() string {
-	return fmt.Sprintf("Code: %d, Title: %s, Detail: %s", err.Code, err.Title, err.Detail)
+	return fmt.Sprintf("Code: %s, Title: %s, Detail: %s", err.Code, err.Title, err.Detail)
 }
@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"eirevpn/api/errors"
+	"eirevpn/api/logger"
+	"eirevpn/api/models"
+	"eirevpn/api/requestid"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditLogs fetches the audit logs, newest first. They can be filtered by
+// actor_id, action, target_type, target_id and an RFC 3339 since and until.
+func AuditLogs(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	filter, err := parseFilter(c)
+	if err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/audit - AuditLogs()",
+			Code:      errors.InvalidForm.Code,
+			Extra:     map[string]interface{}{"Query": c.Request.URL.RawQuery},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
+		return
+	}
+
+	var logs models.AllAuditLogs
+	if err := logs.FindAll(offset, filter); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/audit - AuditLogs()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	count, err := logs.Count(filter)
+	if err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/audit - AuditLogs()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"data": gin.H{
+			"count":      count,
+			"audit_logs": logs,
+		},
+	})
+}
+
+func parseFilter(c *gin.Context) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		Action:     models.AuditAction(c.Query("action")),
+		TargetType: c.Query("target_type"),
+	}
+	if v := c.Query("actor_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return filter, err
+		}
+		filter.ActorID = uint(id)
+	}
+	if v := c.Query("target_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return filter, err
+		}
+		filter.TargetID = uint(id)
+	}
+	if v := c.Query("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, err
+		}
+		filter.Since = t
+	}
+	if v := c.Query("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, err
+		}
+		filter.Until = t
+	}
+	return filter, nil
+}
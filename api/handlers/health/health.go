@@ -0,0 +1,119 @@
+package health
+
+import (
+	"eirevpn/api/config"
+	"eirevpn/api/db"
+	"eirevpn/api/errors"
+	"eirevpn/api/logger"
+	"eirevpn/api/requestid"
+	"eirevpn/api/settings"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const dialTimeout = 3 * time.Second
+
+// integrationHosts are dialled when integration checks are enabled
+var integrationHosts = map[string]string{
+	"stripe":   "api.stripe.com:443",
+	"sendgrid": "api.sendgrid.com:443",
+}
+
+// draining is set once the API starts shutting down
+var draining int32
+
+// Drain makes the readiness check fail so load balancers stop sending
+// new requests while in-flight ones finish
+func Drain() {
+	atomic.StoreInt32(&draining, 1)
+}
+
+// Check is the result of checking a single dependency
+type Check struct {
+	Status  string `json:"status"`
+	Latency int64  `json:"latency_ms"`
+	Error   string `json:"error,omitempty"`
+}
+
+func check(fn func() error) Check {
+	start := time.Now()
+	err := fn()
+	c := Check{Status: "ok", Latency: int64(time.Since(start) / time.Millisecond)}
+	if err != nil {
+		c.Status = "error"
+		c.Error = err.Error()
+	}
+	return c
+}
+
+// Healthz reports the process is up and serving requests
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"data":   gin.H{"status": "ok"},
+	})
+}
+
+// Readyz reports whether the API can serve traffic. The database must be
+// reachable. A config file which failed to reload is reported but does not
+// fail the check, as the last valid config is still in use. Integrations
+// are only checked when enabled in the config and never fail the check,
+// as the API works without them.
+func Readyz(c *gin.Context) {
+	checks := map[string]Check{
+		"config":   check(config.Check),
+		"database": check(db.Ping),
+	}
+	ready := checks["database"].Status == "ok"
+	if atomic.LoadInt32(&draining) == 1 {
+		checks["shutdown"] = Check{Status: "error", Error: "shutting down"}
+		ready = false
+	}
+
+	conf := config.Load()
+	if conf.Health.CheckIntegrations {
+		active := map[string]bool{
+			"stripe":   settings.Bool(settings.StripeIntegrationActive),
+			"sendgrid": conf.SendGrid.IntegrationActive,
+		}
+		for name, host := range integrationHosts {
+			if !active[name] {
+				continue
+			}
+			checks[name] = check(func() error {
+				conn, err := net.DialTimeout("tcp", host, dialTimeout)
+				if err != nil {
+					return err
+				}
+				return conn.Close()
+			})
+		}
+	}
+
+	if !ready {
+		logger.Warn(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/readyz - Readyz()",
+			Code:      errors.NotReady.Code,
+			Extra:     map[string]interface{}{"Checks": checks},
+			Err:       errors.NotReady.Detail,
+		})
+		c.AbortWithStatusJSON(errors.NotReady.Status, gin.H{
+			"status": errors.NotReady.Status,
+			"code":   errors.NotReady.Code,
+			"title":  errors.NotReady.Title,
+			"detail": errors.NotReady.Detail,
+			"checks": checks,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"data":   gin.H{"checks": checks},
+	})
+}
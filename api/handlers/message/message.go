@@ -4,6 +4,7 @@ import (
 	"eirevpn/api/errors"
 	"eirevpn/api/integrations/sendgrid"
 	"eirevpn/api/logger"
+	"eirevpn/api/requestid"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -21,9 +22,10 @@ func Message(c *gin.Context) {
 	mf := MessageFields{}
 	if err := c.BindJSON(&mf); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/message - Message()",
-			Code: errors.MsgBindingFailed.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/message - Message()",
+			Code:      errors.MsgBindingFailed.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -31,10 +33,11 @@ func Message(c *gin.Context) {
 
 	if err := sendgrid.Send().SupportRequest(mf.Email, mf.Subject, mf.Message); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/message - Message()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"Email": mf.Email, "Detail": "Error sending support email"},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/message - Message()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"Email": mf.Email, "Detail": "Error sending support email"},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
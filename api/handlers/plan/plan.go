@@ -1,9 +1,11 @@
 package plan
 
 import (
+	"eirevpn/api/audit"
 	"eirevpn/api/errors"
 	"eirevpn/api/logger"
 	"eirevpn/api/models"
+	"eirevpn/api/requestid"
 	"net/http"
 	"strconv"
 
@@ -17,10 +19,11 @@ func Plan(c *gin.Context) {
 	plan.ID = uint(planID)
 	if err := plan.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/plan/:id - Plan()",
-			Code:  errors.PlanNotFound.Code,
-			Extra: map[string]interface{}{"PlanID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/plan/:id - Plan()",
+			Code:      errors.PlanNotFound.Code,
+			Extra:     map[string]interface{}{"PlanID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.PlanNotFound.Status, errors.PlanNotFound)
 		return
@@ -42,9 +45,10 @@ func CreatePlan(c *gin.Context) {
 
 	if err := c.BindJSON(&plan); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/plans/create - CreatePlan()",
-			Code: errors.InvalidForm.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/plans/create - CreatePlan()",
+			Code:      errors.InvalidForm.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
 		return
@@ -52,8 +56,9 @@ func CreatePlan(c *gin.Context) {
 
 	if err := plan.Create(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/plans/create - CreatePlan()",
-			Code: errors.InternalServerError.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/plans/create - CreatePlan()",
+			Code:      errors.InternalServerError.Code,
 			Extra: map[string]interface{}{
 				"Plan": plan,
 			},
@@ -63,6 +68,13 @@ func CreatePlan(c *gin.Context) {
 		return
 	}
 
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionPlanCreate,
+		TargetType: "plan",
+		TargetID:   plan.ID,
+		After:      plan,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 		"errors": make([]string, 0),
@@ -80,10 +92,11 @@ func DeletePlan(c *gin.Context) {
 	plan.ID = uint(planID)
 	if err := plan.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/plans/delete/:id - DeletePlan()",
-			Code:  errors.PlanNotFound.Code,
-			Extra: map[string]interface{}{"PlanID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/plans/delete/:id - DeletePlan()",
+			Code:      errors.PlanNotFound.Code,
+			Extra:     map[string]interface{}{"PlanID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.PlanNotFound.Status, errors.PlanNotFound)
 		return
@@ -91,15 +104,23 @@ func DeletePlan(c *gin.Context) {
 
 	if err := plan.Delete(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/plans/delete/:id - DeletePlan()",
-			Code:  errors.PlanNotFound.Code,
-			Extra: map[string]interface{}{"PlanID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/plans/delete/:id - DeletePlan()",
+			Code:      errors.PlanNotFound.Code,
+			Extra:     map[string]interface{}{"PlanID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
 	}
 
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionPlanDelete,
+		TargetType: "plan",
+		TargetID:   plan.ID,
+		Before:     plan,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 		"errors": make([]string, 0),
@@ -114,16 +135,19 @@ func UpdatePlan(c *gin.Context) {
 	plan.ID = uint(planID)
 
 	type PlanUpdates struct {
-		Name string `json:"name" binding:"required"`
+		Name          string `json:"name" binding:"required"`
+		DataAllowance *int64 `json:"data_allowance"`
+		MaxDevices    *int   `json:"max_devices"`
 	}
 	planUdates := PlanUpdates{}
 
 	if err := plan.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/plans/update/:id - UpdatePlan()",
-			Code:  errors.PlanNotFound.Code,
-			Extra: map[string]interface{}{"PlanID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/plans/update/:id - UpdatePlan()",
+			Code:      errors.PlanNotFound.Code,
+			Extra:     map[string]interface{}{"PlanID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.PlanNotFound.Status, errors.PlanNotFound)
 		return
@@ -131,26 +155,44 @@ func UpdatePlan(c *gin.Context) {
 
 	if err := c.BindJSON(&planUdates); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/plans/update/:id - UpdatePlan()",
-			Code:  errors.InvalidForm.Code,
-			Extra: map[string]interface{}{"PlanID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/plans/update/:id - UpdatePlan()",
+			Code:      errors.InvalidForm.Code,
+			Extra:     map[string]interface{}{"PlanID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
 		return
 	}
 
+	before := plan
 	plan.Name = planUdates.Name
+	if planUdates.DataAllowance != nil {
+		plan.DataAllowance = *planUdates.DataAllowance
+	}
+	if planUdates.MaxDevices != nil {
+		plan.MaxDevices = *planUdates.MaxDevices
+	}
 	if err := plan.Save(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/plans/update/:id - UpdatePlan()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"PlanID": plan.ID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/plans/update/:id - UpdatePlan()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"PlanID": plan.ID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
 	}
 
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionPlanUpdate,
+		TargetType: "plan",
+		TargetID:   plan.ID,
+		Before:     before,
+		After:      plan,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 		"errors": make([]string, 0),
@@ -165,9 +207,10 @@ func AllPlansPublic(c *gin.Context) {
 
 	if err := plans.FindAll(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/plans - AllPlans()",
-			Code: errors.InternalServerError.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/plans - AllPlans()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 	}
@@ -196,9 +239,10 @@ func AllPlans(c *gin.Context) {
 
 	if err := plans.FindAll(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/plans - AllPlans()",
-			Code: errors.InternalServerError.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/plans - AllPlans()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 	}
@@ -1,21 +1,60 @@
 package server
 
 import (
-	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"eirevpn/api/audit"
 	"eirevpn/api/config"
 	"eirevpn/api/errors"
+	"eirevpn/api/integrations/proxy"
 	"eirevpn/api/logger"
-	"encoding/json"
-	"fmt"
+	"eirevpn/api/metrics"
+	"eirevpn/api/permissions"
+	"eirevpn/api/requestid"
+	"eirevpn/api/settings"
+	"eirevpn/api/util/clientip"
 
 	"eirevpn/api/models"
+	"encoding/hex"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
 )
 
+// defaultDeviceSessionTimeout is the number of minutes a device counts
+// towards the device limit of a plan after it last connected
+const defaultDeviceSessionTimeout = 30
+
+func deviceSessionTimeout() time.Duration {
+	timeout := config.Load().App.DeviceSessionTimeout
+	if timeout <= 0 {
+		timeout = defaultDeviceSessionTimeout
+	}
+	return time.Duration(timeout) * time.Minute
+}
+
+// ActiveDevices counts the devices of every user which have connected
+// within the device session timeout. It is read by the metrics endpoint.
+func ActiveDevices() float64 {
+	var sessions models.AllDeviceSessions
+	count, err := sessions.CountActive(time.Now().Add(-deviceSessionTimeout()))
+	if err != nil {
+		logger.Log(logger.Fields{
+			Loc:  "server.go - ActiveDevices()",
+			Code: errors.InternalServerError.Code,
+			Err:  err.Error(),
+		})
+		return math.NaN()
+	}
+	return float64(count)
+}
+
 // Server fetches a server by ID
 func Server(c *gin.Context) {
 	serverID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
@@ -23,10 +62,11 @@ func Server(c *gin.Context) {
 	server.ID = uint(serverID)
 	if err := server.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/server/:id - Server()",
-			Code:  errors.ServerNotFound.Code,
-			Extra: map[string]interface{}{"ConnID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/server/:id - Server()",
+			Code:      errors.ServerNotFound.Code,
+			Extra:     map[string]interface{}{"ConnID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.ServerNotFound.Status, errors.ServerNotFound)
 		return
@@ -41,15 +81,54 @@ func Server(c *gin.Context) {
 
 }
 
+// Status fetches the version and state reported by the servers proxy node
+func Status(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var server models.Server
+	server.ID = uint(serverID)
+	if err := server.Find(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/server_status/:id - Status()",
+			Code:      errors.ServerNotFound.Code,
+			Extra:     map[string]interface{}{"ServerID": c.Param("id")},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.ServerNotFound.Status, errors.ServerNotFound)
+		return
+	}
+
+	status, err := proxy.Status(server.Node())
+	if err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/server_status/:id - Status()",
+			Code:      errors.ProxyNodeUnreachable.Code,
+			Extra:     map[string]interface{}{"ServerID": server.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.ProxyNodeUnreachable.Status, errors.ProxyNodeUnreachable)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"data": gin.H{
+			"node": status,
+		},
+	})
+}
+
 // CreateServer creates a new server
 func CreateServer(c *gin.Context) {
 	var server models.Server
 
 	if err := c.ShouldBind(&server); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/server/create - CreateServer()",
-			Code: errors.InvalidForm.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/server/create - CreateServer()",
+			Code:      errors.InvalidForm.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
 		return
@@ -61,9 +140,10 @@ func CreateServer(c *gin.Context) {
 		err := c.SaveUploadedFile(file, "assets/"+file.Filename)
 		if err != nil {
 			logger.Log(logger.Fields{
-				Loc:  "/server/create - CreateServer()",
-				Code: errors.InternalServerError.Code,
-				Err:  err.Error(),
+				RequestID: requestid.Get(c),
+				Loc:       "/server/create - CreateServer()",
+				Code:      errors.InternalServerError.Code,
+				Err:       err.Error(),
 			})
 			c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 			return
@@ -74,8 +154,9 @@ func CreateServer(c *gin.Context) {
 
 	if err := server.Create(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/server/create - CreateServer()",
-			Code: errors.InternalServerError.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/server/create - CreateServer()",
+			Code:      errors.InternalServerError.Code,
 			Extra: map[string]interface{}{
 				"Server": server,
 			},
@@ -85,6 +166,13 @@ func CreateServer(c *gin.Context) {
 		return
 	}
 
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionServerCreate,
+		TargetType: "server",
+		TargetID:   server.ID,
+		After:      server,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 		"errors": make([]string, 0),
@@ -102,10 +190,11 @@ func DeleteServer(c *gin.Context) {
 	server.ID = uint(serverID)
 	if err := server.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/server/delete/:id - DeleteServer()",
-			Code:  errors.ServerNotFound.Code,
-			Extra: map[string]interface{}{"ServerID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/server/delete/:id - DeleteServer()",
+			Code:      errors.ServerNotFound.Code,
+			Extra:     map[string]interface{}{"ServerID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.ServerNotFound.Status, errors.ServerNotFound)
 		return
@@ -113,15 +202,23 @@ func DeleteServer(c *gin.Context) {
 
 	if err := server.Delete(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/server/delete/:id - DeleteServer()",
-			Code:  errors.ServerNotFound.Code,
-			Extra: map[string]interface{}{"ServerID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/server/delete/:id - DeleteServer()",
+			Code:      errors.ServerNotFound.Code,
+			Extra:     map[string]interface{}{"ServerID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
 	}
 
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionServerDelete,
+		TargetType: "server",
+		TargetID:   server.ID,
+		Before:     server,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 		"errors": make([]string, 0),
@@ -136,19 +233,24 @@ func UpdateServer(c *gin.Context) {
 	server.ID = uint(ServerID)
 
 	type ServerUpdates struct {
-		IP       string `json:"ip" binding:"required"`
-		Port     int    `json:"port" binding:"required"`
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
+		IP        string `json:"ip" binding:"required"`
+		Port      int    `json:"port" binding:"required"`
+		SocksPort int    `json:"socks_port"`
+		Username  string `json:"username" binding:"required"`
+		Password  string `json:"password" binding:"required"`
+		APIPort   int    `json:"api_port"`
+		APISecret string `json:"api_secret"`
+		MaxUsers  int    `json:"max_users"`
 	}
 	serverUpdates := ServerUpdates{}
 
 	if err := server.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/server/update/:id - UpdateServer()",
-			Code:  errors.ServerNotFound.Code,
-			Extra: map[string]interface{}{"ServerID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/server/update/:id - UpdateServer()",
+			Code:      errors.ServerNotFound.Code,
+			Extra:     map[string]interface{}{"ServerID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.ServerNotFound.Status, errors.ServerNotFound)
 		return
@@ -156,50 +258,52 @@ func UpdateServer(c *gin.Context) {
 
 	if err := c.BindJSON(&serverUpdates); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/server/update/:id - UpdateServer()",
-			Code:  errors.InvalidForm.Code,
-			Extra: map[string]interface{}{"ServerID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/server/update/:id - UpdateServer()",
+			Code:      errors.InvalidForm.Code,
+			Extra:     map[string]interface{}{"ServerID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
 		return
 	}
 
+	before := server
 	server.IP = serverUpdates.IP
 	server.Port = serverUpdates.Port
 	server.Username = serverUpdates.Username
 	server.Password = serverUpdates.Password
+	if serverUpdates.MaxUsers != 0 {
+		server.MaxUsers = serverUpdates.MaxUsers
+	}
+	if serverUpdates.SocksPort != 0 {
+		server.SocksPort = serverUpdates.SocksPort
+	}
+	if serverUpdates.APIPort != 0 {
+		server.APIPort = serverUpdates.APIPort
+	}
+	if serverUpdates.APISecret != "" {
+		server.APISecret = serverUpdates.APISecret
+	}
 	if err := server.Save(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/server/update/:id - UpdateServer()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"ServerID": server.ID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/server/update/:id - UpdateServer()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"ServerID": server.ID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
 	}
 
-	// update proxy server with new config
-	if !config.Load().App.TestMode {
-		var credentials = map[string]string{
-			"username": server.Username,
-			"password": server.Password,
-		}
-		jsonStr, _ := json.Marshal(credentials)
-		url := fmt.Sprintf("http://%s:%v/update_creds", server.IP, 3003)
-		req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonStr))
-		req.Header.Set("Content-Type", "application/json")
-		client := &http.Client{}
-		if _, err := client.Do(req); err != nil {
-			logger.Log(logger.Fields{
-				Loc:   "/server/update/:id - UpdateServer()",
-				Code:  errors.InternalServerError.Code,
-				Extra: map[string]interface{}{"ServerID": server.ID, "Detail": "Error posting new credentials to proxy servers"},
-				Err:   err.Error(),
-			})
-			c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
-		}
-	}
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionServerUpdate,
+		TargetType: "server",
+		TargetID:   server.ID,
+		Before:     before,
+		After:      server,
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
@@ -211,24 +315,72 @@ func UpdateServer(c *gin.Context) {
 // Connect returns a username and password for the server if the user
 // has a valid subscription
 func Connect(c *gin.Context) {
+	// connect/best shares the connect/:id route as gin cannot
+	// register a static path alongside the wildcard
+	if c.Param("id") == "best" {
+		ConnectBest(c)
+		return
+	}
+
 	serverID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
 	var server models.Server
 	server.ID = uint(serverID)
 	if err := server.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/server/:id - Server()",
-			Code:  errors.ServerNotFound.Code,
-			Extra: map[string]interface{}{"ConnID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/server/:id - Server()",
+			Code:      errors.ServerNotFound.Code,
+			Extra:     map[string]interface{}{"ConnID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.ServerNotFound.Status, errors.ServerNotFound)
 		return
 	}
 
+	connect(c, &server)
+}
+
+// ConnectBest connects the user to the least loaded healthy server
+// in the country given by the country query parameter
+func ConnectBest(c *gin.Context) {
+	country := c.Query("country")
+	var servers models.AllServers
+	if err := servers.FindByCountry(country); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/servers/connect/best - ConnectBest()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"Country": country},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	server := servers.LeastLoaded()
+	if server == nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/servers/connect/best - ConnectBest()",
+			Code:      errors.NoServerAvailable.Code,
+			Extra:     map[string]interface{}{"Country": country},
+			Err:       errors.NoServerAvailable.Detail,
+		})
+		c.AbortWithStatusJSON(errors.NoServerAvailable.Status, errors.NoServerAvailable)
+		return
+	}
+
+	connect(c, server)
+}
+
+// connect checks the user is allowed to use the server, issues their
+// proxy credentials and records the connection
+func connect(c *gin.Context, server *models.Server) {
 	userID, exists := c.Get("UserID")
 	if !exists {
 		logger.Log(logger.Fields{
-			Loc: "/server/connect/:id - Connect()",
+			RequestID: requestid.Get(c),
+			Loc:       "/server/connect/:id - Connect()",
 			Extra: map[string]interface{}{
 				"UserID": userID,
 				"Detail": "User ID does not exist in the context",
@@ -238,14 +390,15 @@ func Connect(c *gin.Context) {
 		return
 	}
 
-	conf := config.Load()
-	if conf.App.EnableSubscriptions {
+	maxDevices := 0
+	if settings.Bool(settings.EnableSubscriptions) {
 		var userplan models.UserPlan
 		userplan.UserID = userID.(uint)
 		if err := userplan.Find(); err != nil {
 			logger.Log(logger.Fields{
-				Loc:  "/server/connect/:id - Connect()",
-				Code: errors.InternalServerError.Code,
+				RequestID: requestid.Get(c),
+				Loc:       "/server/connect/:id - Connect()",
+				Code:      errors.InternalServerError.Code,
 				Extra: map[string]interface{}{
 					"UserID": userplan.UserID,
 					"Detail": "Could not find user_plan record",
@@ -258,8 +411,9 @@ func Connect(c *gin.Context) {
 		userPlanExpired := userplan.ExpiryDate.Before(time.Now())
 		if !userplan.Active || userPlanExpired {
 			logger.Log(logger.Fields{
-				Loc:  "/server/connect/:id - Connect()",
-				Code: errors.InternalServerError.Code,
+				RequestID: requestid.Get(c),
+				Loc:       "/server/connect/:id - Connect()",
+				Code:      errors.InternalServerError.Code,
 				Extra: map[string]interface{}{
 					"UserID": userplan.UserID,
 				},
@@ -268,6 +422,161 @@ func Connect(c *gin.Context) {
 			c.AbortWithStatusJSON(errors.UserPlanExpired.Status, errors.UserPlanExpired)
 			return
 		}
+		if err := userplan.RefreshQuota(time.Now()); err != nil {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "/server/connect/:id - Connect()",
+				Code:      errors.InternalServerError.Code,
+				Extra: map[string]interface{}{
+					"UserID": userplan.UserID,
+					"Detail": "Could not refresh the data quota of the user_plan",
+				},
+				Err: err.Error(),
+			})
+			c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+			return
+		}
+		if userplan.QuotaExhausted() {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "/server/connect/:id - Connect()",
+				Code:      errors.DataQuotaExceeded.Code,
+				Extra: map[string]interface{}{
+					"UserID":        userplan.UserID,
+					"DataRemaining": userplan.DataRemaining,
+				},
+				Err: errors.DataQuotaExceeded.Detail,
+			})
+			c.AbortWithStatusJSON(errors.DataQuotaExceeded.Status, errors.DataQuotaExceeded)
+			return
+		}
+		var plan models.Plan
+		plan.ID = userplan.PlanID
+		if err := plan.Find(); err != nil {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "/server/connect/:id - Connect()",
+				Code:      errors.PlanNotFound.Code,
+				Extra:     map[string]interface{}{"PlanID": userplan.PlanID},
+				Err:       err.Error(),
+			})
+			c.AbortWithStatusJSON(errors.PlanNotFound.Status, errors.PlanNotFound)
+			return
+		}
+		maxDevices = plan.MaxDevices
+	}
+
+	device := models.DeviceSession{
+		UserID:    userID.(uint),
+		DeviceID:  deviceID(c),
+		ServerID:  server.ID,
+		IP:        clientip.Get(c),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if maxDevices > 0 {
+		active, err := device.CountOthers(time.Now().Add(-deviceSessionTimeout()))
+		if err != nil {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "/server/connect/:id - Connect()",
+				Code:      errors.InternalServerError.Code,
+				Extra:     map[string]interface{}{"UserID": device.UserID},
+				Err:       err.Error(),
+			})
+			c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+			return
+		}
+		if active >= maxDevices {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "/server/connect/:id - Connect()",
+				Code:      errors.DeviceLimitReached.Code,
+				Extra: map[string]interface{}{
+					"UserID":     device.UserID,
+					"Active":     active,
+					"MaxDevices": maxDevices,
+				},
+				Err: errors.DeviceLimitReached.Detail,
+			})
+			c.AbortWithStatusJSON(errors.DeviceLimitReached.Status, errors.DeviceLimitReached)
+			return
+		}
+	}
+
+	if server.Disabled || server.Full() {
+		apiErr := errors.ServerFull
+		if server.Disabled {
+			apiErr = errors.ServerDisabled
+		}
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/server/connect/:id - Connect()",
+			Code:      apiErr.Code,
+			Extra: map[string]interface{}{
+				"ServerID": server.ID,
+				"Disabled": server.Disabled,
+				"Users":    server.Users,
+				"MaxUsers": server.MaxUsers,
+			},
+			Err: apiErr.Detail,
+		})
+		resp := gin.H{
+			"status": apiErr.Status,
+			"code":   apiErr.Code,
+			"title":  apiErr.Title,
+			"detail": apiErr.Detail,
+		}
+		if alt := suggestAlternative(server); alt != nil {
+			resp["suggested_server"] = gin.H{
+				"id":           alt.ID,
+				"country":      alt.Country,
+				"country_code": alt.CountryCode,
+			}
+		}
+		c.AbortWithStatusJSON(apiErr.Status, resp)
+		return
+	}
+
+	var cred models.ProxyCredential
+	cred.UserID = userID.(uint)
+	cred.ServerID = server.ID
+	// an existing credential is issued again on every connect so a node
+	// which has lost it, such as after a restart, gets it back
+	err := cred.Find()
+	if gorm.IsRecordNotFoundError(err) {
+		cred.MaxDevices = maxDevices
+		err = cred.Create()
+	} else if err == nil {
+		cred.MaxDevices = maxDevices
+		err = cred.Reissue()
+	}
+	if err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/server/connect/:id - Connect()",
+			Code:      errors.ProxyCredentialIssue.Code,
+			Extra: map[string]interface{}{
+				"UserID":   cred.UserID,
+				"ServerID": server.ID,
+				"Detail":   "Could not issue proxy credentials",
+			},
+			Err: err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.ProxyCredentialIssue.Status, errors.ProxyCredentialIssue)
+		return
+	}
+
+	if err := device.Touch(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/server/connect/:id - Connect()",
+			Code:      errors.InternalServerError.Code,
+			Extra: map[string]interface{}{
+				"UserID": device.UserID,
+				"Detail": "Could not record the device session",
+			},
+			Err: err.Error(),
+		})
 	}
 
 	var con models.Connection
@@ -276,8 +585,9 @@ func Connect(c *gin.Context) {
 	con.ServerCountry = server.Country
 	if err := con.Create(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/server/connect/:id - Connect()",
-			Code: errors.InternalServerError.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/server/connect/:id - Connect()",
+			Code:      errors.InternalServerError.Code,
 			Extra: map[string]interface{}{
 				"UserID": con.UserID,
 				"Detail": "Could not add connection request to db",
@@ -286,13 +596,17 @@ func Connect(c *gin.Context) {
 		})
 	}
 
+	metrics.ServerConnect(server.ID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 		"data": gin.H{
-			"username": server.Username,
-			"password": server.Password,
-			"port":     server.Port,
-			"ip":       server.IP,
+			"username":   cred.Username,
+			"password":   cred.Password,
+			"port":       server.Port,
+			"socks_port": server.SocksPort,
+			"ip":         server.IP,
+			"server_id":  server.ID,
 		},
 	})
 
@@ -305,10 +619,11 @@ func FreeConnect(c *gin.Context) {
 	server.ID = uint(serverID)
 	if err := server.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/server/:id - Server()",
-			Code:  errors.ServerNotFound.Code,
-			Extra: map[string]interface{}{"ConnID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/server/:id - Server()",
+			Code:      errors.ServerNotFound.Code,
+			Extra:     map[string]interface{}{"ConnID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.ServerNotFound.Status, errors.ServerNotFound)
 		return
@@ -326,15 +641,47 @@ func FreeConnect(c *gin.Context) {
 
 }
 
+// deviceID identifies the device making the request by its login session,
+// which the client cannot choose. Requests authenticated with an API token
+// have no session so their device is derived from the IP and user agent.
+func deviceID(c *gin.Context) string {
+	if sessionID, ok := c.Get("SessionID"); ok {
+		return fmt.Sprintf("session:%d", sessionID)
+	}
+	sum := sha256.Sum256([]byte(clientip.Get(c) + c.Request.UserAgent()))
+	return hex.EncodeToString(sum[:16])
+}
+
+// suggestAlternative finds the least loaded server that can take the user
+// instead of a full server, preferring one in the same country
+func suggestAlternative(full *models.Server) *models.Server {
+	var servers models.AllServers
+	if err := servers.FindAll(); err != nil {
+		return nil
+	}
+	servers = servers.Except(full.ID)
+	sameCountry := make(models.AllServers, 0, len(servers))
+	for _, s := range servers {
+		if s.CountryCode == full.CountryCode {
+			sameCountry = append(sameCountry, s)
+		}
+	}
+	if alt := sameCountry.LeastLoaded(); alt != nil {
+		return alt
+	}
+	return servers.LeastLoaded()
+}
+
 // Connections returns an array of all server connections
 func Connections(c *gin.Context) {
 	offset, _ := strconv.Atoi(c.Query("offset"))
 	var connections models.AllConnections
 	if err := connections.FindAll(offset); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/servers - Connections()",
-			Code: errors.InternalServerError.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/servers - Connections()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 	}
@@ -342,9 +689,10 @@ func Connections(c *gin.Context) {
 	count, err := connections.Count()
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/servers - Connections()",
-			Code: errors.InternalServerError.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/servers - Connections()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 	}
@@ -358,15 +706,234 @@ func Connections(c *gin.Context) {
 	})
 }
 
+// UsageReport is sent periodically by a proxy node with the bytes each
+// user has transferred since its last report and the load on the node
+type UsageReport struct {
+	ServerID uint `json:"server_id" binding:"required"`
+	Usage    []struct {
+		UserID    uint  `json:"user_id"`
+		BytesUp   int64 `json:"bytes_up"`
+		BytesDown int64 `json:"bytes_down"`
+	} `json:"usage"`
+	Load *struct {
+		Connections int     `json:"connections"`
+		Users       int     `json:"users"`
+		Throughput  int64   `json:"throughput"`
+		CPULoad     float64 `json:"cpu_load"`
+	} `json:"load"`
+}
+
+// ReportUsage records the data usage and load reported by a proxy node.
+// The node authenticates with the API secret stored for its server.
+func ReportUsage(c *gin.Context) {
+	var report UsageReport
+	if err := c.BindJSON(&report); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/servers/usage - ReportUsage()",
+			Code:      errors.InvalidForm.Code,
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
+		return
+	}
+
+	var server models.Server
+	server.ID = report.ServerID
+	if err := server.Find(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/servers/usage - ReportUsage()",
+			Code:      errors.ServerNotFound.Code,
+			Extra:     map[string]interface{}{"ServerID": report.ServerID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.ServerNotFound.Status, errors.ServerNotFound)
+		return
+	}
+
+	secret := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if server.APISecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(server.APISecret)) != 1 {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/servers/usage - ReportUsage()",
+			Code:      errors.NodeUnauthorised.Code,
+			Extra:     map[string]interface{}{"ServerID": report.ServerID},
+			Err:       "Node secret does not match the servers API secret",
+		})
+		c.AbortWithStatusJSON(errors.NodeUnauthorised.Status, errors.NodeUnauthorised)
+		return
+	}
+
+	if report.Load != nil {
+		server.Connections = report.Load.Connections
+		server.Users = report.Load.Users
+		server.Throughput = report.Load.Throughput
+		server.CPULoad = report.Load.CPULoad
+		server.LoadReported = time.Now()
+		if err := server.SaveLoad(); err != nil {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "/servers/usage - ReportUsage()",
+				Code:      errors.InternalServerError.Code,
+				Extra:     map[string]interface{}{"ServerID": server.ID},
+				Err:       err.Error(),
+			})
+		}
+	}
+
+	usage := make(models.AllDataUsage, len(report.Usage))
+	for i, record := range report.Usage {
+		usage[i] = models.DataUsage{
+			UserID:    record.UserID,
+			ServerID:  server.ID,
+			BytesUp:   record.BytesUp,
+			BytesDown: record.BytesDown,
+		}
+	}
+	if err := usage.Create(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/servers/usage - ReportUsage()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"ServerID": server.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	// the quota is only charged once the report is stored, so a report
+	// sent again after a failure is never charged twice
+	if settings.Bool(settings.EnableSubscriptions) {
+		for _, record := range usage {
+			chargeQuota(record.UserID, record.BytesUp+record.BytesDown)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data":   make([]string, 0),
+	})
+}
+
+// chargeQuota takes the bytes from the users plan and suspends their proxy
+// credentials on every server once a data capped plan has run out
+func chargeQuota(userID uint, bytes int64) {
+	var userplan models.UserPlan
+	userplan.UserID = userID
+	if err := userplan.Find(); err != nil {
+		return
+	}
+	if err := userplan.RefreshQuota(time.Now()); err != nil {
+		logger.Log(logger.Fields{
+			Loc:   "/servers/usage - chargeQuota()",
+			Code:  errors.InternalServerError.Code,
+			Extra: map[string]interface{}{"UserID": userID},
+			Err:   err.Error(),
+		})
+		return
+	}
+	if err := userplan.UseData(bytes); err != nil {
+		logger.Log(logger.Fields{
+			Loc:   "/servers/usage - chargeQuota()",
+			Code:  errors.InternalServerError.Code,
+			Extra: map[string]interface{}{"UserID": userID},
+			Err:   err.Error(),
+		})
+		return
+	}
+	if !userplan.QuotaExhausted() {
+		return
+	}
+
+	var creds models.AllProxyCredentials
+	if err := creds.FindAll(userID); err != nil {
+		logger.Log(logger.Fields{
+			Loc:   "/servers/usage - chargeQuota()",
+			Code:  errors.InternalServerError.Code,
+			Extra: map[string]interface{}{"UserID": userID},
+			Err:   err.Error(),
+		})
+		return
+	}
+	for _, cred := range creds {
+		if cred.Suspended {
+			continue
+		}
+		if err := cred.Suspend(); err != nil {
+			logger.Log(logger.Fields{
+				Loc:  "/servers/usage - chargeQuota()",
+				Code: errors.DataQuotaExceeded.Code,
+				Extra: map[string]interface{}{
+					"UserID":   userID,
+					"ServerID": cred.ServerID,
+					"Detail":   "Could not suspend proxy credentials",
+				},
+				Err: err.Error(),
+			})
+		}
+	}
+}
+
+// RevokeCredentials revokes every proxy credential issued to a user,
+// cutting them off from all servers until they connect again
+func RevokeCredentials(c *gin.Context) {
+	userID, _ := strconv.ParseUint(c.Param("userid"), 10, 64)
+	var creds models.AllProxyCredentials
+	if err := creds.FindAll(uint(userID)); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/servers/credentials/:userid - RevokeCredentials()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("userid")},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	for _, cred := range creds {
+		if err := cred.Delete(); err != nil {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "/servers/credentials/:userid - RevokeCredentials()",
+				Code:      errors.ProxyCredentialRevoke.Code,
+				Extra: map[string]interface{}{
+					"UserID":   cred.UserID,
+					"ServerID": cred.ServerID,
+				},
+				Err: err.Error(),
+			})
+			c.AbortWithStatusJSON(errors.ProxyCredentialRevoke.Status, errors.ProxyCredentialRevoke)
+			return
+		}
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionServerRevoke,
+		TargetType: "user",
+		TargetID:   uint(userID),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data":   make([]string, 0),
+	})
+}
+
 // AllServers returns an array of all available servers
 func AllServers(c *gin.Context) {
 	var servers models.AllServers
 
 	if err := servers.FindAll(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/servers - AllServers()",
-			Code: errors.InternalServerError.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/servers - AllServers()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 	}
@@ -374,7 +941,8 @@ func AllServers(c *gin.Context) {
 	userID, exists := c.Get("UserID")
 	if !exists {
 		logger.Log(logger.Fields{
-			Loc: "/servers - AllServers()",
+			RequestID: requestid.Get(c),
+			Loc:       "/servers - AllServers()",
 			Extra: map[string]interface{}{
 				"UserID": userID,
 				"Detail": "User ID does not exist in the context",
@@ -388,8 +956,9 @@ func AllServers(c *gin.Context) {
 	user.ID = userID.(uint)
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/servers - AllServers()",
-			Code: errors.UserNotFound.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/servers - AllServers()",
+			Code:      errors.UserNotFound.Code,
 			Extra: map[string]interface{}{
 				"UserID": userID,
 				"Detail": errors.UserNotFound.Detail,
@@ -400,13 +969,30 @@ func AllServers(c *gin.Context) {
 		return
 	}
 
-	if user.Type != models.UserTypeAdmin {
-		// dont send username and passwords
+	role := permissions.RoleOf(user)
+	// disabled servers are only shown to admins
+	if role == "" {
+		hideUnhealthy := config.Load().App.HideUnhealthyServers
+		visible := make(models.AllServers, 0, len(servers))
+		for _, s := range servers {
+			if s.Disabled || hideUnhealthy && s.Unhealthy() {
+				continue
+			}
+			visible = append(visible, s)
+		}
+		servers = visible
+	}
+
+	// dont send username and passwords unless the admin may see them
+	if !permissions.Has(role, permissions.ServersCredentials) {
 		for i, s := range servers {
 			s.Username = ""
 			s.Password = ""
 			s.IP = ""
 			s.Port = 0000
+			s.SocksPort = 0
+			s.APIPort = 0
+			s.APISecret = ""
 			servers[i] = s
 		}
 	}
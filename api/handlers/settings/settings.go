@@ -1,9 +1,12 @@
 package settings
 
 import (
-	"eirevpn/api/config"
+	"eirevpn/api/audit"
 	"eirevpn/api/errors"
 	"eirevpn/api/logger"
+	"eirevpn/api/models"
+	"eirevpn/api/requestid"
+	"eirevpn/api/settings"
 	"net/http"
 	"strconv"
 
@@ -11,45 +14,79 @@ import (
 )
 
 type SettingsFields struct {
-	EnableCSRF          string   `json:"enableCsrf" binding:"required"`
-	EnableSubscriptions string   `json:"enableSubscriptions" binding:"required"`
-	EnableAuth          string   `json:"enableAuth" binding:"required"`
-	IntegrationActive   string   `json:"enableStripe" binding:"required"`
-	AllowedOrigins      []string `json:"allowedOrigins" binding:"required"`
+	EnableCSRF          string          `json:"enableCsrf" binding:"required"`
+	EnableSubscriptions string          `json:"enableSubscriptions" binding:"required"`
+	EnableAuth          string          `json:"enableAuth" binding:"required"`
+	IntegrationActive   string          `json:"enableStripe" binding:"required"`
+	AdminTwoFactor      string          `json:"requireAdminTwoFactor" binding:"required"`
+	AllowedOrigins      []string        `json:"allowedOrigins" binding:"required"`
+	Flags               map[string]bool `json:"flags"`
 }
 
-// UpdateSettings updates the config.yaml
+// UpdateSettings stores the settings and any feature flags given in the
+// database, where every API instance picks them up
 func UpdateSettings(c *gin.Context) {
 
 	settingsUpdates := SettingsFields{}
 	if err := c.BindJSON(&settingsUpdates); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/settings/update - UpdateSettings()",
-			Code: errors.SettingsUpdateFailed.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/settings/update - UpdateSettings()",
+			Code:      errors.SettingsUpdateFailed.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.SettingsUpdateFailed.Status, errors.SettingsUpdateFailed)
 		return
 	}
 
-	conf := config.Load()
-	conf.App.EnableCSRF = settingsUpdates.EnableCSRF == "true"
-	conf.App.EnableSubscriptions = settingsUpdates.EnableSubscriptions == "true"
-	conf.App.EnableAuth = settingsUpdates.EnableAuth == "true"
-	conf.Stripe.IntegrationActive = settingsUpdates.IntegrationActive == "true"
-	conf.App.AllowedOrigins = settingsUpdates.AllowedOrigins
+	updates := map[string]interface{}{
+		settings.EnableCSRF:              settingsUpdates.EnableCSRF == "true",
+		settings.EnableSubscriptions:     settingsUpdates.EnableSubscriptions == "true",
+		settings.EnableAuth:              settingsUpdates.EnableAuth == "true",
+		settings.StripeIntegrationActive: settingsUpdates.IntegrationActive == "true",
+		settings.AdminTwoFactorRequired:  settingsUpdates.AdminTwoFactor == "true",
+		settings.AllowedOrigins:          settingsUpdates.AllowedOrigins,
+	}
+	for name, on := range settingsUpdates.Flags {
+		updates[name] = on
+	}
+	for key, value := range updates {
+		if err := settings.Validate(key, value); err != nil {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "/settings/update - UpdateSettings()",
+				Code:      errors.InvalidForm.Code,
+				Extra:     map[string]interface{}{"Key": key},
+				Err:       err.Error(),
+			})
+			c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
+			return
+		}
+	}
 
-	if err := conf.SaveConfig(); err != nil {
+	before := current()
+	var actorID uint
+	if userID, ok := c.Get("UserID"); ok {
+		actorID, _ = userID.(uint)
+	}
+	if _, err := settings.SetAll(updates, actorID); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/settings/update - UpdateSettings()",
-			Code:  errors.SettingsUpdateFailed.Code,
-			Extra: map[string]interface{}{"PlanID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/settings/update - UpdateSettings()",
+			Code:      errors.SettingsUpdateFailed.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.SettingsUpdateFailed.Status, errors.SettingsUpdateFailed)
 		return
-
 	}
+
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionSettingsUpdate,
+		TargetType: "settings",
+		Before:     before,
+		After:      current(),
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 	})
@@ -57,15 +94,7 @@ func UpdateSettings(c *gin.Context) {
 
 // Settings fetches the settings
 func Settings(c *gin.Context) {
-	conf := config.Load()
-
-	s := SettingsFields{
-		EnableCSRF:          strconv.FormatBool(conf.App.EnableCSRF),
-		EnableSubscriptions: strconv.FormatBool(conf.App.EnableSubscriptions),
-		EnableAuth:          strconv.FormatBool(conf.App.EnableAuth),
-		IntegrationActive:   strconv.FormatBool(conf.Stripe.IntegrationActive),
-		AllowedOrigins:      conf.App.AllowedOrigins,
-	}
+	s := current()
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
@@ -75,3 +104,58 @@ func Settings(c *gin.Context) {
 	})
 
 }
+
+// SettingsHistory fetches the changes made to the settings, newest first.
+// They can be filtered to a single setting or flag with key.
+func SettingsHistory(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	key := c.Query("key")
+
+	var changes models.AllSettingChanges
+	if err := changes.FindAll(offset, key); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/settings/history - SettingsHistory()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	count, err := changes.Count(key)
+	if err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/settings/history - SettingsHistory()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"data": gin.H{
+			"count":   count,
+			"changes": changes,
+		},
+	})
+}
+
+func current() SettingsFields {
+	flags := map[string]bool{}
+	for _, name := range settings.Flags() {
+		flags[name] = settings.Bool(name)
+	}
+	return SettingsFields{
+		EnableCSRF:          strconv.FormatBool(settings.Bool(settings.EnableCSRF)),
+		EnableSubscriptions: strconv.FormatBool(settings.Bool(settings.EnableSubscriptions)),
+		EnableAuth:          strconv.FormatBool(settings.Bool(settings.EnableAuth)),
+		IntegrationActive:   strconv.FormatBool(settings.Bool(settings.StripeIntegrationActive)),
+		AdminTwoFactor:      strconv.FormatBool(settings.Bool(settings.AdminTwoFactorRequired)),
+		AllowedOrigins:      settings.Strings(settings.AllowedOrigins),
+		Flags:               flags,
+	}
+}
@@ -0,0 +1,124 @@
+package user
+
+import (
+	"eirevpn/api/audit"
+	"eirevpn/api/errors"
+	"eirevpn/api/lockout"
+	"eirevpn/api/logger"
+	"eirevpn/api/models"
+	"eirevpn/api/requestid"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkLockout aborts the request if too many attempts have failed
+// recently for the account or IP address, telling the caller how long
+// to wait before trying again
+func checkLockout(c *gin.Context, attempt lockout.Attempt, loc string) bool {
+	wait := lockout.Check(attempt)
+	if wait <= 0 {
+		return true
+	}
+	logger.Log(logger.Fields{
+		RequestID: requestid.Get(c),
+		Loc:       loc,
+		Code:      errors.AccountLocked.Code,
+		Extra: map[string]interface{}{
+			"Account": attempt.Account,
+			"IP":      attempt.IP,
+			"Wait":    wait.String(),
+		},
+		Err: errors.AccountLocked.Detail,
+	})
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.AbortWithStatusJSON(errors.AccountLocked.Status, errors.AccountLocked)
+	return false
+}
+
+// Lockouts fetches the accounts and IP addresses with recent failed
+// login or password reset attempts
+func Lockouts(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	var lockouts models.AllLockouts
+	if err := lockouts.FindAll(offset); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/lockouts - Lockouts()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	count, err := lockouts.Count()
+	if err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/lockouts - Lockouts()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"data": gin.H{
+			"count":    count,
+			"lockouts": lockouts,
+		},
+	})
+}
+
+// ClearLockout removes a lockout, letting the account or IP address
+// try again straight away
+func ClearLockout(c *gin.Context) {
+	lockoutID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var l models.Lockout
+	l.ID = uint(lockoutID)
+	if l.ID == 0 {
+		c.AbortWithStatusJSON(errors.LockoutNotFound.Status, errors.LockoutNotFound)
+		return
+	}
+	if err := l.Find(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/lockouts/:id - ClearLockout()",
+			Code:      errors.LockoutNotFound.Code,
+			Extra:     map[string]interface{}{"LockoutID": c.Param("id")},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.LockoutNotFound.Status, errors.LockoutNotFound)
+		return
+	}
+
+	if err := l.Delete(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/lockouts/:id - ClearLockout()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"LockoutID": l.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionLockoutClear,
+		TargetType: "lockout",
+		TargetID:   l.ID,
+		Before:     l,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data":   make([]string, 0),
+	})
+}
@@ -1,11 +1,16 @@
 package user
 
 import (
+	"eirevpn/api/audit"
 	"eirevpn/api/errors"
 	"eirevpn/api/integrations/sendgrid"
+	"eirevpn/api/lockout"
 	"eirevpn/api/logger"
 	"eirevpn/api/models"
+	"eirevpn/api/requestid"
+	"eirevpn/api/util/clientip"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -21,22 +26,35 @@ func ForgotPasswordToken(c *gin.Context) {
 	u := User{}
 	if err := c.BindJSON(&u); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/forgot_pass - ForgotPasswordToken()",
-			Code: errors.BindingFailed.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/forgot_pass - ForgotPasswordToken()",
+			Code:      errors.BindingFailed.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
 	}
 
+	// every request counts towards the limit as each one sends an email
+	attempt := lockout.Attempt{
+		Scope:   models.LockoutScopeReset,
+		Account: strings.ToLower(u.Email),
+		IP:      clientip.Get(c),
+	}
+	if !checkLockout(c, attempt, "/forgot_pass - ForgotPasswordToken()") {
+		return
+	}
+	lockout.Fail(attempt, nil)
+
 	var user models.User
 	user.Email = u.Email
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/forgot_pass - ForgotPasswordToken()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"Email": u.Email},
-			Err:   errors.UserNotFound.Detail,
+			RequestID: requestid.Get(c),
+			Loc:       "/forgot_pass - ForgotPasswordToken()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"Email": u.Email},
+			Err:       errors.UserNotFound.Detail,
 		})
 		// return with ok status as we dont want to leak which emails
 		// have an account
@@ -48,10 +66,11 @@ func ForgotPasswordToken(c *gin.Context) {
 	fp.UserID = user.ID
 	if err := fp.Create(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/forgot_pass - ForgotPasswordToken()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": user.ID, "Detail": "Error creating forgot password object"},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/forgot_pass - ForgotPasswordToken()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID, "Detail": "Error creating forgot password object"},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -59,10 +78,11 @@ func ForgotPasswordToken(c *gin.Context) {
 
 	if err := sendgrid.Send().ForgotPassword(u.Email, fp.Token); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/forgot_pass - ForgotPasswordToken()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"Email": u.Email, "Detail": "Error sending support email"},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/forgot_pass - ForgotPasswordToken()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"Email": u.Email, "Detail": "Error sending support email"},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -83,15 +103,24 @@ func UpdatePassword(c *gin.Context) {
 
 	token := c.Param("token")
 
+	// tokens cannot be tied to an account until they are found, so
+	// guesses are only limited by IP address
+	attempt := lockout.Attempt{Scope: models.LockoutScopeReset, IP: clientip.Get(c)}
+	if !checkLockout(c, attempt, "/forgot_pass - UpdatePassword()") {
+		return
+	}
+
 	var fp models.ForgotPassword
 	fp.Token = token
 	if err := fp.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/forgot_pass - UpdatePassword()",
-			Code:  errors.TokenNotFound.Code,
-			Extra: map[string]interface{}{"Token": c.Param("token")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/forgot_pass - UpdatePassword()",
+			Code:      errors.TokenNotFound.Code,
+			Extra:     map[string]interface{}{"Token": c.Param("token")},
+			Err:       err.Error(),
 		})
+		lockout.Fail(attempt, nil)
 		c.AbortWithStatusJSON(errors.TokenNotFound.Status, errors.TokenNotFound)
 		return
 	}
@@ -99,9 +128,10 @@ func UpdatePassword(c *gin.Context) {
 	u := User{}
 	if err := c.BindJSON(&u); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/forgot_pass - UpdatePassword()",
-			Code: errors.BindingFailed.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/forgot_pass - UpdatePassword()",
+			Code:      errors.BindingFailed.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -111,10 +141,11 @@ func UpdatePassword(c *gin.Context) {
 	user.ID = fp.UserID
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/forgot_pass - UpdatePassword()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"UserID": fp.UserID},
-			Err:   errors.UserNotFound.Detail,
+			RequestID: requestid.Get(c),
+			Loc:       "/forgot_pass - UpdatePassword()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": fp.UserID},
+			Err:       errors.UserNotFound.Detail,
 		})
 		c.JSON(errors.UserNotFound.Status, errors.UserNotFound)
 		return
@@ -123,10 +154,11 @@ func UpdatePassword(c *gin.Context) {
 	pw, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/forgot_pass - UpdatePassword()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": user.ID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/forgot_pass - UpdatePassword()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -135,10 +167,11 @@ func UpdatePassword(c *gin.Context) {
 	user.Password = string(pw)
 	if err := user.Save(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/forgot_pass - UpdatePassword()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": user.ID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/forgot_pass - UpdatePassword()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -146,15 +179,23 @@ func UpdatePassword(c *gin.Context) {
 
 	if err := fp.Delete(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/forgot_pass - UpdatePassword()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"UserID": user.ID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/forgot_pass - UpdatePassword()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.UserNotFound.Status, errors.UserNotFound)
 		return
 	}
 
+	audit.Record(c, audit.Entry{
+		ActorID:    user.ID,
+		Action:     models.AuditActionPasswordReset,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 	})
@@ -167,7 +208,8 @@ func ChangePassword(c *gin.Context) {
 	cookieUserID, exists := c.Get("UserID")
 	if !exists {
 		logger.Log(logger.Fields{
-			Loc: "/user/private/changepassword - ChangePassword()",
+			RequestID: requestid.Get(c),
+			Loc:       "/user/private/changepassword - ChangePassword()",
 			Extra: map[string]interface{}{
 				"UserID": cookieUserID,
 				"Detail": "User ID does not exist in the context",
@@ -181,10 +223,11 @@ func ChangePassword(c *gin.Context) {
 	user.ID = cookieUserID.(uint)
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user/private/changepassword - ChangePassword()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"UserID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user/private/changepassword - ChangePassword()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.UserNotFound.Status, errors.UserNotFound)
 		return
@@ -199,10 +242,11 @@ func ChangePassword(c *gin.Context) {
 
 	if err := c.BindJSON(&changePassword); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user/private/changepassword - ChangePassword()",
-			Code:  errors.InvalidForm.Code,
-			Extra: map[string]interface{}{"UserID": cookieUserID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user/private/changepassword - ChangePassword()",
+			Code:      errors.InvalidForm.Code,
+			Extra:     map[string]interface{}{"UserID": cookieUserID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
 		return
@@ -210,10 +254,11 @@ func ChangePassword(c *gin.Context) {
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(changePassword.CurrentPassword)); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user/private/changepassword - ChangePassword()",
-			Code:  errors.WrongPassword.Code,
-			Extra: map[string]interface{}{"UserID": cookieUserID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user/private/changepassword - ChangePassword()",
+			Code:      errors.WrongPassword.Code,
+			Extra:     map[string]interface{}{"UserID": cookieUserID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.WrongPassword.Status, errors.WrongPassword)
 		return
@@ -222,10 +267,11 @@ func ChangePassword(c *gin.Context) {
 	pw, err := bcrypt.GenerateFromPassword([]byte(changePassword.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user/private/changepassword - ChangePassword()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": cookieUserID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user/private/changepassword - ChangePassword()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": cookieUserID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -234,15 +280,22 @@ func ChangePassword(c *gin.Context) {
 	user.Password = string(pw)
 	if err := user.Save(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user/private/changepassword - ChangePassword()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": user.ID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user/private/changepassword - ChangePassword()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
 	}
 
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionPasswordChange,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 		"errors": make([]string, 0),
@@ -0,0 +1,115 @@
+package user
+
+import (
+	"eirevpn/api/audit"
+	"eirevpn/api/errors"
+	"eirevpn/api/logger"
+	"eirevpn/api/models"
+	"eirevpn/api/permissions"
+	"eirevpn/api/requestid"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permissions fetches the role of the logged in user and the
+// permissions it grants them
+func Permissions(c *gin.Context) {
+	user, ok := contextUser(c, "/user/permissions - Permissions()")
+	if !ok {
+		return
+	}
+
+	role := permissions.RoleOf(*user)
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data": gin.H{
+			"role":        role,
+			"permissions": permissions.Of(role),
+		},
+	})
+}
+
+// UpdateRole assigns a role to a user, making them an admin. An empty
+// role removes their admin access.
+func UpdateRole(c *gin.Context) {
+	userID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var user models.User
+	user.ID = uint(userID)
+	if err := user.Find(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/users/role/:id - UpdateRole()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.UserNotFound.Status, errors.UserNotFound)
+		return
+	}
+
+	type RoleUpdate struct {
+		Role models.UserRole `json:"role"`
+	}
+	update := RoleUpdate{}
+	if err := c.BindJSON(&update); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/users/role/:id - UpdateRole()",
+			Code:      errors.InvalidForm.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
+		return
+	}
+
+	// stop admins removing their own access, which could leave no one
+	// able to assign roles
+	cookieUserID, _ := c.Get("UserID")
+	if update.Role != "" && !permissions.ValidRole(update.Role) || cookieUserID == user.ID {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/users/role/:id - UpdateRole()",
+			Code:      errors.InvalidRole.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID, "Role": update.Role},
+			Err:       errors.InvalidRole.Detail,
+		})
+		c.AbortWithStatusJSON(errors.InvalidRole.Status, errors.InvalidRole)
+		return
+	}
+
+	before := user
+	user.Role = update.Role
+	user.Type = models.UserTypeAdmin
+	if update.Role == "" {
+		user.Type = models.UserTypeNormal
+	}
+	if err := user.Save(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/users/role/:id - UpdateRole()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionUserRole,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Before:     before,
+		After:      user,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data":   make([]string, 0),
+	})
+}
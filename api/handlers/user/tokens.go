@@ -0,0 +1,190 @@
+package user
+
+import (
+	"eirevpn/api/audit"
+	"eirevpn/api/errors"
+	"eirevpn/api/logger"
+	"eirevpn/api/models"
+	"eirevpn/api/requestid"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APITokens fetches the API tokens of the logged in user
+func APITokens(c *gin.Context) {
+	user, ok := contextUser(c, "/user/tokens - APITokens()")
+	if !ok {
+		return
+	}
+
+	var tokens models.AllAPITokens
+	if err := tokens.FindAll(user.ID); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/tokens - APITokens()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data": gin.H{
+			"tokens": tokens,
+		},
+	})
+}
+
+// CreateAPIToken mints a new API token for the logged in user. The token
+// is only returned in this response.
+func CreateAPIToken(c *gin.Context) {
+	// tokens must be minted from a logged in session so a leaked token
+	// cannot be used to create more
+	if _, usingToken := c.Get("APITokenID"); usingToken {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/tokens - CreateAPIToken()",
+			Code:      errors.APITokenScope.Code,
+			Err:       "API tokens cannot be used to create API tokens",
+		})
+		c.AbortWithStatusJSON(errors.APITokenScope.Status, errors.APITokenScope)
+		return
+	}
+
+	user, ok := contextUser(c, "/user/tokens - CreateAPIToken()")
+	if !ok {
+		return
+	}
+
+	type TokenRequest struct {
+		Name          string   `json:"name" binding:"required"`
+		Scopes        []string `json:"scopes" binding:"required"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	tr := TokenRequest{}
+	if err := c.BindJSON(&tr); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/tokens - CreateAPIToken()",
+			Code:      errors.InvalidForm.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
+		return
+	}
+
+	for _, s := range tr.Scopes {
+		scope := models.APITokenScope(s)
+		valid := scope == models.APITokenScopeRead || scope == models.APITokenScopeWrite || scope == models.APITokenScopeAdmin
+		if !valid || (scope == models.APITokenScopeAdmin && user.Type != models.UserTypeAdmin) {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "/user/tokens - CreateAPIToken()",
+				Code:      errors.InvalidForm.Code,
+				Extra:     map[string]interface{}{"UserID": user.ID, "Scope": s},
+				Err:       "Scope is unknown or not allowed for the user",
+			})
+			c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
+			return
+		}
+	}
+
+	token := models.APIToken{
+		Name:   tr.Name,
+		Scopes: strings.Join(tr.Scopes, ","),
+	}
+	if tr.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, tr.ExpiresInDays)
+		token.ExpiresAt = &expires
+	}
+	plain, err := token.New(user.ID)
+	if err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/tokens - CreateAPIToken()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionAPITokenCreate,
+		TargetType: "api_token",
+		TargetID:   token.ID,
+		After:      token,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data": gin.H{
+			"token":     plain,
+			"api_token": token,
+		},
+	})
+}
+
+// DeleteAPIToken revokes one of the logged in users API tokens
+func DeleteAPIToken(c *gin.Context) {
+	user, ok := contextUser(c, "/user/tokens/:id - DeleteAPIToken()")
+	if !ok {
+		return
+	}
+
+	tokenID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	var token models.APIToken
+	token.ID = uint(tokenID)
+	token.UserID = user.ID
+	if token.ID == 0 {
+		c.AbortWithStatusJSON(errors.APITokenNotFound.Status, errors.APITokenNotFound)
+		return
+	}
+	if err := token.Find(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/tokens/:id - DeleteAPIToken()",
+			Code:      errors.APITokenNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID, "APITokenID": c.Param("id")},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.APITokenNotFound.Status, errors.APITokenNotFound)
+		return
+	}
+
+	if err := token.Delete(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/tokens/:id - DeleteAPIToken()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID, "APITokenID": token.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionAPITokenDelete,
+		TargetType: "api_token",
+		TargetID:   token.ID,
+		Before:     token,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data":   make([]string, 0),
+	})
+}
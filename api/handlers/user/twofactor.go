@@ -0,0 +1,453 @@
+package user
+
+import (
+	"eirevpn/api/audit"
+	"eirevpn/api/errors"
+	"eirevpn/api/lockout"
+	"eirevpn/api/logger"
+	"eirevpn/api/models"
+	"eirevpn/api/requestid"
+	"eirevpn/api/settings"
+	"eirevpn/api/util/clientip"
+	"eirevpn/api/util/jwt"
+	"eirevpn/api/util/totp"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// totpIssuer is the name authenticator apps show next to the users code
+const totpIssuer = "EireVPN"
+
+// secondFactor is the code a user enters after their password, either
+// from their authenticator app or one of their recovery codes
+type secondFactor struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// verify checks the code, falling back to the recovery code if no
+// authenticator code was given
+func (sf secondFactor) verify(user *models.User) bool {
+	if sf.Code != "" {
+		return user.ValidateTOTP(sf.Code)
+	}
+	if sf.RecoveryCode != "" {
+		var rc models.RecoveryCode
+		return rc.Use(user.ID, sf.RecoveryCode) == nil
+	}
+	return false
+}
+
+// twoFactorRequired reports whether the user must have two factor
+// authentication enabled
+func twoFactorRequired(user *models.User) bool {
+	return user.Type == models.UserTypeAdmin && settings.Bool(settings.AdminTwoFactorRequired)
+}
+
+// contextUser fetches the logged in user, aborting the request if they
+// cannot be found
+func contextUser(c *gin.Context, loc string) (*models.User, bool) {
+	userID, exists := c.Get("UserID")
+	if !exists {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       loc,
+			Extra: map[string]interface{}{
+				"UserID": userID,
+				"Detail": "User ID does not exist in the context",
+			},
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return nil, false
+	}
+	var user models.User
+	user.ID = userID.(uint)
+	if err := user.Find(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       loc,
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": userID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.UserNotFound.Status, errors.UserNotFound)
+		return nil, false
+	}
+	return &user, true
+}
+
+// LoginTwoFactor completes a login for users with two factor authentication
+// enabled, exchanging the token returned by LoginUser and a code for a session
+func LoginTwoFactor(c *gin.Context) {
+	type TwoFactorLogin struct {
+		Token string `json:"token" binding:"required"`
+		secondFactor
+	}
+	login := TwoFactorLogin{}
+	if err := c.BindJSON(&login); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/login/2fa - LoginTwoFactor()",
+			Code:      errors.InvalidForm.Code,
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
+		return
+	}
+
+	userID, err := jwt.ValidateTwoFactorToken(login.Token)
+	if err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/login/2fa - LoginTwoFactor()",
+			Code:      errors.TwoFactorTokenInvalid.Code,
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.TwoFactorTokenInvalid.Status, errors.TwoFactorTokenInvalid)
+		return
+	}
+
+	var user models.User
+	user.ID = userID
+	if err := user.Find(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/login/2fa - LoginTwoFactor()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": userID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.UserNotFound.Status, errors.UserNotFound)
+		return
+	}
+
+	if !user.TwoFactorEnabled {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/login/2fa - LoginTwoFactor()",
+			Code:      errors.TwoFactorNotEnabled.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       errors.TwoFactorNotEnabled.Detail,
+		})
+		c.AbortWithStatusJSON(errors.TwoFactorNotEnabled.Status, errors.TwoFactorNotEnabled)
+		return
+	}
+
+	attempt := lockout.Attempt{
+		Scope:   models.LockoutScopeLogin,
+		Account: strings.ToLower(user.Email),
+		IP:      clientip.Get(c),
+	}
+	if !checkLockout(c, attempt, "/user/login/2fa - LoginTwoFactor()") {
+		return
+	}
+
+	if !login.verify(&user) {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/login/2fa - LoginTwoFactor()",
+			Code:      errors.TwoFactorCodeInvalid.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       errors.TwoFactorCodeInvalid.Detail,
+		})
+		lockout.Fail(attempt, &user)
+		audit.Record(c, audit.Entry{
+			Action:     models.AuditActionLoginFailed,
+			TargetType: "user",
+			TargetID:   user.ID,
+			After:      gin.H{"second_factor": true},
+		})
+		c.AbortWithStatusJSON(errors.TwoFactorCodeInvalid.Status, errors.TwoFactorCodeInvalid)
+		return
+	}
+
+	lockout.Succeed(attempt)
+	startSession(c, user)
+}
+
+// TwoFactor fetches the state of the users two factor authentication
+func TwoFactor(c *gin.Context) {
+	user, ok := contextUser(c, "/user/2fa - TwoFactor()")
+	if !ok {
+		return
+	}
+
+	var codes models.AllRecoveryCodes
+	remaining, err := codes.CountUnused(user.ID)
+	if err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/2fa - TwoFactor()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data": gin.H{
+			"enabled":                  user.TwoFactorEnabled,
+			"required":                 twoFactorRequired(user),
+			"recovery_codes_remaining": remaining,
+		},
+	})
+}
+
+// SetupTwoFactor generates a new TOTP secret for the user. Two factor
+// authentication is not turned on until a code from it is verified.
+func SetupTwoFactor(c *gin.Context) {
+	user, ok := contextUser(c, "/user/2fa/setup - SetupTwoFactor()")
+	if !ok {
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/2fa/setup - SetupTwoFactor()",
+			Code:      errors.TwoFactorAlreadyEnabled.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       errors.TwoFactorAlreadyEnabled.Detail,
+		})
+		c.AbortWithStatusJSON(errors.TwoFactorAlreadyEnabled.Status, errors.TwoFactorAlreadyEnabled)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/2fa/setup - SetupTwoFactor()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	if err := user.Save(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/2fa/setup - SetupTwoFactor()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data": gin.H{
+			"secret":           secret,
+			"provisioning_uri": totp.ProvisioningURI(secret, user.Email, totpIssuer),
+		},
+	})
+}
+
+// EnableTwoFactor turns on two factor authentication once the user has
+// entered a code from their authenticator, and returns their recovery codes
+func EnableTwoFactor(c *gin.Context) {
+	user, ok := contextUser(c, "/user/2fa/enable - EnableTwoFactor()")
+	if !ok {
+		return
+	}
+
+	sf := secondFactor{}
+	if err := c.BindJSON(&sf); err != nil || sf.Code == "" {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/2fa/enable - EnableTwoFactor()",
+			Code:      errors.InvalidForm.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+		})
+		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
+		return
+	}
+
+	if user.TwoFactorEnabled {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/2fa/enable - EnableTwoFactor()",
+			Code:      errors.TwoFactorAlreadyEnabled.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       errors.TwoFactorAlreadyEnabled.Detail,
+		})
+		c.AbortWithStatusJSON(errors.TwoFactorAlreadyEnabled.Status, errors.TwoFactorAlreadyEnabled)
+		return
+	}
+
+	if !user.ValidateTOTP(sf.Code) {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/2fa/enable - EnableTwoFactor()",
+			Code:      errors.TwoFactorCodeInvalid.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       errors.TwoFactorCodeInvalid.Detail,
+		})
+		c.AbortWithStatusJSON(errors.TwoFactorCodeInvalid.Status, errors.TwoFactorCodeInvalid)
+		return
+	}
+
+	user.TwoFactorEnabled = true
+	if err := user.Save(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/2fa/enable - EnableTwoFactor()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionTwoFactorEnable,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
+
+	recoveryCodes(c, user, "/user/2fa/enable - EnableTwoFactor()")
+}
+
+// RegenerateRecoveryCodes replaces the users recovery codes, for example
+// once they have used most of them
+func RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := contextUser(c, "/user/2fa/recovery_codes - RegenerateRecoveryCodes()")
+	if !ok {
+		return
+	}
+	if !checkSecondFactor(c, user, "/user/2fa/recovery_codes - RegenerateRecoveryCodes()") {
+		return
+	}
+	recoveryCodes(c, user, "/user/2fa/recovery_codes - RegenerateRecoveryCodes()")
+}
+
+// DisableTwoFactor turns off two factor authentication after checking
+// a code, unless the user is required to keep it on
+func DisableTwoFactor(c *gin.Context) {
+	user, ok := contextUser(c, "/user/2fa/disable - DisableTwoFactor()")
+	if !ok {
+		return
+	}
+
+	if twoFactorRequired(user) {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/2fa/disable - DisableTwoFactor()",
+			Code:      errors.TwoFactorRequired.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       "Admins may not disable two factor authentication",
+		})
+		c.AbortWithStatusJSON(errors.TwoFactorRequired.Status, errors.TwoFactorRequired)
+		return
+	}
+
+	if !checkSecondFactor(c, user, "/user/2fa/disable - DisableTwoFactor()") {
+		return
+	}
+
+	if err := user.DisableTwoFactor(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/2fa/disable - DisableTwoFactor()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionTwoFactorDisable,
+		TargetType: "user",
+		TargetID:   user.ID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data":   make([]string, 0),
+	})
+}
+
+// checkSecondFactor binds and verifies a code from a user who already has
+// two factor authentication enabled, aborting the request if it is wrong
+func checkSecondFactor(c *gin.Context, user *models.User, loc string) bool {
+	if !user.TwoFactorEnabled {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       loc,
+			Code:      errors.TwoFactorNotEnabled.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       errors.TwoFactorNotEnabled.Detail,
+		})
+		c.AbortWithStatusJSON(errors.TwoFactorNotEnabled.Status, errors.TwoFactorNotEnabled)
+		return false
+	}
+
+	sf := secondFactor{}
+	if err := c.BindJSON(&sf); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       loc,
+			Code:      errors.InvalidForm.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
+		return false
+	}
+
+	if !sf.verify(user) {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       loc,
+			Code:      errors.TwoFactorCodeInvalid.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       errors.TwoFactorCodeInvalid.Detail,
+		})
+		c.AbortWithStatusJSON(errors.TwoFactorCodeInvalid.Status, errors.TwoFactorCodeInvalid)
+		return false
+	}
+	return true
+}
+
+// recoveryCodes issues the user a fresh set of recovery codes and
+// responds with them
+func recoveryCodes(c *gin.Context, user *models.User, loc string) {
+	var rc models.AllRecoveryCodes
+	codes, err := rc.Generate(user.ID)
+	if err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       loc,
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
@@ -1,16 +1,22 @@
 package user
 
 import (
+	"eirevpn/api/audit"
 	cfg "eirevpn/api/config"
 	"eirevpn/api/errors"
 	"eirevpn/api/integrations/sendgrid"
 	"eirevpn/api/integrations/stripe"
+	"eirevpn/api/lockout"
 	"eirevpn/api/logger"
 	"eirevpn/api/models"
+	"eirevpn/api/permissions"
+	"eirevpn/api/requestid"
+	"eirevpn/api/util/clientip"
 	"eirevpn/api/util/jwt"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	stripego "github.com/stripe/stripe-go"
@@ -23,7 +29,8 @@ func checkPrivilege(c *gin.Context, queryUserID uint) *errors.APIError {
 	cookieUserID, exists := c.Get("UserID")
 	if !exists {
 		logger.Log(logger.Fields{
-			Loc: "/user - checkPrivilege()",
+			RequestID: requestid.Get(c),
+			Loc:       "/user - checkPrivilege()",
 			Extra: map[string]interface{}{
 				"UserID": cookieUserID,
 				"Detail": "User ID does not exist in the context",
@@ -36,22 +43,25 @@ func checkPrivilege(c *gin.Context, queryUserID uint) *errors.APIError {
 	user.ID = cookieUserID.(uint)
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user - checkPrivilege()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"UserID": cookieUserID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user - checkPrivilege()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": cookieUserID},
+			Err:       err.Error(),
 		})
 		return &errors.UserNotFound
 	}
 
-	if user.Type == models.UserTypeAdmin {
+	// admins who can view users may access any users details
+	if permissions.Has(permissions.RoleOf(user), permissions.UsersRead) {
 		return nil
 	}
 
 	if user.ID != queryUserID {
 		logger.Log(logger.Fields{
-			Loc:  "/user - checkPrivilege()",
-			Code: errors.ProtectedRouted.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user - checkPrivilege()",
+			Code:      errors.ProtectedRouted.Code,
 			Extra: map[string]interface{}{
 				"CookieUserID": cookieUserID,
 				"QueryUserID":  queryUserID,
@@ -77,10 +87,11 @@ func User(c *gin.Context) {
 	user.ID = uint(userID)
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user/:id - User()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"UserID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user/:id - User()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.UserNotFound.Status, errors.UserNotFound)
 		return
@@ -103,6 +114,189 @@ func User(c *gin.Context) {
 
 }
 
+// Usage returns the data the user has transferred in their current
+// billing period. Users without a plan are given the calendar month.
+func Usage(c *gin.Context) {
+	userID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err := checkPrivilege(c, uint(userID)); err != nil {
+		c.AbortWithStatusJSON(err.Status, err)
+		return
+	}
+
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	end := start.AddDate(0, 1, 0)
+	var userPlan models.UserPlan
+	userPlan.UserID = uint(userID)
+	if err := userPlan.Find(); err == nil && !userPlan.StartDate.IsZero() {
+		start, end = userPlan.CurrentPeriod(now)
+		if err := userPlan.RefreshQuota(now); err != nil {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "/user/usage/:id - Usage()",
+				Code:      errors.InternalServerError.Code,
+				Extra:     map[string]interface{}{"UserID": c.Param("id")},
+				Err:       err.Error(),
+			})
+		}
+	}
+
+	var total models.UsageTotal
+	if err := total.Find(uint(userID), start, end); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/usage/:id - Usage()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data": gin.H{
+			"period_start":   start,
+			"period_end":     end,
+			"bytes_up":       total.BytesUp,
+			"bytes_down":     total.BytesDown,
+			"data_allowance": userPlan.DataAllowance,
+			"data_remaining": userPlan.DataRemaining,
+		},
+	})
+}
+
+// Sessions lists the devices the user is logged in on
+func Sessions(c *gin.Context) {
+	userID, exists := c.Get("UserID")
+	if !exists {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/sessions - Sessions()",
+			Extra: map[string]interface{}{
+				"UserID": userID,
+				"Detail": "User ID does not exist in the context",
+			},
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	var sessions models.AllUserAppSessions
+	if err := sessions.FindAll(userID.(uint)); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/sessions - Sessions()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": userID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	currentID, _ := c.Get("SessionID")
+	type session struct {
+		models.UserAppSession
+		Current bool `json:"current"`
+	}
+	list := make([]session, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, session{s, s.ID == currentID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data": gin.H{
+			"sessions": list,
+		},
+	})
+}
+
+// DeleteSession logs the user out of one of their sessions
+func DeleteSession(c *gin.Context) {
+	sessionID, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	userID, exists := c.Get("UserID")
+	if !exists {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/sessions/:id - DeleteSession()",
+			Extra: map[string]interface{}{
+				"UserID": userID,
+				"Detail": "User ID does not exist in the context",
+			},
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
+	var usersession models.UserAppSession
+	usersession.ID = uint(sessionID)
+	usersession.UserID = userID.(uint)
+	if err := usersession.Find(); err != nil || sessionID == 0 {
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		} else {
+			errMsg = "Session ID missing"
+		}
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/sessions/:id - DeleteSession()",
+			Code:      errors.SessionNotFound.Code,
+			Extra: map[string]interface{}{
+				"UserID":    userID,
+				"SessionID": c.Param("id"),
+			},
+			Err: errMsg,
+		})
+		c.AbortWithStatusJSON(errors.SessionNotFound.Status, errors.SessionNotFound)
+		return
+	}
+
+	if err := usersession.Delete(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/sessions/:id - DeleteSession()",
+			Code:      errors.UserSessionDelete.Code,
+			Extra:     map[string]interface{}{"SessionID": usersession.ID},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.UserSessionDelete.Status, errors.UserSessionDelete)
+		return
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionSessionDelete,
+		TargetType: "session",
+		TargetID:   usersession.ID,
+		Before:     usersession,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": 200,
+		"errors": make([]string, 0),
+		"data":   make([]string, 0),
+	})
+}
+
+// deviceName names the device logging in. Clients can name themselves with
+// the X-Device-Name header, otherwise the browser extension and website
+// are told apart by the origin of the request.
+func deviceName(c *gin.Context) string {
+	if name := c.GetHeader("X-Device-Name"); name != "" {
+		return name
+	}
+	origin := c.GetHeader("Origin")
+	if strings.HasPrefix(origin, "chrome-extension://") || strings.HasPrefix(origin, "moz-extension://") {
+		return "Browser Extension"
+	}
+	return "Website"
+}
+
 // UpdateUser updates a user
 func UpdateUser(c *gin.Context) {
 	conf := cfg.Load()
@@ -119,10 +313,11 @@ func UpdateUser(c *gin.Context) {
 
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user/update/:id - UpdateUser()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"UserID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user/update/:id - UpdateUser()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.UserNotFound.Status, errors.UserNotFound)
 		return
@@ -131,7 +326,8 @@ func UpdateUser(c *gin.Context) {
 	cookieUserID, exists := c.Get("UserID")
 	if !exists {
 		logger.Log(logger.Fields{
-			Loc: "/user/update/:id - UpdateUser()",
+			RequestID: requestid.Get(c),
+			Loc:       "/user/update/:id - UpdateUser()",
 			Extra: map[string]interface{}{
 				"UserID": userID,
 				"Detail": "User ID does not exist in the context",
@@ -142,8 +338,9 @@ func UpdateUser(c *gin.Context) {
 	}
 	if cookieUserID.(uint) != user.ID {
 		logger.Log(logger.Fields{
-			Loc:  "/user/update/:id - UpdateUser()",
-			Code: errors.ProtectedRouted.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/update/:id - UpdateUser()",
+			Code:      errors.ProtectedRouted.Code,
 			Extra: map[string]interface{}{
 				"CookieUserID": cookieUserID,
 				"QueryUserID":  userID,
@@ -160,28 +357,40 @@ func UpdateUser(c *gin.Context) {
 
 	if err := c.BindJSON(&userUpdates); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user/update/:id - UpdateUser()",
-			Code:  errors.InvalidForm.Code,
-			Extra: map[string]interface{}{"UserID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user/update/:id - UpdateUser()",
+			Code:      errors.InvalidForm.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
 		return
 	}
 
+	before := user
 	user.FirstName = userUpdates.FirstName
 	user.LastName = userUpdates.LastName
 	user.Email = userUpdates.Email
 	if err := user.Save(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user/update/:id - UpdateUser()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": user.ID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user/update/:id - UpdateUser()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
 	}
 
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionUserUpdate,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Before:     before,
+		After:      user,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 		"errors": make([]string, 0),
@@ -197,10 +406,11 @@ func DeleteUser(c *gin.Context) {
 	user.ID = uint(userID)
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user/delete/:id - DeleteUser()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"UserID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user/delete/:id - DeleteUser()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.UserNotFound.Status, errors.UserNotFound)
 		return
@@ -212,8 +422,9 @@ func DeleteUser(c *gin.Context) {
 	if err := userPlan.Find(); err == nil {
 		if err := userPlan.Delete(); err != nil {
 			logger.Log(logger.Fields{
-				Loc:  "/user/delete/:id - DeleteUser()",
-				Code: errors.InternalServerError.Code,
+				RequestID: requestid.Get(c),
+				Loc:       "/user/delete/:id - DeleteUser()",
+				Code:      errors.InternalServerError.Code,
 				Extra: map[string]interface{}{
 					"UserPlanID": userPlan.ID,
 					"Detail":     "Failed to delete user plan",
@@ -225,17 +436,60 @@ func DeleteUser(c *gin.Context) {
 		}
 	}
 
+	// Revoke any proxy credentials issued to the user
+	var creds models.AllProxyCredentials
+	if err := creds.FindAll(user.ID); err == nil {
+		for _, cred := range creds {
+			if err := cred.Delete(); err != nil {
+				logger.Log(logger.Fields{
+					RequestID: requestid.Get(c),
+					Loc:       "/user/delete/:id - DeleteUser()",
+					Code:      errors.ProxyCredentialRevoke.Code,
+					Extra: map[string]interface{}{
+						"UserID":   user.ID,
+						"ServerID": cred.ServerID,
+					},
+					Err: err.Error(),
+				})
+				c.AbortWithStatusJSON(errors.ProxyCredentialRevoke.Status, errors.ProxyCredentialRevoke)
+				return
+			}
+		}
+	}
+
+	// Revoke the users API tokens
+	var tokens models.AllAPITokens
+	if err := tokens.DeleteAll(user.ID); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "/user/delete/:id - DeleteUser()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID, "Detail": "Failed to revoke API tokens"},
+			Err:       err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
+	}
+
 	if err := user.Delete(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/user/delete/:id - DeleteUser()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/user/delete/:id - DeleteUser()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
 	}
 
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionUserDelete,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Before:     user,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 		"errors": make([]string, 0),
@@ -250,22 +504,40 @@ func LoginUser(c *gin.Context) {
 
 	if err := c.BindJSON(&userLogin); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/login - LoginUser()",
-			Code:  errors.EmailOrPassword.Code,
-			Extra: map[string]interface{}{"Email": userLogin.Email},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/login - LoginUser()",
+			Code:      errors.EmailOrPassword.Code,
+			Extra:     map[string]interface{}{"Email": userLogin.Email},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.EmailOrPassword.Status, errors.EmailOrPassword)
 		return
 	}
 
+	attempt := lockout.Attempt{
+		Scope:   models.LockoutScopeLogin,
+		Account: strings.ToLower(userLogin.Email),
+		IP:      clientip.Get(c),
+	}
+	if !checkLockout(c, attempt, "/login - LoginUser()") {
+		return
+	}
+
 	userDb.Email = userLogin.Email
 	if err := userDb.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/login - LoginUser()",
-			Code:  errors.EmailNotFound.Code,
-			Extra: map[string]interface{}{"Email": userLogin.Email},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/login - LoginUser()",
+			Code:      errors.EmailNotFound.Code,
+			Extra:     map[string]interface{}{"Email": userLogin.Email},
+			Err:       err.Error(),
+		})
+		lockout.Fail(attempt, nil)
+		audit.Record(c, audit.Entry{
+			Action:     models.AuditActionLoginFailed,
+			TargetType: "user",
+			TargetID:   0,
+			After:      gin.H{"email": userLogin.Email},
 		})
 		c.AbortWithStatusJSON(errors.EmailNotFound.Status, errors.EmailNotFound)
 		return
@@ -273,22 +545,65 @@ func LoginUser(c *gin.Context) {
 
 	if err := bcrypt.CompareHashAndPassword([]byte(userDb.Password), []byte(userLogin.Password)); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/login - LoginUser()",
-			Code:  errors.WrongPassword.Code,
-			Extra: map[string]interface{}{"Email": userLogin.Email},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/login - LoginUser()",
+			Code:      errors.WrongPassword.Code,
+			Extra:     map[string]interface{}{"Email": userLogin.Email},
+			Err:       err.Error(),
+		})
+		lockout.Fail(attempt, &userDb)
+		audit.Record(c, audit.Entry{
+			Action:     models.AuditActionLoginFailed,
+			TargetType: "user",
+			TargetID:   userDb.ID,
 		})
 		c.AbortWithStatusJSON(errors.WrongPassword.Status, errors.WrongPassword)
 		return
 	}
 
-	var usersession models.UserAppSession
+	// users with two factor enabled get a short lived token to send back
+	// along with their code instead of a session
+	if userDb.TwoFactorEnabled {
+		token, err := jwt.TwoFactorToken(userDb.ID)
+		if err != nil {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "/login - LoginUser()",
+				Code:      errors.InternalServerError.Code,
+				Extra:     map[string]interface{}{"UserID": userDb.ID},
+				Err:       err.Error(),
+			})
+			c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status": 200,
+			"data": gin.H{
+				"two_factor_required": true,
+				"two_factor_token":    token,
+			},
+		})
+		return
+	}
+
+	lockout.Succeed(attempt)
+	startSession(c, userDb)
+}
+
+// startSession logs the user in on this device, setting the auth cookies
+func startSession(c *gin.Context, userDb models.User) {
+	usersession := models.UserAppSession{
+		DeviceName: deviceName(c),
+		UserAgent:  c.Request.UserAgent(),
+		IP:         clientip.Get(c),
+	}
 	if err := usersession.New(userDb.ID); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/login - LoginUser() - Create session",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": userDb.ID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/login - startSession()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": userDb.ID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -297,10 +612,11 @@ func LoginUser(c *gin.Context) {
 	authToken, refreshToken, csrfToken, err := jwt.Tokens(usersession)
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/login - LoginUser()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": userDb.ID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/login - startSession()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": userDb.ID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -316,6 +632,14 @@ func LoginUser(c *gin.Context) {
 	c.SetCookie("uid", strconv.FormatUint(uint64(userDb.ID), 10), authCookieMaxAge, "/", conf.App.Domain, false, false)
 	c.Header("X-CSRF-Token", csrfToken)
 
+	audit.Record(c, audit.Entry{
+		ActorID:    userDb.ID,
+		Action:     models.AuditActionLogin,
+		TargetType: "user",
+		TargetID:   userDb.ID,
+		After:      gin.H{"device": usersession.DeviceName},
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 	})
@@ -329,9 +653,10 @@ func Logout(c *gin.Context) {
 	authCookie, err := c.Request.Cookie(conf.App.AuthCookieName)
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "user/logout - Logout()",
-			Code: errors.AuthCookieMissing.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "user/logout - Logout()",
+			Code:      errors.AuthCookieMissing.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithError(403, err)
 		return
@@ -340,9 +665,10 @@ func Logout(c *gin.Context) {
 	authClaims, err := jwt.ValidateToken(authCookie.Value)
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "user/logout - Logout()",
-			Code: errors.TokenInvalid.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "user/logout - Logout()",
+			Code:      errors.TokenInvalid.Code,
+			Err:       err.Error(),
 		})
 	}
 
@@ -351,14 +677,25 @@ func Logout(c *gin.Context) {
 		Identifier: authClaims.SessionIdentifier,
 	}
 
-	if err := usersession.DeleteAll(); err != nil {
-		logger.Log(logger.Fields{
-			Loc:  "user/logout - Logout()",
-			Code: errors.UserSessionDelete.Code,
-			Err:  err.Error(),
-		})
+	// only log out this device, the users other sessions stay active
+	if err := usersession.FindByIdentifier(); err == nil {
+		if err := usersession.Delete(); err != nil {
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "user/logout - Logout()",
+				Code:      errors.UserSessionDelete.Code,
+				Err:       err.Error(),
+			})
+		}
 	}
 
+	audit.Record(c, audit.Entry{
+		ActorID:    authClaims.UserID,
+		Action:     models.AuditActionLogout,
+		TargetType: "user",
+		TargetID:   authClaims.UserID,
+	})
+
 	c.SetCookie(conf.App.AuthCookieName, "", -1, "/", conf.App.Domain, false, true)
 	c.SetCookie(conf.App.RefreshCookieName, "", -1, "/", conf.App.Domain, false, true)
 	c.SetCookie("uid", "", -1, "/", conf.App.Domain, false, false)
@@ -374,10 +711,11 @@ func SignUpUser(c *gin.Context) {
 	var user models.User
 	if err := c.BindJSON(&user); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/signup - SignUpUser()",
-			Code:  errors.InvalidForm.Code,
-			Extra: map[string]interface{}{"Email": user.Email},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/signup - SignUpUser()",
+			Code:      errors.InvalidForm.Code,
+			Extra:     map[string]interface{}{"Email": user.Email},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
 		return
@@ -387,22 +725,26 @@ func SignUpUser(c *gin.Context) {
 	userTmp.Email = user.Email
 	if err := userTmp.Find(); err == nil {
 		logger.Log(logger.Fields{
-			Loc:   "/signup - SignUpUser()",
-			Code:  errors.EmailTaken.Code,
-			Extra: map[string]interface{}{"Email": user.Email},
-			Err:   errors.EmailTaken.Detail,
+			RequestID: requestid.Get(c),
+			Loc:       "/signup - SignUpUser()",
+			Code:      errors.EmailTaken.Code,
+			Extra:     map[string]interface{}{"Email": user.Email},
+			Err:       errors.EmailTaken.Detail,
 		})
 		c.JSON(errors.EmailTaken.Status, errors.EmailTaken)
 		return
 	}
 
 	user.Type = models.UserTypeNormal
+	user.Role = ""
+	user.TwoFactorEnabled = false
 	if err := user.Create(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/signup - SignUpUser()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"Email": user.Email},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/signup - SignUpUser()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"Email": user.Email},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -412,10 +754,11 @@ func SignUpUser(c *gin.Context) {
 	plan.PlanType = models.PlanTypeFreeTrial
 	if err := plan.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/signup - SignUpUser()",
-			Code:  errors.PlanNotFound.Code,
-			Extra: map[string]interface{}{"Detail": "Free Trial Plan Not Found"},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/signup - SignUpUser()",
+			Code:      errors.PlanNotFound.Code,
+			Extra:     map[string]interface{}{"Detail": "Free Trial Plan Not Found"},
+			Err:       err.Error(),
 		})
 	}
 
@@ -429,8 +772,9 @@ func SignUpUser(c *gin.Context) {
 
 		if err := userPlan.Save(); err != nil {
 			logger.Log(logger.Fields{
-				Loc:  "/signup - SignUpUser()",
-				Code: errors.InternalServerError.Code,
+				RequestID: requestid.Get(c),
+				Loc:       "/signup - SignUpUser()",
+				Code:      errors.InternalServerError.Code,
 				Extra: map[string]interface{}{
 					"UserID": userPlan.UserID,
 					"Detail": "Adding user plan with free trial failed",
@@ -444,10 +788,11 @@ func SignUpUser(c *gin.Context) {
 	et.UserID = user.ID
 	if err := et.Create(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/signup - SignUpUser()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": user.ID, "Detail": "Error creating email confirmation object"},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/signup - SignUpUser()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID, "Detail": "Error creating email confirmation object"},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -455,10 +800,11 @@ func SignUpUser(c *gin.Context) {
 
 	if err := sendgrid.Send().RegistrationMail(user, et.Token); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/signup - SignUpUser()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": user.ID, "Detail": "Error sending registration email"},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/signup - SignUpUser()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID, "Detail": "Error sending registration email"},
+			Err:       err.Error(),
 		})
 	}
 
@@ -474,9 +820,10 @@ func AllUsers(c *gin.Context) {
 	var users models.AllUsers
 	if err := users.FindAll(offset); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/plans - AllUsers()",
-			Code: errors.InternalServerError.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/plans - AllUsers()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 	}
@@ -490,9 +837,10 @@ func AllUsers(c *gin.Context) {
 	count, err := users.Count()
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/servers - Connections()",
-			Code: errors.InternalServerError.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/servers - Connections()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 	}
@@ -515,10 +863,11 @@ func ConfirmEmail(c *gin.Context) {
 	et.Token = token
 	if err := et.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/confirm_email/:token - ConfirmEmail()",
-			Code:  errors.TokenNotFound.Code,
-			Extra: map[string]interface{}{"token": c.Param("token")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/confirm_email/:token - ConfirmEmail()",
+			Code:      errors.TokenNotFound.Code,
+			Extra:     map[string]interface{}{"token": c.Param("token")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.TokenNotFound.Status, errors.TokenNotFound)
 		return
@@ -528,10 +877,11 @@ func ConfirmEmail(c *gin.Context) {
 	user.ID = et.UserID
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/confirm_email/:token - ConfirmEmail()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"UserID": et.UserID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/confirm_email/:token - ConfirmEmail()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": et.UserID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.UserNotFound.Status, errors.UserNotFound)
 		return
@@ -540,10 +890,11 @@ func ConfirmEmail(c *gin.Context) {
 	user.EmailConfirmed = true
 	if err := user.Save(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/confirm_email/:token - ConfirmEmail()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": user.ID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/confirm_email/:token - ConfirmEmail()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -551,10 +902,11 @@ func ConfirmEmail(c *gin.Context) {
 
 	if err := et.Delete(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/confirm_email/:token - ConfirmEmail()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"UserID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/confirm_email/:token - ConfirmEmail()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.UserNotFound.Status, errors.UserNotFound)
 		return
@@ -571,7 +923,8 @@ func ResendLink(c *gin.Context) {
 	userID, exists := c.Get("UserID")
 	if !exists {
 		logger.Log(logger.Fields{
-			Loc: "/confirm_email/resend - ResendLink()",
+			RequestID: requestid.Get(c),
+			Loc:       "/confirm_email/resend - ResendLink()",
 			Extra: map[string]interface{}{
 				"Detail": "User ID does not exist in the context",
 			},
@@ -581,10 +934,11 @@ func ResendLink(c *gin.Context) {
 	user.ID = userID.(uint)
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/confirm_email/resend - ResendLink()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"UserID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/confirm_email/resend - ResendLink()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.UserNotFound.Status, errors.UserNotFound)
 		return
@@ -594,10 +948,11 @@ func ResendLink(c *gin.Context) {
 	et.UserID = user.ID
 	if err := et.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/confirm_email/resend - ResendLink()",
-			Code:  errors.TokenNotFound.Code,
-			Extra: map[string]interface{}{"UserID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/confirm_email/resend - ResendLink()",
+			Code:      errors.TokenNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.TokenNotFound.Status, errors.TokenNotFound)
 		return
@@ -605,10 +960,11 @@ func ResendLink(c *gin.Context) {
 
 	if err := sendgrid.Send().RegistrationMail(user, et.Token); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/signup - SignUpUser()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserID": user.ID, "Detail": "Error sending registration email"},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/signup - SignUpUser()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserID": user.ID, "Detail": "Error sending registration email"},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
@@ -625,7 +981,8 @@ func StripeSession(c *gin.Context) {
 	userID, exists := c.Get("UserID")
 	if !exists {
 		logger.Log(logger.Fields{
-			Loc: "/user/session/:planid - StripeSession()",
+			RequestID: requestid.Get(c),
+			Loc:       "/user/session/:planid - StripeSession()",
 			Extra: map[string]interface{}{
 				"UserID": userID,
 				"Detail": "User ID does not exist in the context",
@@ -635,8 +992,9 @@ func StripeSession(c *gin.Context) {
 	user.ID = userID.(uint)
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/user/session/:planid - StripeSession()",
-			Code: errors.UserNotFound.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/session/:planid - StripeSession()",
+			Code:      errors.UserNotFound.Code,
 			Extra: map[string]interface{}{
 				"UserID": userID,
 				"Detail": errors.UserNotFound.Detail,
@@ -653,8 +1011,9 @@ func StripeSession(c *gin.Context) {
 
 	if err := plan.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/user/session/:planid - StripeSession()",
-			Code: errors.PlanNotFound.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/session/:planid - StripeSession()",
+			Code:      errors.PlanNotFound.Code,
 			Extra: map[string]interface{}{
 				"PlanID": c.Param("planid"),
 			},
@@ -668,8 +1027,9 @@ func StripeSession(c *gin.Context) {
 		customer, err := user.CreateStripeCustomer()
 		if err != nil {
 			logger.Log(logger.Fields{
-				Loc:  "/user/session/:planid - StripeSession()",
-				Code: errors.StripeCreateCustomerErr.Code,
+				RequestID: requestid.Get(c),
+				Loc:       "/user/session/:planid - StripeSession()",
+				Code:      errors.StripeCreateCustomerErr.Code,
 				Extra: map[string]interface{}{
 					"UserID": userID,
 					"Detail": errors.StripeCreateCustomerErr.Detail,
@@ -682,8 +1042,9 @@ func StripeSession(c *gin.Context) {
 		user.StripeCustomerID = customer.ID
 		if err := user.Save(); err != nil {
 			logger.Log(logger.Fields{
-				Loc:  "/user/session/:planid - CreateSession()",
-				Code: errors.StripeCreateCustomerErr.Code,
+				RequestID: requestid.Get(c),
+				Loc:       "/user/session/:planid - CreateSession()",
+				Code:      errors.StripeCreateCustomerErr.Code,
 				Extra: map[string]interface{}{
 					"StripeCustomerID": customer.ID,
 					"UserID":           userID,
@@ -701,8 +1062,9 @@ func StripeSession(c *gin.Context) {
 		stripeSession, err := stripe.CreateSubscriptionSession(plan.StripePlanID, user.StripeCustomerID, fmt.Sprint(user.ID))
 		if err != nil {
 			logger.Log(logger.Fields{
-				Loc:  "/user/session/:planid - CreateSession()",
-				Code: errors.StripeCreateSessionErr.Code,
+				RequestID: requestid.Get(c),
+				Loc:       "/user/session/:planid - CreateSession()",
+				Code:      errors.StripeCreateSessionErr.Code,
 				Extra: map[string]interface{}{
 					"StripePlanID": plan.StripePlanID,
 					"Detail":       errors.StripeCreateSessionErr.Detail,
@@ -720,8 +1082,9 @@ func StripeSession(c *gin.Context) {
 		cart.PlanID = plan.ID
 		if err := cart.Save(); err != nil {
 			logger.Log(logger.Fields{
-				Loc:  "/user/session/:planid - CreateSession()",
-				Code: errors.StripeCreateCustomerErr.Code,
+				RequestID: requestid.Get(c),
+				Loc:       "/user/session/:planid - CreateSession()",
+				Code:      errors.StripeCreateCustomerErr.Code,
 				Extra: map[string]interface{}{
 					"cartID": cart.ID,
 					"UserID": userID,
@@ -736,8 +1099,9 @@ func StripeSession(c *gin.Context) {
 		stripeSession, err := stripe.CreatePAYGSession(plan.Name, user.StripeCustomerID, cart.ID, plan.Amount)
 		if err != nil {
 			logger.Log(logger.Fields{
-				Loc:  "/user/session/:planid - CreateSession()",
-				Code: errors.StripeCreateSessionErr.Code,
+				RequestID: requestid.Get(c),
+				Loc:       "/user/session/:planid - CreateSession()",
+				Code:      errors.StripeCreateSessionErr.Code,
 				Extra: map[string]interface{}{
 					"StripePlanID": plan.StripePlanID,
 					"Detail":       errors.StripeCreateSessionErr.Detail,
@@ -762,7 +1126,8 @@ func StripeUpdatePaymentSession(c *gin.Context) {
 	userID, exists := c.Get("UserID")
 	if !exists {
 		logger.Log(logger.Fields{
-			Loc: "/user/updatepayment- StripeUpdatePaymentSession()",
+			RequestID: requestid.Get(c),
+			Loc:       "/user/updatepayment- StripeUpdatePaymentSession()",
 			Extra: map[string]interface{}{
 				"UserID": userID,
 				"Detail": "User ID does not exist in the context",
@@ -772,8 +1137,9 @@ func StripeUpdatePaymentSession(c *gin.Context) {
 	user.ID = userID.(uint)
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/user/updatepayment- StripeUpdatePaymentSession()",
-			Code: errors.UserNotFound.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/updatepayment- StripeUpdatePaymentSession()",
+			Code:      errors.UserNotFound.Code,
 			Extra: map[string]interface{}{
 				"UserID": userID,
 				"Detail": errors.UserNotFound.Detail,
@@ -787,8 +1153,9 @@ func StripeUpdatePaymentSession(c *gin.Context) {
 	customer, err := stripe.GetCustomer(user.StripeCustomerID)
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/user/updatepayment- StripeUpdatePaymentSession()",
-			Code: errors.StripeCustomerNotFound.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/updatepayment- StripeUpdatePaymentSession()",
+			Code:      errors.StripeCustomerNotFound.Code,
 			Extra: map[string]interface{}{
 				"StripeCustomerID": user.StripeCustomerID,
 				"UserID":           user.ID,
@@ -807,8 +1174,9 @@ func StripeUpdatePaymentSession(c *gin.Context) {
 	stripeSetupSession, err := stripe.CreateSessionSetup(customer.ID, subcscription.ID)
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/user/updatepayment- StripeUpdatePaymentSession()",
-			Code: errors.StripeCreateSessionSetupErr.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/updatepayment- StripeUpdatePaymentSession()",
+			Code:      errors.StripeCreateSessionSetupErr.Code,
 			Extra: map[string]interface{}{
 				"StripeCustomerID": customer.ID,
 				"StripeSubID":      subcscription.ID,
@@ -831,7 +1199,8 @@ func Webhook(c *gin.Context) {
 	webhookevent, err := stripe.WebhookEventHandler(c.Request.Body, c.Request.Header.Get("Stripe-Signature"), conf.Stripe.EndpointSecret)
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc: "/user/webhook - Webhook()",
+			RequestID: requestid.Get(c),
+			Loc:       "/user/webhook - Webhook()",
 			Extra: map[string]interface{}{
 				"Detail": "Error fetching webhook event",
 			},
@@ -848,8 +1217,9 @@ func Webhook(c *gin.Context) {
 			plan.StripePlanID = webhookevent.StripePlanID
 			if err := plan.Find(); err != nil {
 				logger.Log(logger.Fields{
-					Loc:  "/user/webhook - Webhook()",
-					Code: errors.PlanNotFound.Code,
+					RequestID: requestid.Get(c),
+					Loc:       "/user/webhook - Webhook()",
+					Code:      errors.PlanNotFound.Code,
 					Extra: map[string]interface{}{
 						"PlanID": webhookevent.StripePlanID,
 					},
@@ -866,10 +1236,11 @@ func Webhook(c *gin.Context) {
 			userPlan.ExpiryDate = time.Unix(webhookevent.StripeSubscriptionEndPeriod, 0)
 			if err := userPlan.Create(); err != nil {
 				logger.Log(logger.Fields{
-					Loc:   "/user/webhook - Webhook()",
-					Code:  errors.InternalServerError.Code,
-					Extra: map[string]interface{}{"UserID": userPlan.UserID},
-					Err:   err.Error(),
+					RequestID: requestid.Get(c),
+					Loc:       "/user/webhook - Webhook()",
+					Code:      errors.InternalServerError.Code,
+					Extra:     map[string]interface{}{"UserID": userPlan.UserID},
+					Err:       err.Error(),
 				})
 				c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 				return
@@ -881,8 +1252,9 @@ func Webhook(c *gin.Context) {
 			cart.ID = webhookevent.CartID
 			if err := cart.Find(); err != nil {
 				logger.Log(logger.Fields{
-					Loc:  "/user/webhook - Webhook()",
-					Code: errors.InternalServerError.Code,
+					RequestID: requestid.Get(c),
+					Loc:       "/user/webhook - Webhook()",
+					Code:      errors.InternalServerError.Code,
 					Extra: map[string]interface{}{
 						"CartID": webhookevent.CartID,
 					},
@@ -896,8 +1268,9 @@ func Webhook(c *gin.Context) {
 			plan.ID = cart.PlanID
 			if err := plan.Find(); err != nil {
 				logger.Log(logger.Fields{
-					Loc:  "/user/webhook - Webhook()",
-					Code: errors.PlanNotFound.Code,
+					RequestID: requestid.Get(c),
+					Loc:       "/user/webhook - Webhook()",
+					Code:      errors.PlanNotFound.Code,
 					Extra: map[string]interface{}{
 						"PlanID": cart.PlanID,
 					},
@@ -915,10 +1288,11 @@ func Webhook(c *gin.Context) {
 			userPlan.ExpiryDate = time.Now().Add(time.Hour * time.Duration(plan.IntervalCount))
 			if err := userPlan.Save(); err != nil {
 				logger.Log(logger.Fields{
-					Loc:   "/user/webhook - Webhook()",
-					Code:  errors.InternalServerError.Code,
-					Extra: map[string]interface{}{"UserPlanID": userPlan.UserID},
-					Err:   err.Error(),
+					RequestID: requestid.Get(c),
+					Loc:       "/user/webhook - Webhook()",
+					Code:      errors.InternalServerError.Code,
+					Extra:     map[string]interface{}{"UserPlanID": userPlan.UserID},
+					Err:       err.Error(),
 				})
 				c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 				return
@@ -926,8 +1300,9 @@ func Webhook(c *gin.Context) {
 
 			if err := cart.Delete(); err != nil {
 				logger.Log(logger.Fields{
-					Loc:  "/user/webhook - Webhook()",
-					Code: errors.InternalServerError.Code,
+					RequestID: requestid.Get(c),
+					Loc:       "/user/webhook - Webhook()",
+					Code:      errors.InternalServerError.Code,
 					Extra: map[string]interface{}{
 						"CartID": cart.UserID,
 						"Detail": "Failed to delete cart",
@@ -948,8 +1323,9 @@ func Webhook(c *gin.Context) {
 			plan.StripePlanID = webhookevent.StripePlanID
 			if err := plan.Find(); err != nil {
 				logger.Log(logger.Fields{
-					Loc:  "/user/webhook - Webhook()",
-					Code: errors.PlanNotFound.Code,
+					RequestID: requestid.Get(c),
+					Loc:       "/user/webhook - Webhook()",
+					Code:      errors.PlanNotFound.Code,
 					Extra: map[string]interface{}{
 						"PlanID": webhookevent.StripePlanID,
 					},
@@ -962,8 +1338,9 @@ func Webhook(c *gin.Context) {
 			user.StripeCustomerID = webhookevent.StripeCustomerID
 			if err := user.Find(); err != nil {
 				logger.Log(logger.Fields{
-					Loc:  "/user/webhook - Webhook()",
-					Code: errors.UserNotFound.Code,
+					RequestID: requestid.Get(c),
+					Loc:       "/user/webhook - Webhook()",
+					Code:      errors.UserNotFound.Code,
 					Extra: map[string]interface{}{
 						"CustomerID": webhookevent.StripeCustomerID,
 					},
@@ -977,8 +1354,9 @@ func Webhook(c *gin.Context) {
 			userPlan.PlanID = plan.ID
 			if err := userPlan.Find(); err != nil {
 				logger.Log(logger.Fields{
-					Loc:  "/user/webhook - Webhook()",
-					Code: errors.InternalServerError.Code,
+					RequestID: requestid.Get(c),
+					Loc:       "/user/webhook - Webhook()",
+					Code:      errors.InternalServerError.Code,
 					Extra: map[string]interface{}{
 						"UserID": userPlan.UserID,
 						"Detail": "Could not find user_plan record",
@@ -995,10 +1373,11 @@ func Webhook(c *gin.Context) {
 			// and create this object as a new one. Still needs to be tested though
 			if err := userPlan.Create(); err != nil {
 				logger.Log(logger.Fields{
-					Loc:   "/user/webhook - Webhook()",
-					Code:  errors.InternalServerError.Code,
-					Extra: map[string]interface{}{"UserID": userPlan.UserID},
-					Err:   err.Error(),
+					RequestID: requestid.Get(c),
+					Loc:       "/user/webhook - Webhook()",
+					Code:      errors.InternalServerError.Code,
+					Extra:     map[string]interface{}{"UserID": userPlan.UserID},
+					Err:       err.Error(),
 				})
 				c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 				return
@@ -1014,7 +1393,8 @@ func CancelSubscription(c *gin.Context) {
 	userID, exists := c.Get("UserID")
 	if !exists {
 		logger.Log(logger.Fields{
-			Loc: "/user/updatepayment- StripeUpdatePaymentSession()",
+			RequestID: requestid.Get(c),
+			Loc:       "/user/updatepayment- StripeUpdatePaymentSession()",
 			Extra: map[string]interface{}{
 				"UserID": userID,
 				"Detail": "User ID does not exist in the context",
@@ -1024,8 +1404,9 @@ func CancelSubscription(c *gin.Context) {
 	user.ID = userID.(uint)
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/user/updatepayment- StripeUpdatePaymentSession()",
-			Code: errors.UserNotFound.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/updatepayment- StripeUpdatePaymentSession()",
+			Code:      errors.UserNotFound.Code,
 			Extra: map[string]interface{}{
 				"UserID": userID,
 				"Detail": errors.UserNotFound.Detail,
@@ -1039,8 +1420,9 @@ func CancelSubscription(c *gin.Context) {
 	subscription, err := stripe.CustomerSubscription(user.StripeCustomerID)
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/user/cancel - CancelSubscription()",
-			Code: errors.InternalServerError.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/cancel - CancelSubscription()",
+			Code:      errors.InternalServerError.Code,
 			Extra: map[string]interface{}{
 				"UserID": user.ID,
 				"Detail": "Error fetching customer subscription",
@@ -1055,8 +1437,9 @@ func CancelSubscription(c *gin.Context) {
 	plan.StripePlanID = subscription.Plan.ID
 	if err := plan.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/user/webhook - Webhook()",
-			Code: errors.PlanNotFound.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/webhook - Webhook()",
+			Code:      errors.PlanNotFound.Code,
 			Extra: map[string]interface{}{
 				"PlanID": subscription.Plan.ID,
 			},
@@ -1071,8 +1454,9 @@ func CancelSubscription(c *gin.Context) {
 	userPlan.PlanID = plan.ID
 	if err := userPlan.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/user/webhook - Webhook()",
-			Code: errors.InternalServerError.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/webhook - Webhook()",
+			Code:      errors.InternalServerError.Code,
 			Extra: map[string]interface{}{
 				"UserID": userPlan.UserID,
 				"Detail": "Could not find user_plan record",
@@ -1085,8 +1469,9 @@ func CancelSubscription(c *gin.Context) {
 
 	if err := userPlan.Delete(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/user/webhook - Webhook()",
-			Code: errors.InternalServerError.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/webhook - Webhook()",
+			Code:      errors.InternalServerError.Code,
 			Extra: map[string]interface{}{
 				"UserPlanID": userPlan.ID,
 				"Detail":     "Failed to delete user plan",
@@ -1100,8 +1485,9 @@ func CancelSubscription(c *gin.Context) {
 	err = stripe.CancelSubscription(subscription.ID)
 	if err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/user/cancel - CancelSubscription()",
-			Code: errors.InternalServerError.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/user/cancel - CancelSubscription()",
+			Code:      errors.InternalServerError.Code,
 			Extra: map[string]interface{}{
 				"UserID": user.ID,
 				"Detail": "Error canceling customer subscription",
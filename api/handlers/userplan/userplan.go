@@ -1,10 +1,13 @@
 package userplan
 
 import (
+	"eirevpn/api/audit"
 	"eirevpn/api/config"
 	"eirevpn/api/errors"
 	"eirevpn/api/logger"
 	"eirevpn/api/models"
+	"eirevpn/api/permissions"
+	"eirevpn/api/requestid"
 	"net/http"
 	"strconv"
 	"time"
@@ -16,7 +19,8 @@ func checkPrivilege(c *gin.Context, queryUserID uint) *errors.APIError {
 	cookieUserID, exists := c.Get("UserID")
 	if !exists {
 		logger.Log(logger.Fields{
-			Loc: "/userplans - checkPrivilege()",
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans - checkPrivilege()",
 			Extra: map[string]interface{}{
 				"UserID": cookieUserID,
 				"Detail": "User ID does not exist in the context",
@@ -29,22 +33,25 @@ func checkPrivilege(c *gin.Context, queryUserID uint) *errors.APIError {
 	user.ID = cookieUserID.(uint)
 	if err := user.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/userplans - checkPrivilege()",
-			Code:  errors.UserNotFound.Code,
-			Extra: map[string]interface{}{"UserID": cookieUserID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans - checkPrivilege()",
+			Code:      errors.UserNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": cookieUserID},
+			Err:       err.Error(),
 		})
 		return &errors.UserNotFound
 	}
 
-	if user.Type == models.UserTypeAdmin {
+	// admins who can view user plans may access any users plan
+	if permissions.Has(permissions.RoleOf(user), permissions.UserPlansRead) {
 		return nil
 	}
 
 	if user.ID != queryUserID {
 		logger.Log(logger.Fields{
-			Loc:  "/userplans - checkPrivilege()",
-			Code: errors.ProtectedRouted.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans - checkPrivilege()",
+			Code:      errors.ProtectedRouted.Code,
 			Extra: map[string]interface{}{
 				"CookieUserID": cookieUserID,
 				"QueryUserID":  queryUserID,
@@ -71,10 +78,11 @@ func UserPlan(c *gin.Context) {
 	userplan.UserID = uint(userID)
 	if err := userplan.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/userplans/:id - UserPlan()",
-			Code:  errors.UserPlanNotFound.Code,
-			Extra: map[string]interface{}{"UserPlanID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans/:id - UserPlan()",
+			Code:      errors.UserPlanNotFound.Code,
+			Extra:     map[string]interface{}{"UserPlanID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.UserPlanNotFound.Status, errors.UserPlanNotFound)
 		return
@@ -95,10 +103,11 @@ func UserPlan(c *gin.Context) {
 	plan.ID = userplan.PlanID
 	if err := plan.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/userplans/:id - UserPlan()",
-			Code:  errors.PlanNotFound.Code,
-			Extra: map[string]interface{}{"PlanID": userplan.PlanID},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans/:id - UserPlan()",
+			Code:      errors.PlanNotFound.Code,
+			Extra:     map[string]interface{}{"PlanID": userplan.PlanID},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.PlanNotFound.Status, errors.PlanNotFound)
 		return
@@ -133,9 +142,10 @@ func CreateUserPlan(c *gin.Context) {
 	userPlanCreate := UserPlanCreate{}
 	if err := c.BindJSON(&userPlanCreate); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/userplans/create - CreateUserPlan()",
-			Code: errors.InvalidForm.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans/create - CreateUserPlan()",
+			Code:      errors.InvalidForm.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
 		return
@@ -151,8 +161,9 @@ func CreateUserPlan(c *gin.Context) {
 
 	if err := userplan.Create(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/userplans/create - CreateUserPlan()",
-			Code: errors.InternalServerError.Code,
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans/create - CreateUserPlan()",
+			Code:      errors.InternalServerError.Code,
 			Extra: map[string]interface{}{
 				"UserPlan": userplan,
 			},
@@ -162,6 +173,13 @@ func CreateUserPlan(c *gin.Context) {
 		return
 	}
 
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionUserPlanCreate,
+		TargetType: "userplan",
+		TargetID:   userplan.ID,
+		After:      userplan,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 		"data": gin.H{
@@ -178,10 +196,11 @@ func DeleteUserPlan(c *gin.Context) {
 	userplan.UserID = uint(userID)
 	if err := userplan.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/userplans/delete/:id - DeleteUserPlan()",
-			Code:  errors.UserPlanNotFound.Code,
-			Extra: map[string]interface{}{"UserPlanID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans/delete/:id - DeleteUserPlan()",
+			Code:      errors.UserPlanNotFound.Code,
+			Extra:     map[string]interface{}{"UserPlanID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.UserPlanNotFound.Status, errors.UserPlanNotFound)
 		return
@@ -189,15 +208,23 @@ func DeleteUserPlan(c *gin.Context) {
 
 	if err := userplan.Delete(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/userplans/delete/:id - DeleteUserPlan()",
-			Code:  errors.PlanNotFound.Code,
-			Extra: map[string]interface{}{"UserPlanID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans/delete/:id - DeleteUserPlan()",
+			Code:      errors.PlanNotFound.Code,
+			Extra:     map[string]interface{}{"UserPlanID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 		return
 	}
 
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionUserPlanDelete,
+		TargetType: "userplan",
+		TargetID:   userplan.ID,
+		Before:     userplan,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 	})
@@ -218,10 +245,11 @@ func UpdateUserPlan(c *gin.Context) {
 
 	if err := userplan.Find(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/userplans/update/:id - UpdateUserPlan()",
-			Code:  errors.UserPlanNotFound.Code,
-			Extra: map[string]interface{}{"UserID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans/update/:id - UpdateUserPlan()",
+			Code:      errors.UserPlanNotFound.Code,
+			Extra:     map[string]interface{}{"UserID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.UserPlanNotFound.Status, errors.UserPlanNotFound)
 		return
@@ -229,15 +257,17 @@ func UpdateUserPlan(c *gin.Context) {
 
 	if err := c.BindJSON(&userPlanUdates); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/userplans/update/:id - UpdateUserPlan()",
-			Code:  errors.InvalidForm.Code,
-			Extra: map[string]interface{}{"UserPlanID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans/update/:id - UpdateUserPlan()",
+			Code:      errors.InvalidForm.Code,
+			Extra:     map[string]interface{}{"UserPlanID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InvalidForm.Status, errors.InvalidForm)
 		return
 	}
 
+	before := userplan
 	userplan.Active = userPlanUdates.Active == "true"
 	startdate, _ := time.Parse("2006-01-02 15:04", userPlanUdates.StartDate)
 	userplan.StartDate = startdate
@@ -245,14 +275,24 @@ func UpdateUserPlan(c *gin.Context) {
 	userplan.ExpiryDate = expirydate
 	if err := userplan.Save(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:   "/userplans/update/:id - UpdateUserPlan()",
-			Code:  errors.InternalServerError.Code,
-			Extra: map[string]interface{}{"UserPlanID": c.Param("id")},
-			Err:   err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans/update/:id - UpdateUserPlan()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"UserPlanID": c.Param("id")},
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return
 	}
 
+	audit.Record(c, audit.Entry{
+		Action:     models.AuditActionUserPlanUpdate,
+		TargetType: "userplan",
+		TargetID:   userplan.ID,
+		Before:     before,
+		After:      userplan,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": 200,
 	})
@@ -264,9 +304,10 @@ func AllUserPlans(c *gin.Context) {
 
 	if err := plans.FindAll(); err != nil {
 		logger.Log(logger.Fields{
-			Loc:  "/userplans - AllUserPlans()",
-			Code: errors.InternalServerError.Code,
-			Err:  err.Error(),
+			RequestID: requestid.Get(c),
+			Loc:       "/userplans - AllUserPlans()",
+			Code:      errors.InternalServerError.Code,
+			Err:       err.Error(),
 		})
 		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 	}
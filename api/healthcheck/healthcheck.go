@@ -0,0 +1,100 @@
+package healthcheck
+
+import (
+	"eirevpn/api/config"
+	"eirevpn/api/integrations/proxy"
+	"eirevpn/api/logger"
+	"eirevpn/api/models"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	defaultInterval    = 60
+	defaultMaxFailures = 3
+	dialTimeout        = 5 * time.Second
+)
+
+// Start probes every server on the interval set in the config until stop
+// is closed. It is meant to be run in its own goroutine.
+func Start(stop <-chan struct{}) {
+	for {
+		CheckAll()
+		interval := config.Load().App.HealthCheckInterval
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Duration(interval) * time.Second):
+		}
+	}
+}
+
+// CheckAll probes each server and records the result against it
+func CheckAll() {
+	var servers models.AllServers
+	if err := servers.FindAll(); err != nil {
+		logger.Log(logger.Fields{
+			Loc: "healthcheck - CheckAll()",
+			Err: err.Error(),
+		})
+		return
+	}
+	maxFailures := config.Load().App.HealthCheckMaxFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultMaxFailures
+	}
+	for _, server := range servers {
+		Check(&server, maxFailures)
+	}
+}
+
+// Check dials the proxy port of the server and asks its node for its status.
+// The server is only marked unhealthy after maxFailures checks in a row fail
+// so a single dropped probe does not hide it from users.
+func Check(server *models.Server, maxFailures int) {
+	latency, err := probe(server)
+	if err != nil {
+		server.FailedChecks++
+		server.Healthy = server.FailedChecks < maxFailures
+		logger.Warn(logger.Fields{
+			Loc: "healthcheck - Check()",
+			Extra: map[string]interface{}{
+				"ServerID":     server.ID,
+				"FailedChecks": server.FailedChecks,
+			},
+			Err: err.Error(),
+		})
+	} else {
+		server.FailedChecks = 0
+		server.Healthy = true
+		server.Latency = latency
+		server.LastSeen = time.Now()
+	}
+	server.LastChecked = time.Now()
+	if err := server.SaveHealth(); err != nil {
+		logger.Log(logger.Fields{
+			Loc:   "healthcheck - Check()",
+			Extra: map[string]interface{}{"ServerID": server.ID},
+			Err:   err.Error(),
+		})
+	}
+}
+
+// probe returns the time taken to open a TCP connection to the proxy
+func probe(server *models.Server) (int64, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", server.IP, server.Port), dialTimeout)
+	if err != nil {
+		return 0, err
+	}
+	latency := time.Since(start).Nanoseconds() / int64(time.Millisecond)
+	conn.Close()
+	if _, err := proxy.Status(server.Node()); err != nil {
+		return 0, err
+	}
+	return latency, nil
+}
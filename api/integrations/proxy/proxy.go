@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"bytes"
+	"eirevpn/api/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultAPIPort is used when a server has no control API port set
+const defaultAPIPort = 3003
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Node holds the address and secret of a proxy nodes control API
+type Node struct {
+	IP     string
+	Port   int
+	Secret string
+}
+
+// NodeStatus is reported by the status endpoint of a proxy node
+type NodeStatus struct {
+	Version     string `json:"version"`
+	Uptime      int64  `json:"uptime"`
+	Credentials int    `json:"credentials"`
+	Connections int    `json:"connections"`
+}
+
+// NodeError is the structured error returned by a proxy node
+type NodeError struct {
+	Status int    `json:"status"`
+	Code   string `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+func (err *NodeError) Error() string {
+	return fmt.Sprintf("Status: %d, Code: %s, Title: %s, Detail: %s", err.Status, err.Code, err.Title, err.Detail)
+}
+
+// AddCredential issues a username and password for the user on the node.
+// The node refuses connections from more than maxDevices client IPs at
+// once, 0 meaning no limit.
+func AddCredential(node Node, userID uint, username, password string, maxDevices int) error {
+	return node.request("POST", "/v1/credentials", map[string]interface{}{
+		"user_id":     userID,
+		"username":    username,
+		"password":    password,
+		"max_devices": maxDevices,
+	}, nil)
+}
+
+// RevokeCredential removes the users credential from the node. A node
+// which holds no credential for the user is not treated as an error.
+func RevokeCredential(node Node, userID uint) error {
+	err := node.request("DELETE", fmt.Sprintf("/v1/credentials/%d", userID), nil, nil)
+	if nodeErr, ok := err.(*NodeError); ok && nodeErr.Status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// SuspendCredential stops the node accepting the users credential and
+// closes their open connections. Issuing the credential again lifts it.
+func SuspendCredential(node Node, userID uint) error {
+	return node.request("POST", fmt.Sprintf("/v1/credentials/%d/suspend", userID), nil, nil)
+}
+
+// Status fetches the version and state of the node
+func Status(node Node) (*NodeStatus, error) {
+	if config.Load().App.TestMode {
+		return &NodeStatus{}, nil
+	}
+	var resp struct {
+		Data NodeStatus `json:"data"`
+	}
+	if err := node.request("GET", "/v1/status", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+func (node Node) request(method, path string, body map[string]interface{}, out interface{}) error {
+	if config.Load().App.TestMode {
+		return nil
+	}
+	var reqBody io.Reader
+	if body != nil {
+		jsonStr, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewBuffer(jsonStr)
+	}
+	port := node.Port
+	if port == 0 {
+		port = defaultAPIPort
+	}
+	url := fmt.Sprintf("http://%s:%v%s", node.IP, port, path)
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+node.Secret)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		nodeErr := &NodeError{Status: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(nodeErr); err != nil {
+			return fmt.Errorf("proxy node %s responded to %s with status %d", node.IP, path, resp.StatusCode)
+		}
+		return nodeErr
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
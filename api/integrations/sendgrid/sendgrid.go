@@ -2,8 +2,10 @@ package sendgrid
 
 import (
 	cfg "eirevpn/api/config"
+	"eirevpn/api/metrics"
 	"eirevpn/api/models"
 	"fmt"
+	"time"
 
 	"github.com/sendgrid/rest"
 	"github.com/sendgrid/sendgrid-go"
@@ -24,8 +26,11 @@ func Send() *SendGrid {
 	return &sg
 }
 
-func (sg *SendGrid) makeRequest() error {
-	_, err := sendgrid.API(sg.Request)
+// makeRequest sends the mail, recording the outcome under the operation
+func (sg *SendGrid) makeRequest(operation string) error {
+	start := time.Now()
+	resp, err := sendgrid.API(sg.Request)
+	metrics.External("sendgrid", operation, start, err != nil || resp.StatusCode >= 400)
 	if err != nil {
 		fmt.Println("err", err)
 		return err
@@ -49,7 +54,7 @@ func (sg *SendGrid) RegistrationMail(user models.User, token string) error {
 	p.SetDynamicTemplateData("confirm_email_url", cfg.Load().App.Domain+"/confirm_email?token="+token)
 	m.AddPersonalizations(p)
 	sg.Request.Body = mail.GetRequestBody(m)
-	return sg.makeRequest()
+	return sg.makeRequest("registration")
 }
 
 // RegistrationMail builds the body for sending a registration email
@@ -70,7 +75,7 @@ func (sg *SendGrid) SupportRequest(email, subject, message string) error {
 	p.SetDynamicTemplateData("message", message)
 	m.AddPersonalizations(p)
 	sg.Request.Body = mail.GetRequestBody(m)
-	return sg.makeRequest()
+	return sg.makeRequest("support_request")
 }
 
 // ForgotPassword builds the body for sending an email to the user
@@ -90,5 +95,26 @@ func (sg *SendGrid) ForgotPassword(email, token string) error {
 	p.SetDynamicTemplateData("password_reset_url", "https://"+cfg.Load().App.Domain+"/forgot_pass/"+token)
 	m.AddPersonalizations(p)
 	sg.Request.Body = mail.GetRequestBody(m)
-	return sg.makeRequest()
+	return sg.makeRequest("forgot_password")
+}
+
+// AccountLocked builds the body for warning the user their account has
+// been locked after repeated failed login attempts
+func (sg *SendGrid) AccountLocked(user models.User, until time.Time) error {
+	if !cfg.Load().SendGrid.IntegrationActive {
+		return nil
+	}
+	m := mail.NewV3Mail()
+	address := "mailservice@eirevpn.ie"
+	name := "ÉireVPN Mail Service"
+	e := mail.NewEmail(name, address)
+	m.SetFrom(e)
+	m.SetTemplateID(cfg.Load().SendGrid.Templates.AccountLocked)
+	p := mail.NewPersonalization()
+	p.AddTos(mail.NewEmail(user.FirstName+" "+user.LastName, user.Email))
+	p.SetDynamicTemplateData("locked_until", until.Format(time.RFC1123))
+	p.SetDynamicTemplateData("password_reset_url", "https://"+cfg.Load().App.Domain+"/forgot_pass")
+	m.AddPersonalizations(p)
+	sg.Request.Body = mail.GetRequestBody(m)
+	return sg.makeRequest("account_locked")
 }
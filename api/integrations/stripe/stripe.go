@@ -2,10 +2,13 @@ package stripe
 
 import (
 	"eirevpn/api/config"
+	"eirevpn/api/metrics"
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/stripe/stripe-go"
 	"github.com/stripe/stripe-go/checkout/session"
@@ -20,6 +23,11 @@ import (
 
 var conf config.Config
 
+// enabled reports whether requests should be made to Stripe
+var enabled = func() bool {
+	return config.Load().Stripe.IntegrationActive
+}
+
 type WebhookEvent struct {
 	Type                        string
 	CheckoutModeSubscription    bool
@@ -34,10 +42,21 @@ type WebhookEvent struct {
 
 func Init() {
 	stripe.Key = config.Load().Stripe.SecretKey
+	// matches the default client of the library, adding metrics
+	stripe.SetHTTPClient(&http.Client{
+		Timeout:   80 * time.Second,
+		Transport: &metrics.Transport{Service: "stripe"},
+	})
+}
+
+// SetEnabled replaces the check for whether the integration is active, so
+// it can be switched on and off at runtime
+func SetEnabled(fn func() bool) {
+	enabled = fn
 }
 
 func CreatePlan(amount, intervalCount int64, interval, name, currency string) (*string, *string, error) {
-	if conf.Stripe.IntegrationActive {
+	if enabled() {
 		params := &stripe.PlanParams{
 			Amount:        &amount,
 			Interval:      &interval,
@@ -54,7 +73,7 @@ func CreatePlan(amount, intervalCount int64, interval, name, currency string) (*
 }
 
 func UpdatePlan(StripeProductID, name string) error {
-	if conf.Stripe.IntegrationActive {
+	if enabled() {
 		_, err := product.Update(StripeProductID, &stripe.ProductParams{
 			Name: &name,
 		})
@@ -66,7 +85,7 @@ func UpdatePlan(StripeProductID, name string) error {
 }
 
 func DeletePlan(StripePlanID, StripeProductID string) error {
-	if conf.Stripe.IntegrationActive {
+	if enabled() {
 		_, err := plan.Del(StripePlanID, nil)
 		if err != nil {
 			return err
@@ -80,7 +99,7 @@ func DeletePlan(StripePlanID, StripeProductID string) error {
 }
 
 func CreateSubscriptionSession(planID, customerID, userID string) (*stripe.CheckoutSession, error) {
-	if conf.Stripe.IntegrationActive {
+	if enabled() {
 		params := &stripe.CheckoutSessionParams{
 			Customer:          stripe.String(customerID),
 			ClientReferenceID: stripe.String(userID),
@@ -104,10 +123,10 @@ func CreateSubscriptionSession(planID, customerID, userID string) (*stripe.Check
 }
 
 func CreatePAYGSession(planName, customerID string, cartID uint, planAmount int64) (*stripe.CheckoutSession, error) {
-	if conf.Stripe.IntegrationActive {
+	if enabled() {
 		params := &stripe.CheckoutSessionParams{
 			Customer:          stripe.String(customerID),
-			ClientReferenceID: stripe.String(string(cartID)),
+			ClientReferenceID: stripe.String(strconv.FormatUint(uint64(cartID), 10)),
 			PaymentMethodTypes: stripe.StringSlice([]string{
 				"card",
 			}),
@@ -129,7 +148,7 @@ func CreatePAYGSession(planName, customerID string, cartID uint, planAmount int6
 }
 
 func CreateSessionSetup(customerID, subscriptionID string) (*stripe.CheckoutSession, error) {
-	if conf.Stripe.IntegrationActive {
+	if enabled() {
 		params := &stripe.CheckoutSessionParams{
 			PaymentMethodTypes: stripe.StringSlice([]string{
 				"card",
@@ -152,34 +171,34 @@ func CreateSessionSetup(customerID, subscriptionID string) (*stripe.CheckoutSess
 }
 
 func CreateCustomer(customerEmail, firstName, lastName string, userID uint) (*stripe.Customer, error) {
-	if conf.Stripe.IntegrationActive {
+	if enabled() {
 		params := &stripe.CustomerParams{
 			Name:        stripe.String(firstName + " " + lastName),
 			Email:       stripe.String(customerEmail),
 			Description: stripe.String("Customer for " + customerEmail),
 		}
-		params.AddMetadata("EireVPN_UserID", string(userID))
+		params.AddMetadata("EireVPN_UserID", strconv.FormatUint(uint64(userID), 10))
 		return customer.New(params)
 	}
 	return nil, nil
 }
 
 func GetSubscription(subscriptionId string) (*stripe.Subscription, error) {
-	if conf.Stripe.IntegrationActive {
+	if enabled() {
 		return sub.Get(subscriptionId, nil)
 	}
 	return nil, nil
 }
 
 func GetCustomer(customerId string) (*stripe.Customer, error) {
-	if conf.Stripe.IntegrationActive {
+	if enabled() {
 		return customer.Get(customerId, nil)
 	}
 	return nil, nil
 }
 
 func GetSetupIntent(setupIntentID string) (*stripe.SetupIntent, error) {
-	if conf.Stripe.IntegrationActive {
+	if enabled() {
 		return setupintent.Get(setupIntentID, nil)
 	}
 	return nil, nil
@@ -0,0 +1,180 @@
+package lockout
+
+import (
+	"eirevpn/api/config"
+	"eirevpn/api/integrations/sendgrid"
+	"eirevpn/api/logger"
+	"eirevpn/api/models"
+	"time"
+)
+
+const (
+	defaultDelayAfter     = 3
+	defaultLockAfter      = 10
+	defaultIPLockAfter    = 50
+	defaultLockoutMinutes = 15
+)
+
+// Attempt identifies who is making a login or password reset attempt.
+// Account is the email address entered and may be left empty when the
+// attempt cannot be tied to an account.
+type Attempt struct {
+	Scope   models.LockoutScope
+	Account string
+	IP      string
+}
+
+// policy sets how many failed attempts are allowed before each attempt
+// is delayed, and before attempts are refused outright for lockFor
+type policy struct {
+	delayAfter int
+	lockAfter  int
+	lockFor    time.Duration
+}
+
+func policyFor(kind models.LockoutKind) policy {
+	conf := config.Load()
+	p := policy{
+		delayAfter: conf.App.LoginDelayAfter,
+		lockAfter:  conf.App.LoginLockoutAfter,
+		lockFor:    time.Duration(conf.App.LoginLockoutMinutes) * time.Minute,
+	}
+	if p.delayAfter <= 0 {
+		p.delayAfter = defaultDelayAfter
+	}
+	if p.lockAfter <= 0 {
+		p.lockAfter = defaultLockAfter
+	}
+	if p.lockFor <= 0 {
+		p.lockFor = defaultLockoutMinutes * time.Minute
+	}
+	// many users can share an IP address so it is allowed more attempts
+	if kind == models.LockoutKindIP {
+		p.lockAfter = conf.App.IPLockoutAfter
+		if p.lockAfter <= 0 {
+			p.lockAfter = defaultIPLockAfter
+		}
+	}
+	return p
+}
+
+// Check returns how long the caller must wait before the attempt is
+// allowed, 0 meaning it may go ahead now
+func Check(a Attempt) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+	for _, l := range a.lockouts() {
+		if err := l.FindKey(); err != nil {
+			continue
+		}
+		if w := policyFor(l.Kind).wait(&l, now); w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+// Fail records a failed attempt against the account and the IP address.
+// If this locks the account its owner is emailed, user being nil when
+// the account does not exist.
+func Fail(a Attempt, user *models.User) {
+	now := time.Now()
+	for _, l := range a.lockouts() {
+		_ = l.FindKey()
+		if user != nil && l.Kind == models.LockoutKindAccount {
+			l.UserID = user.ID
+		}
+		locked := policyFor(l.Kind).fail(&l, now)
+		if err := l.Save(); err != nil {
+			logger.Log(logger.Fields{
+				Loc:   "lockout - Fail()",
+				Extra: map[string]interface{}{"Kind": l.Kind, "Key": l.Key},
+				Err:   err.Error(),
+			})
+			continue
+		}
+		if locked && user != nil && l.Kind == models.LockoutKindAccount {
+			logger.Log(logger.Fields{
+				Loc:   "lockout - Fail()",
+				Extra: map[string]interface{}{"UserID": user.ID, "Detail": "Account locked after repeated failed attempts"},
+			})
+			if err := sendgrid.Send().AccountLocked(*user, *l.LockedUntil); err != nil {
+				logger.Log(logger.Fields{
+					Loc:   "lockout - Fail()",
+					Extra: map[string]interface{}{"UserID": user.ID, "Detail": "Error sending account locked email"},
+					Err:   err.Error(),
+				})
+			}
+		}
+	}
+}
+
+// Succeed clears the failed attempts against the account. The IP address
+// keeps its count so one working login cannot reset it for an attacker.
+func Succeed(a Attempt) {
+	if a.Account == "" {
+		return
+	}
+	l := models.Lockout{Scope: a.Scope, Kind: models.LockoutKindAccount, Key: a.Account}
+	if err := l.FindKey(); err != nil {
+		return
+	}
+	if err := l.Delete(); err != nil {
+		logger.Log(logger.Fields{
+			Loc:   "lockout - Succeed()",
+			Extra: map[string]interface{}{"Key": l.Key},
+			Err:   err.Error(),
+		})
+	}
+}
+
+func (a Attempt) lockouts() []models.Lockout {
+	lockouts := []models.Lockout{{Scope: a.Scope, Kind: models.LockoutKindIP, Key: a.IP}}
+	if a.Account != "" {
+		lockouts = append(lockouts, models.Lockout{Scope: a.Scope, Kind: models.LockoutKindAccount, Key: a.Account})
+	}
+	return lockouts
+}
+
+// expired reports whether the failures are old enough to be forgotten
+func (p policy) expired(l *models.Lockout, now time.Time) bool {
+	if l.LockedUntil != nil {
+		return !l.Locked(now)
+	}
+	return now.Sub(l.LastFailure) > p.lockFor
+}
+
+// wait returns how long until the next attempt is allowed. Once the delay
+// threshold is passed the wait doubles with each failure.
+func (p policy) wait(l *models.Lockout, now time.Time) time.Duration {
+	if l.Locked(now) {
+		return l.LockedUntil.Sub(now)
+	}
+	if p.expired(l, now) || l.Failures < p.delayAfter {
+		return 0
+	}
+	delay := time.Second << uint(l.Failures-p.delayAfter)
+	if delay <= 0 || delay > p.lockFor {
+		delay = p.lockFor
+	}
+	if wait := l.LastFailure.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// fail counts a failure, returning true if it locked the key
+func (p policy) fail(l *models.Lockout, now time.Time) bool {
+	if p.expired(l, now) {
+		l.Failures = 0
+		l.LockedUntil = nil
+	}
+	l.Failures++
+	l.LastFailure = now
+	if l.Failures >= p.lockAfter && !l.Locked(now) {
+		until := now.Add(p.lockFor)
+		l.LockedUntil = &until
+		return true
+	}
+	return false
+}
@@ -1,83 +1,227 @@
 package logger
 
 import (
+	"eirevpn/api/config"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
-	// . "github.com/logrusorgru/aurora"
 )
 
-var loggingEnabled bool
+// Level is the severity of a log entry
+type Level int
 
+const (
+	// LevelError is the zero value so entries which do not set a level,
+	// which are mostly failed requests, are logged as errors
+	LevelError Level = iota
+	LevelWarn
+	LevelInfo
+	LevelDebug
+)
+
+// LogFilePath is used when no log file is configured
 const LogFilePath = "./logs/default.log"
 
-type Fields struct {
-	Code  string
-	Loc   string
-	Err   string
-	Extra map[string]interface{}
+var levelNames = map[Level]string{
+	LevelError: "ERROR",
+	LevelWarn:  "WARN",
+	LevelInfo:  "INFO",
+	LevelDebug: "DEBUG",
 }
 
-func Init(enabled bool) {
-	loggingEnabled = enabled
+func (l Level) String() string {
+	return levelNames[l]
 }
 
-func createLogFile() {
-	// detect if file exists
-	var _, err = os.Stat(LogFilePath)
-	// create file if not exists
-	if os.IsNotExist(err) {
-		var file, err = os.Create(LogFilePath)
-		if err != nil {
-			fmt.Println(err.Error())
+// ParseLevel reads a level name, defaulting to info
+func ParseLevel(name string) Level {
+	for l, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			return l
 		}
-		defer file.Close()
 	}
-	fmt.Println("==> created log file", LogFilePath)
+	return LevelInfo
 }
 
-func writeFile(msg string) {
-	// open file using READ & WRITE permission
-	var file, err = os.OpenFile(LogFilePath, os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Println(err.Error())
+type Fields struct {
+	Level     Level
+	RequestID string
+	Code      string
+	Loc       string
+	Err       string
+	Extra     map[string]interface{}
+}
+
+var (
+	mu             sync.Mutex
+	loggingEnabled bool
+	minLevel       = LevelInfo
+	jsonFormat     bool
+	out            io.Writer = os.Stdout
+	file           *rotatingFile
+)
+
+// Init sets up the logger from the Logging section of the config. Entries
+// are written to stdout and the log file, which is rotated once it grows
+// too large or too old.
+func Init(enabled bool) {
+	conf := config.Load().Logging
+
+	mu.Lock()
+	defer mu.Unlock()
+	loggingEnabled = enabled
+	minLevel = ParseLevel(conf.Level)
+	jsonFormat = strings.EqualFold(conf.Format, "json")
+	if file != nil {
+		file.Close()
+		file = nil
+	}
+	out = os.Stdout
+	if !enabled {
+		return
 	}
-	// defer file.Close()
 
-	// write to file
-	_, err = file.WriteString(msg + "\n")
-	if err != nil {
-		fmt.Println(err.Error())
+	path := conf.File
+	if path == "" {
+		path = LogFilePath
 	}
-	// save changes
-	err = file.Sync()
+	f, err := openRotatingFile(path, conf.MaxSizeMB, conf.MaxAgeDays, conf.MaxBackups)
 	if err != nil {
 		fmt.Println(err.Error())
+		return
+	}
+	file = f
+	out = io.MultiWriter(os.Stdout, f)
+}
+
+// Close flushes and closes the log file. Later entries are only written
+// to stdout.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	out = os.Stdout
+	if file == nil {
+		return nil
 	}
+	err := file.Sync()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	file = nil
+	return err
 }
 
+// Writer returns a writer for other loggers such as gin's to share. It
+// always writes wherever entries currently go, so it stays valid when Init
+// reopens the log file on a config reload.
+func Writer() io.Writer {
+	return sharedWriter{}
+}
+
+type sharedWriter struct{}
+
+func (sharedWriter) Write(p []byte) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return out.Write(p)
+}
+
+// Enabled reports whether entries at the level are written
+func Enabled(level Level) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return loggingEnabled && level <= minLevel
+}
+
+// Log writes the entry at its level, error if none is set
 func Log(fields Fields) {
-	if loggingEnabled {
-		var msg strings.Builder
-		msg.WriteString(fmt.Sprintf("%s %v | ", "ERROR", time.Now().Format("2006-01-02 15:04:05")))
-		if fields.Err != "" {
-			msg.WriteString(fmt.Sprintf("%s: %v | ", "ERROR", fields.Err))
-		}
-		if fields.Code != "" {
-			msg.WriteString(fmt.Sprintf("%s: %v | ", "CODE", fields.Code))
-		}
-		for k, v := range fields.Extra {
-			if k == "Err" {
-				v = strings.Replace(v.(string), "\n", " ", -1)
-			}
-			field := fmt.Sprintf("%s: %v | ", k, v)
-			msg.WriteString(field)
-		}
-		if fields.Loc != "" {
-			msg.WriteString(fmt.Sprintf("%s: %v", "LOC", fields.Loc))
-		}
-		fmt.Println(msg.String())
-		writeFile(msg.String())
+	mu.Lock()
+	defer mu.Unlock()
+	if !loggingEnabled || fields.Level > minLevel {
+		return
+	}
+	if jsonFormat {
+		fmt.Fprintln(out, formatJSON(time.Now(), fields))
+	} else {
+		fmt.Fprintln(out, formatText(time.Now(), fields))
+	}
+}
+
+func Debug(fields Fields) {
+	fields.Level = LevelDebug
+	Log(fields)
+}
+
+func Info(fields Fields) {
+	fields.Level = LevelInfo
+	Log(fields)
+}
+
+func Warn(fields Fields) {
+	fields.Level = LevelWarn
+	Log(fields)
+}
+
+func Error(fields Fields) {
+	fields.Level = LevelError
+	Log(fields)
+}
+
+func formatText(t time.Time, fields Fields) string {
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("%s %v | ", fields.Level, t.Format("2006-01-02 15:04:05")))
+	if fields.RequestID != "" {
+		msg.WriteString(fmt.Sprintf("%s: %v | ", "REQUEST", fields.RequestID))
+	}
+	if fields.Err != "" {
+		msg.WriteString(fmt.Sprintf("%s: %v | ", "ERROR", strings.Replace(fields.Err, "\n", " ", -1)))
+	}
+	if fields.Code != "" {
+		msg.WriteString(fmt.Sprintf("%s: %v | ", "CODE", fields.Code))
+	}
+	keys := make([]string, 0, len(fields.Extra))
+	for k := range fields.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		msg.WriteString(fmt.Sprintf("%s: %v | ", k, fields.Extra[k]))
+	}
+	if fields.Loc != "" {
+		msg.WriteString(fmt.Sprintf("%s: %v", "LOC", fields.Loc))
+	}
+	return msg.String()
+}
+
+func formatJSON(t time.Time, fields Fields) string {
+	entry := map[string]interface{}{
+		"time":  t.Format(time.RFC3339Nano),
+		"level": strings.ToLower(fields.Level.String()),
+	}
+	if fields.RequestID != "" {
+		entry["request_id"] = fields.RequestID
+	}
+	if fields.Code != "" {
+		entry["code"] = fields.Code
+	}
+	if fields.Loc != "" {
+		entry["loc"] = fields.Loc
+	}
+	if fields.Err != "" {
+		entry["err"] = fields.Err
+	}
+	if len(fields.Extra) > 0 {
+		entry["extra"] = fields.Extra
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		// fall back to the text format rather than lose the entry
+		return formatText(t, fields)
 	}
+	return string(b)
 }
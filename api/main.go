@@ -1,33 +1,138 @@
 package main
 
 import (
+	"context"
 	cfg "eirevpn/api/config"
+	"eirevpn/api/handlers/health"
+	"eirevpn/api/healthcheck"
 	"eirevpn/api/integrations"
 	"eirevpn/api/logger"
-	"eirevpn/api/models"
+	"eirevpn/api/migrations"
 	"eirevpn/api/router"
+	"flag"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"reflect"
+	"syscall"
+	"time"
 
 	"eirevpn/api/db"
 )
 
+// defaultShutdownTimeout is the number of seconds in-flight requests
+// are given to finish once a shutdown signal is received
+const defaultShutdownTimeout = 30
+
 func main() {
 	debugMode := false
 	logging := true
 
 	appPath, _ := os.Getwd()
-	filename, _ := filepath.Abs(appPath + "/config.yaml")
-	cfg.Init(filename)
+	defaultConfig := os.Getenv(cfg.EnvPrefix + "_CONFIG")
+	if defaultConfig == "" {
+		defaultConfig = appPath + "/config.yaml"
+	}
+	configPath := flag.String("config", defaultConfig, "path to the config file")
+	flag.Parse()
+	filename, _ := filepath.Abs(*configPath)
+	if err := cfg.Init(filename); err != nil {
+		log.Fatal(err)
+	}
 	conf := cfg.Load()
 
+	if flag.Arg(0) == "migrate" {
+		if err := db.Init(conf, debugMode); err != nil {
+			log.Fatal(err)
+		}
+		err := migrations.Command(db.GetDB(), flag.Args()[1:], os.Stdout)
+		db.CloseDB()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	integrations.Init()
 
-	db.Init(conf, debugMode, models.Get())
+	if err := db.Init(conf, debugMode); err != nil {
+		log.Fatal(err)
+	}
+	if err := migrations.Check(db.GetDB()); err != nil {
+		log.Fatal(err)
+	}
 
 	logger.Init(logging)
 
+	cfg.Subscribe(func(prev, next cfg.Config) {
+		if !reflect.DeepEqual(prev.Logging, next.Logging) {
+			logger.Init(logging)
+		}
+		if prev.Stripe.SecretKey != next.Stripe.SecretKey {
+			integrations.Init()
+		}
+		logger.Info(logger.Fields{Loc: "main.go - main()", Extra: map[string]interface{}{"Detail": "Config reloaded"}})
+	})
+	stopWatch := make(chan struct{})
+	go cfg.Watch(stopWatch)
+
+	stopHealthcheck := make(chan struct{})
+	healthcheckDone := make(chan struct{})
+	go func() {
+		healthcheck.Start(stopHealthcheck)
+		close(healthcheckDone)
+	}()
+
 	r := router.Init(logging)
 
-	r.Run(":" + conf.App.Port)
+	srv := &http.Server{Addr: ":" + conf.App.Port, Handler: r}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	var exitCode int
+	select {
+	case err := <-serveErr:
+		logger.Error(logger.Fields{Loc: "main.go - main()", Err: err.Error()})
+		exitCode = 1
+	case sig := <-quit:
+		logger.Info(logger.Fields{Loc: "main.go - main()", Extra: map[string]interface{}{"Detail": "Shutting down", "Signal": sig.String()}})
+	}
+
+	// stop accepting requests and let those in flight, such as webhooks
+	// being processed, finish before the database is closed
+	health.Drain()
+	timeout := cfg.Load().App.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Warn(logger.Fields{
+			Loc:   "main.go - main()",
+			Extra: map[string]interface{}{"Detail": "Requests still running after the shutdown timeout were dropped"},
+			Err:   err.Error(),
+		})
+		srv.Close()
+	}
+
+	close(stopWatch)
+
+	// a probe of the servers may be running, give it the rest of the timeout
+	close(stopHealthcheck)
+	select {
+	case <-healthcheckDone:
+	case <-ctx.Done():
+	}
+	cancel()
+
+	db.CloseDB()
+	logger.Info(logger.Fields{Loc: "main.go - main()", Extra: map[string]interface{}{"Detail": "Shutdown complete"}})
+	logger.Close()
+	os.Exit(exitCode)
 }
@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"eirevpn/api/config"
+	"eirevpn/api/errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eirevpn_http_requests_total",
+		Help: "HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "eirevpn_http_request_duration_seconds",
+		Help: "Time taken to handle HTTP requests, by method and route.",
+	}, []string{"method", "route"})
+	dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "eirevpn_db_query_duration_seconds",
+		Help: "Time taken by database queries, by operation and table.",
+	}, []string{"operation", "table"})
+	externalRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eirevpn_external_requests_total",
+		Help: "Requests made to third party services, by service, operation and outcome.",
+	}, []string{"service", "operation", "outcome"})
+	externalDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "eirevpn_external_request_duration_seconds",
+		Help: "Time taken by requests to third party services, by service and operation.",
+	}, []string{"service", "operation"})
+	serverConnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eirevpn_server_connects_total",
+		Help: "Proxy credentials issued to users connecting, by server.",
+	}, []string{"server_id"})
+
+	// activeSessions holds the func() float64 which counts the devices
+	// recently connected to a server, set by the router
+	activeSessions atomic.Value
+	_              = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "eirevpn_active_sessions",
+		Help: "Devices connected to a server within the device session timeout.",
+	}, func() float64 {
+		if fn, ok := activeSessions.Load().(func() float64); ok {
+			return fn()
+		}
+		return 0
+	})
+)
+
+// SetActiveSessions sets the function which counts the devices recently
+// connected to a server
+func SetActiveSessions(fn func() float64) {
+	activeSessions.Store(fn)
+}
+
+// exposition serves the registered metrics
+var exposition = promhttp.Handler()
+
+var (
+	routesMu sync.RWMutex
+	routes   = map[string]string{}
+)
+
+// SetRoutes records the path each handler is registered on so requests
+// are labelled by route rather than by their raw path, which would give
+// a new series for every id
+func SetRoutes(info gin.RoutesInfo) {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	routes = map[string]string{}
+	for _, r := range info {
+		key := r.Method + " " + r.Handler
+		if _, ok := routes[key]; !ok {
+			routes[key] = r.Path
+		}
+	}
+}
+
+func route(c *gin.Context) string {
+	routesMu.RLock()
+	defer routesMu.RUnlock()
+	if path, ok := routes[c.Request.Method+" "+c.HandlerName()]; ok {
+		return path
+	}
+	return "unmatched"
+}
+
+// Middleware counts every request and how long it took
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		r := route(c)
+		httpRequests.WithLabelValues(c.Request.Method, r, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, r).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the metrics in the Prometheus exposition format. Scrapers
+// must send the metrics token as a bearer token, and metrics are not served
+// at all until a token is configured since the route is public.
+func Handler(c *gin.Context) {
+	conf := config.Load()
+	if !conf.Metrics.Enabled || conf.Metrics.Token == "" {
+		c.AbortWithStatusJSON(errors.MetricsDisabled.Status, errors.MetricsDisabled)
+		return
+	}
+	bearer := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(bearer), []byte(conf.Metrics.Token)) != 1 {
+		c.AbortWithStatusJSON(errors.MetricsTokenInvalid.Status, errors.MetricsTokenInvalid)
+		return
+	}
+	exposition.ServeHTTP(c.Writer, c.Request)
+}
+
+// ServerConnect counts a user connecting to the server
+func ServerConnect(serverID uint) {
+	serverConnects.WithLabelValues(strconv.FormatUint(uint64(serverID), 10)).Inc()
+}
+
+// External records the outcome of a request made to a third party service
+func External(service, operation string, start time.Time, failed bool) {
+	outcome := "success"
+	if failed {
+		outcome = "error"
+	}
+	externalRequests.WithLabelValues(service, operation, outcome).Inc()
+	externalDuration.WithLabelValues(service, operation).Observe(time.Since(start).Seconds())
+}
+
+// Transport records the outcome of every request sent through it. The
+// operation is the first path segment after the API version, so
+// /v1/customers/cus_123 is recorded as customers.
+type Transport struct {
+	Service string
+	Base    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	External(t.Service, req.Method+" "+operation(req.URL.Path), start, err != nil || resp.StatusCode >= 400)
+	return resp, err
+}
+
+func operation(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 1 {
+		return parts[1]
+	}
+	return parts[0]
+}
+
+// RegisterCallbacks times every query gorm makes
+func RegisterCallbacks(db *gorm.DB) {
+	cb := db.Callback()
+	cb.Create().Before("gorm:create").Register("metrics:before_create", startTimer)
+	cb.Create().After("gorm:create").Register("metrics:after_create", observer("create"))
+	cb.Query().Before("gorm:query").Register("metrics:before_query", startTimer)
+	cb.Query().After("gorm:query").Register("metrics:after_query", observer("query"))
+	cb.Update().Before("gorm:update").Register("metrics:before_update", startTimer)
+	cb.Update().After("gorm:update").Register("metrics:after_update", observer("update"))
+	cb.Delete().Before("gorm:delete").Register("metrics:before_delete", startTimer)
+	cb.Delete().After("gorm:delete").Register("metrics:after_delete", observer("delete"))
+	cb.RowQuery().Before("gorm:row_query").Register("metrics:before_row_query", startTimer)
+	cb.RowQuery().After("gorm:row_query").Register("metrics:after_row_query", observer("row_query"))
+}
+
+const timerKey = "metrics:start"
+
+func startTimer(scope *gorm.Scope) {
+	scope.Set(timerKey, time.Now())
+}
+
+func observer(operation string) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		v, ok := scope.Get(timerKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+		dbDuration.WithLabelValues(operation, scope.TableName()).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,276 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// baselineTable is a table as it was when the schema was managed by
+// AutoMigrate. Every table has an id primary key and the columns of
+// models.BaseModel, which are not repeated here.
+type baselineTable struct {
+	name    string
+	columns []string
+	unique  []string
+}
+
+// baselineColumns are the columns of models.BaseModel after the id
+var baselineColumns = []string{
+	"created_at timestamp with time zone",
+	"updated_at timestamp with time zone",
+	"deleted_at timestamp with time zone",
+}
+
+// baseline is the schema the first migration creates. It is a snapshot of
+// the models when migrations replaced AutoMigrate and must not change,
+// changes to the models need a migration of their own.
+var baseline = []baselineTable{
+	{
+		name: "plans",
+		columns: []string{
+			"name text",
+			"amount bigint",
+			"interval text",
+			"interval_count bigint",
+			"plan_type text",
+			"currency text",
+			"data_allowance bigint",
+			"max_devices integer",
+			"stripe_plan_id text",
+			"stripe_product_id text",
+		},
+	},
+	{
+		name: "users",
+		columns: []string{
+			"first_name text",
+			"last_name text",
+			"email text",
+			"password text",
+			"stripe_customer_id text",
+			"type text",
+			"role text",
+			"email_confirmed boolean",
+			"two_factor_enabled boolean",
+			"totp_secret text",
+			"totp_last_step bigint",
+		},
+	},
+	{
+		name: "user_plans",
+		columns: []string{
+			"user_id integer",
+			"plan_id integer",
+			"active boolean",
+			"start_date timestamp with time zone",
+			"expiry_date timestamp with time zone",
+			"data_allowance bigint",
+			"data_remaining bigint",
+			"quota_period_from timestamp with time zone",
+		},
+	},
+	{
+		name: "user_app_sessions",
+		columns: []string{
+			"user_id integer",
+			"identifier text",
+			"device_name text",
+			"user_agent text",
+			"ip text",
+			"last_used timestamp with time zone",
+		},
+	},
+	{
+		name: "carts",
+		columns: []string{
+			"user_id integer",
+			"plan_id integer",
+		},
+	},
+	{
+		name: "servers",
+		columns: []string{
+			"country text",
+			"country_code text",
+			"type text",
+			"ip text",
+			"port integer",
+			"socks_port integer",
+			"username text",
+			"password text",
+			"image_path text",
+			"api_port integer",
+			"api_secret text",
+			"healthy boolean",
+			"latency bigint",
+			"last_seen timestamp with time zone",
+			"last_checked timestamp with time zone",
+			"failed_checks integer",
+			"max_users integer",
+			"connections integer",
+			"throughput bigint",
+			"cpu_load numeric",
+			"load_reported timestamp with time zone",
+		},
+	},
+	{
+		name: "email_tokens",
+		columns: []string{
+			"user_id integer",
+			"token text",
+		},
+	},
+	{
+		name: "forgot_passwords",
+		columns: []string{
+			"user_id integer",
+			"token text",
+		},
+	},
+	{
+		name: "connections",
+		columns: []string{
+			"user_id integer",
+			"server_id integer",
+			"server_country text",
+			"bytes_up bigint",
+			"bytes_down bigint",
+		},
+	},
+	{
+		name: "proxy_credentials",
+		columns: []string{
+			"user_id integer",
+			"server_id integer",
+			"username text",
+			"password text",
+			"suspended boolean",
+			"max_devices integer",
+		},
+	},
+	{
+		name: "data_usages",
+		columns: []string{
+			"user_id integer",
+			"server_id integer",
+			"connection_id integer",
+			"bytes_up bigint",
+			"bytes_down bigint",
+		},
+	},
+	{
+		name: "device_sessions",
+		columns: []string{
+			"user_id integer",
+			"device_id text",
+			"server_id integer",
+			"ip text",
+			"user_agent text",
+			"last_active timestamp with time zone",
+		},
+	},
+	{
+		name: "recovery_codes",
+		columns: []string{
+			"user_id integer",
+			"hash text",
+			"used_at timestamp with time zone",
+		},
+	},
+	{
+		name: "lockouts",
+		columns: []string{
+			"scope text",
+			"kind text",
+			"key text",
+			"user_id integer",
+			"failures integer",
+			"last_failure timestamp with time zone",
+			"locked_until timestamp with time zone",
+		},
+	},
+	{
+		name: "api_tokens",
+		columns: []string{
+			"user_id integer",
+			"name text",
+			"prefix text",
+			"hash text",
+			"scopes text",
+			"last_used timestamp with time zone",
+			"last_used_ip text",
+			"expires_at timestamp with time zone",
+		},
+	},
+	{
+		name: "audit_logs",
+		columns: []string{
+			"actor_id integer",
+			"action text",
+			"target_type text",
+			"target_id integer",
+			"changes text",
+			"ip text",
+		},
+	},
+	{
+		name: "settings",
+		columns: []string{
+			"key text",
+			"value text",
+			"version integer",
+			"updated_by integer",
+		},
+		unique: []string{"key"},
+	},
+	{
+		name: "setting_changes",
+		columns: []string{
+			"key text",
+			"before text",
+			"after text",
+			"version integer",
+			"changed_by integer",
+		},
+	},
+}
+
+// baselineUp creates the tables which do not exist. Databases created by
+// AutoMigrate already have them, so any column or index they are missing
+// is added the way AutoMigrate would have.
+func baselineUp(tx *gorm.DB) error {
+	for _, t := range baseline {
+		var columns []string
+		for _, column := range append(append([]string{"id serial"}, baselineColumns...), t.columns...) {
+			// names are quoted as some, such as interval, are keywords
+			parts := strings.SplitN(column, " ", 2)
+			columns = append(columns, fmt.Sprintf("%q %s", parts[0], parts[1]))
+		}
+		statements := []string{
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s, PRIMARY KEY (id))", t.name, strings.Join(columns, ", ")),
+		}
+		for _, column := range columns[1:] {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s", t.name, column))
+		}
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_deleted_at ON %s (deleted_at)", t.name, t.name))
+		for _, column := range t.unique {
+			statements = append(statements, fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS uix_%s_%s ON %s (%q)", t.name, column, t.name, column))
+		}
+		if err := SQL(statements...)(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// baselineDown drops the tables the baseline created
+func baselineDown(tx *gorm.DB) error {
+	for i := len(baseline) - 1; i >= 0; i-- {
+		if err := tx.Exec("DROP TABLE IF EXISTS " + baseline[i].name).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Command runs the migrate command with its arguments, writing the result
+// to w: up applies every pending migration, down rolls back the last one,
+// or the last n, and status lists them
+func Command(db *gorm.DB, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+	switch args[0] {
+	case "up":
+		done, err := Up(db)
+		for _, m := range done {
+			fmt.Fprintf(w, "Applied %d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(w, "Database schema is up to date")
+		}
+		return err
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations to roll back: %s", args[1])
+			}
+		}
+		done, err := Down(db, n)
+		for _, m := range done {
+			fmt.Fprintf(w, "Rolled back %d %s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		states, err := Status(db)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range states {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
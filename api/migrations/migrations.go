@@ -0,0 +1,206 @@
+package migrations
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// table records the version of every migration applied to the database
+const table = "schema_migrations"
+
+// lockID is the key of the advisory lock held while migrating, so only one
+// instance migrates at a time
+const lockID = 7310285
+
+// Migration changes the schema from the previous version. Down must undo
+// everything Up does. Both run inside a transaction which is rolled back
+// if they fail.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// State is a migration and when it was applied, if it has been
+type State struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type applied struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// SQL returns a step which runs the statements in order
+func SQL(statements ...string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Latest returns the version the schema is at once every migration has
+// been applied
+func Latest() int {
+	return all[len(all)-1].Version
+}
+
+// Up applies every pending migration in order, returning those applied
+func Up(db *gorm.DB) ([]Migration, error) {
+	var done []Migration
+	for {
+		m, ok, err := step(db, true)
+		if err != nil || !ok {
+			return done, err
+		}
+		done = append(done, m)
+	}
+}
+
+// Down rolls back the last n migrations applied, returning those rolled
+// back
+func Down(db *gorm.DB, n int) ([]Migration, error) {
+	var done []Migration
+	for len(done) < n {
+		m, ok, err := step(db, false)
+		if err != nil || !ok {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Status returns every migration and when it was applied
+func Status(db *gorm.DB) ([]State, error) {
+	if err := createTable(db); err != nil {
+		return nil, err
+	}
+	versions, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]State, len(all))
+	for i, m := range all {
+		states[i].Migration = m
+		if a, ok := versions[m.Version]; ok {
+			appliedAt := a.AppliedAt
+			states[i].AppliedAt = &appliedAt
+		}
+	}
+	return states, nil
+}
+
+// Check returns an error when a migration has not been applied, so the API
+// refuses to start against a schema it does not match
+func Check(db *gorm.DB) error {
+	states, err := Status(db)
+	if err != nil {
+		return err
+	}
+	var pending []int
+	for _, s := range states {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Version)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is missing migrations %v, run the migrate command to apply them", pending)
+	}
+	return nil
+}
+
+// step applies the next pending migration, or rolls back the last applied,
+// in its own transaction. It reports false when there is nothing to do.
+func step(db *gorm.DB, up bool) (Migration, bool, error) {
+	if err := createTable(db); err != nil {
+		return Migration{}, false, err
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		return Migration{}, false, tx.Error
+	}
+	// the applied versions are read after taking the lock as another
+	// instance may have migrated while we waited
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockID).Error; err != nil {
+		tx.Rollback()
+		return Migration{}, false, err
+	}
+	versions, err := appliedVersions(tx)
+	if err != nil {
+		tx.Rollback()
+		return Migration{}, false, err
+	}
+
+	m, ok := next(versions, up)
+	if !ok {
+		tx.Rollback()
+		return Migration{}, false, nil
+	}
+	if up {
+		err = m.Up(tx)
+		if err == nil {
+			err = tx.Exec("INSERT INTO "+table+" (version, name, applied_at) VALUES (?, ?, ?)", m.Version, m.Name, time.Now()).Error
+		}
+	} else {
+		err = m.Down(tx)
+		if err == nil {
+			err = tx.Exec("DELETE FROM "+table+" WHERE version = ?", m.Version).Error
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return Migration{}, false, fmt.Errorf("migration %d %s: %v", m.Version, m.Name, err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return Migration{}, false, err
+	}
+	return m, true, nil
+}
+
+// next returns the first migration not applied when migrating up, or the
+// last one applied when rolling back
+func next(versions map[int]applied, up bool) (Migration, bool) {
+	if up {
+		for _, m := range all {
+			if _, ok := versions[m.Version]; !ok {
+				return m, true
+			}
+		}
+		return Migration{}, false
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		if _, ok := versions[all[i].Version]; ok {
+			return all[i], true
+		}
+	}
+	return Migration{}, false
+}
+
+func createTable(db *gorm.DB) error {
+	return db.Exec("CREATE TABLE IF NOT EXISTS " + table + " (" +
+		"version integer PRIMARY KEY, " +
+		"name text NOT NULL, " +
+		"applied_at timestamp with time zone NOT NULL)").Error
+}
+
+func appliedVersions(db *gorm.DB) (map[int]applied, error) {
+	var rows []applied
+	if err := db.Table(table).Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	versions := make(map[int]applied, len(rows))
+	for _, r := range rows {
+		versions[r.Version] = r
+	}
+	return versions, nil
+}
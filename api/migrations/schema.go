@@ -0,0 +1,31 @@
+package migrations
+
+// all lists the migrations in the order they are applied. Versions must
+// increase and a migration must never be edited once released, add a new
+// one instead.
+var all = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up:      baselineUp,
+		Down:    baselineDown,
+	},
+	{
+		Version: 2,
+		Name:    "index setting changes by key",
+		Up:      SQL(`CREATE INDEX IF NOT EXISTS idx_setting_changes_key ON setting_changes (key, id DESC)`),
+		Down:    SQL(`DROP INDEX IF EXISTS idx_setting_changes_key`),
+	},
+	{
+		Version: 3,
+		Name:    "add disabled to servers",
+		Up:      SQL(`ALTER TABLE servers ADD COLUMN IF NOT EXISTS disabled boolean NOT NULL DEFAULT false`),
+		Down:    SQL(`ALTER TABLE servers DROP COLUMN IF EXISTS disabled`),
+	},
+	{
+		Version: 4,
+		Name:    "add users to servers",
+		Up:      SQL(`ALTER TABLE servers ADD COLUMN IF NOT EXISTS users integer NOT NULL DEFAULT 0`),
+		Down:    SQL(`ALTER TABLE servers DROP COLUMN IF EXISTS users`),
+	},
+}
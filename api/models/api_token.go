@@ -0,0 +1,150 @@
+package models
+
+import (
+	"crypto/sha256"
+	"eirevpn/api/util/random"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type APITokenScope string
+type AllAPITokens []APIToken
+
+var (
+	// APITokenScopeRead allows GET requests to the private routes
+	APITokenScopeRead APITokenScope = "read"
+	// APITokenScopeWrite allows any request to the private routes
+	APITokenScopeWrite APITokenScope = "write"
+	// APITokenScopeAdmin allows any request to the protected routes
+	APITokenScopeAdmin APITokenScope = "admin"
+)
+
+// apiTokenPrefix marks a string as one of our tokens, making leaked
+// tokens easy to search for
+const apiTokenPrefix = "evpn_"
+
+// apiTokenLastUsedInterval stops every request writing to the token
+const apiTokenLastUsedInterval = time.Minute
+
+// APIToken is a named token a user can send as a bearer token in place
+// of their session cookies. Only a hash of the token is stored.
+type APIToken struct {
+	BaseModel
+	UserID     uint       `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     string     `json:"scopes"`
+	LastUsed   *time.Time `json:"last_used"`
+	LastUsedIP string     `json:"last_used_ip"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+func (t *APIToken) Find() error {
+	if err := db().Where(&t).First(&t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindByToken fetches the token matching the plain text token
+func (t *APIToken) FindByToken(token string) error {
+	if err := db().Where("hash = ?", hashAPIToken(token)).First(&t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// New generates the token, stores its hash and returns the plain text
+// token. This is the only time it is available.
+func (t *APIToken) New(userID uint) (string, error) {
+	b, err := random.GenerateRandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	token := apiTokenPrefix + hex.EncodeToString(b)
+	t.UserID = userID
+	t.Prefix = token[:len(apiTokenPrefix)+8]
+	t.Hash = hashAPIToken(token)
+	if err := db().Create(&t).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (t *APIToken) Delete() error {
+	if err := db().Delete(&t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Expired reports whether the token has passed its expiry date
+func (t *APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// HasScope reports whether the token was granted the scope. Write
+// access implies read access.
+func (t *APIToken) HasScope(scope APITokenScope) bool {
+	for _, s := range strings.Split(t.Scopes, ",") {
+		granted := APITokenScope(strings.TrimSpace(s))
+		if granted == scope || (scope == APITokenScopeRead && granted == APITokenScopeWrite) {
+			return true
+		}
+	}
+	return false
+}
+
+// Touch records that the token has been used from the ip
+func (t *APIToken) Touch(ip string) error {
+	now := time.Now()
+	if t.LastUsed != nil && now.Sub(*t.LastUsed) < apiTokenLastUsedInterval && t.LastUsedIP == ip {
+		return nil
+	}
+	t.LastUsed = &now
+	t.LastUsedIP = ip
+	if err := db().Model(&t).UpdateColumns(map[string]interface{}{
+		"last_used":    now,
+		"last_used_ip": ip,
+	}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindAll fetches every token of the user
+func (at *AllAPITokens) FindAll(userID uint) error {
+	if err := db().Where("user_id = ?", userID).Order("created_at desc").Find(&at).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteAll revokes every token of the user
+func (at *AllAPITokens) DeleteAll(userID uint) error {
+	if err := db().Where("user_id = ?", userID).Delete(&APIToken{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// BeforeCreate sets the CreatedAt column to the current time
+func (t *APIToken) BeforeCreate(scope *gorm.Scope) error {
+	scope.SetColumn("CreatedAt", time.Now())
+	return nil
+}
+
+// BeforeUpdate sets the UpdatedAt column to the current time
+func (t *APIToken) BeforeUpdate(scope *gorm.Scope) error {
+	scope.SetColumn("UpdatedAt", time.Now())
+	return nil
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type AuditAction string
+type AllAuditLogs []AuditLog
+
+var (
+	AuditActionLogin            AuditAction = "user.login"
+	AuditActionLoginFailed      AuditAction = "user.login_failed"
+	AuditActionLogout           AuditAction = "user.logout"
+	AuditActionUserCreate       AuditAction = "user.create"
+	AuditActionUserUpdate       AuditAction = "user.update"
+	AuditActionUserDelete       AuditAction = "user.delete"
+	AuditActionUserRole         AuditAction = "user.role"
+	AuditActionPasswordChange   AuditAction = "user.password_change"
+	AuditActionPasswordReset    AuditAction = "user.password_reset"
+	AuditActionSessionDelete    AuditAction = "user.session_delete"
+	AuditActionTwoFactorEnable  AuditAction = "user.2fa_enable"
+	AuditActionTwoFactorDisable AuditAction = "user.2fa_disable"
+	AuditActionAPITokenCreate   AuditAction = "api_token.create"
+	AuditActionAPITokenDelete   AuditAction = "api_token.delete"
+	AuditActionLockoutClear     AuditAction = "lockout.clear"
+	AuditActionPlanCreate       AuditAction = "plan.create"
+	AuditActionPlanUpdate       AuditAction = "plan.update"
+	AuditActionPlanDelete       AuditAction = "plan.delete"
+	AuditActionServerCreate     AuditAction = "server.create"
+	AuditActionServerUpdate     AuditAction = "server.update"
+	AuditActionServerDelete     AuditAction = "server.delete"
+	AuditActionServerRevoke     AuditAction = "server.revoke_credentials"
+	AuditActionServerRotate     AuditAction = "server.rotate_credentials"
+	AuditActionUserPlanCreate   AuditAction = "userplan.create"
+	AuditActionUserPlanUpdate   AuditAction = "userplan.update"
+	AuditActionUserPlanDelete   AuditAction = "userplan.delete"
+	AuditActionSettingsUpdate   AuditAction = "settings.update"
+)
+
+// AuditChange holds the value of a field before and after an action
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChanges maps each field changed by an action to its values. It is
+// stored as JSON text.
+type AuditChanges map[string]AuditChange
+
+func (ac AuditChanges) Value() (driver.Value, error) {
+	if ac == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(ac)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (ac *AuditChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*ac = AuditChanges{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, ac)
+	case string:
+		return json.Unmarshal([]byte(v), ac)
+	}
+	return fmt.Errorf("cannot scan %T into AuditChanges", value)
+}
+
+// AuditLog records who performed an action, what it was performed on and
+// what it changed. ActorID is 0 when the actor is unknown, such as a
+// failed login.
+type AuditLog struct {
+	BaseModel
+	ActorID    uint         `json:"actor_id"`
+	Action     AuditAction  `json:"action"`
+	TargetType string       `json:"target_type"`
+	TargetID   uint         `json:"target_id"`
+	Changes    AuditChanges `json:"changes" gorm:"type:text"`
+	IP         string       `json:"ip"`
+}
+
+// AuditFilter narrows the audit logs returned. Zero values match anything.
+type AuditFilter struct {
+	ActorID    uint
+	Action     AuditAction
+	TargetType string
+	TargetID   uint
+	Since      time.Time
+	Until      time.Time
+}
+
+func (a *AuditLog) Create() error {
+	if err := db().Create(&a).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (f AuditFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.ActorID != 0 {
+		query = query.Where("actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		query = query.Where("action = ?", f.Action)
+	}
+	if f.TargetType != "" {
+		query = query.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != 0 {
+		query = query.Where("target_id = ?", f.TargetID)
+	}
+	if !f.Since.IsZero() {
+		query = query.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		query = query.Where("created_at < ?", f.Until)
+	}
+	return query
+}
+
+// FindAll fetches the audit logs matching the filter, newest first
+func (al *AllAuditLogs) FindAll(offset int, filter AuditFilter) error {
+	limit := 20
+	if err := filter.apply(db()).Order("created_at desc, id desc").Limit(limit).Offset(offset).Find(&al).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (al *AllAuditLogs) Count(filter AuditFilter) (*int, error) {
+	var count int
+	if err := filter.apply(db().Model(&AuditLog{})).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+// BeforeCreate sets the CreatedAt column to the current time
+func (a *AuditLog) BeforeCreate(scope *gorm.Scope) error {
+	scope.SetColumn("CreatedAt", time.Now())
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 )
 
 type AllConnections []Connection
+type AllConnectionStats []ConnectionStats
 
 // Connections contains the email confirmation tokens with a one to one mapping
 // to the user
@@ -15,6 +16,8 @@ type Connection struct {
 	UserID        uint   `json:"user_id"`
 	ServerID      uint   `json:"server_id"`
 	ServerCountry string `json:"server_country"`
+	BytesUp       int64  `json:"bytes_up"`
+	BytesDown     int64  `json:"bytes_down"`
 }
 
 func (c *Connection) Find() error {
@@ -24,6 +27,27 @@ func (c *Connection) Find() error {
 	return nil
 }
 
+// findLatest fetches the most recent connection the user made to the server
+// within the transaction
+func (c *Connection) findLatest(tx *gorm.DB) error {
+	if err := tx.Where("user_id = ? AND server_id = ?", c.UserID, c.ServerID).Order("created_at desc").First(&c).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// addUsage increments the bytes transferred over the connection within the
+// transaction
+func (c *Connection) addUsage(tx *gorm.DB, up, down int64) error {
+	if err := tx.Model(&c).UpdateColumns(map[string]interface{}{
+		"bytes_up":   gorm.Expr("bytes_up + ?", up),
+		"bytes_down": gorm.Expr("bytes_down + ?", down),
+	}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
 func (c *Connection) GetUser() (*User, error) {
 	var user User
 	if err := db().Model(&user).Related(&c).Error; err != nil {
@@ -69,6 +93,32 @@ func (ac *AllConnections) Count() (*int, error) {
 	return &count, nil
 }
 
+// ConnectionStats is the number of connections made to a server, by how
+// many users, and the data transferred over them
+type ConnectionStats struct {
+	ServerID      uint   `json:"server_id"`
+	ServerCountry string `json:"server_country"`
+	Connections   int    `json:"connections"`
+	Users         int    `json:"users"`
+	BytesUp       int64  `json:"bytes_up"`
+	BytesDown     int64  `json:"bytes_down"`
+}
+
+// Find sums the connections made to each server since the given time,
+// busiest first
+func (acs *AllConnectionStats) Find(since time.Time) error {
+	if err := db().Model(&Connection{}).
+		Select("server_id, server_country, count(*) as connections, count(distinct user_id) as users, "+
+			"coalesce(sum(bytes_up), 0) as bytes_up, coalesce(sum(bytes_down), 0) as bytes_down").
+		Where("created_at >= ?", since).
+		Group("server_id, server_country").
+		Order("connections desc").
+		Scan(&acs).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
 // BeforeCreate sets the CreatedAt column to the current time
 func (c *Connection) BeforeCreate(scope *gorm.Scope) error {
 	scope.SetColumn("CreatedAt", time.Now())
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type AllDataUsage []DataUsage
+
+// DataUsage contains the bytes a user transferred through a server
+// between two usage reports from the proxy node
+type DataUsage struct {
+	BaseModel
+	UserID       uint  `json:"user_id"`
+	ServerID     uint  `json:"server_id"`
+	ConnectionID uint  `json:"connection_id"`
+	BytesUp      int64 `json:"bytes_up"`
+	BytesDown    int64 `json:"bytes_down"`
+}
+
+// UsageTotal is the sum of the data a user transferred over a period
+type UsageTotal struct {
+	BytesUp   int64 `json:"bytes_up"`
+	BytesDown int64 `json:"bytes_down"`
+}
+
+func (du *DataUsage) Create() error {
+	if err := db().Create(&du).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Create stores every record of a usage report and adds it to the latest
+// connection each user made to the server. It runs in one transaction so a
+// report is recorded in full or not at all, and a node can safely send it
+// again after a failure.
+func (adu *AllDataUsage) Create() error {
+	tx := db().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for i := range *adu {
+		du := &(*adu)[i]
+		var conn Connection
+		conn.UserID = du.UserID
+		conn.ServerID = du.ServerID
+		err := conn.findLatest(tx)
+		if err != nil && !gorm.IsRecordNotFoundError(err) {
+			tx.Rollback()
+			return err
+		}
+		if err == nil {
+			if err := conn.addUsage(tx, du.BytesUp, du.BytesDown); err != nil {
+				tx.Rollback()
+				return err
+			}
+			du.ConnectionID = conn.ID
+		}
+		if err := tx.Create(du).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Find sums the data transferred by the user between start and end
+func (ut *UsageTotal) Find(userID uint, start, end time.Time) error {
+	if err := db().Model(&DataUsage{}).
+		Select("coalesce(sum(bytes_up), 0) as bytes_up, coalesce(sum(bytes_down), 0) as bytes_down").
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, start, end).
+		Scan(&ut).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Total returns the upstream and downstream bytes combined
+func (ut *UsageTotal) Total() int64 {
+	return ut.BytesUp + ut.BytesDown
+}
+
+// BeforeCreate sets the CreatedAt column to the current time
+func (du *DataUsage) BeforeCreate(scope *gorm.Scope) error {
+	scope.SetColumn("CreatedAt", time.Now())
+	return nil
+}
+
+// BeforeUpdate sets the UpdatedAt column to the current time
+func (du *DataUsage) BeforeUpdate(scope *gorm.Scope) error {
+	scope.SetColumn("UpdatedAt", time.Now())
+	return nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type AllDeviceSessions []DeviceSession
+
+// DeviceSession records the last time a device connected to a server
+// so the number of devices a user has active at once can be limited
+type DeviceSession struct {
+	BaseModel
+	UserID     uint      `json:"user_id"`
+	DeviceID   string    `json:"device_id"`
+	ServerID   uint      `json:"server_id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	LastActive time.Time `json:"last_active"`
+}
+
+func (ds *DeviceSession) Find() error {
+	if err := db().Where("user_id = ? AND device_id = ?", ds.UserID, ds.DeviceID).First(&ds).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Touch marks the device as active now, creating its session
+// if this is the first time it has connected
+func (ds *DeviceSession) Touch() error {
+	existing := DeviceSession{UserID: ds.UserID, DeviceID: ds.DeviceID}
+	if err := existing.Find(); err == nil {
+		ds.ID = existing.ID
+		ds.CreatedAt = existing.CreatedAt
+	}
+	ds.LastActive = time.Now()
+	if err := db().Save(&ds).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// CountOthers returns the number of the users other devices
+// which have been active since the given time
+func (ds *DeviceSession) CountOthers(since time.Time) (int, error) {
+	var count int
+	if err := db().Model(&DeviceSession{}).
+		Where("user_id = ? AND device_id <> ? AND last_active > ?", ds.UserID, ds.DeviceID, since).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountActive returns the number of devices of every user which have
+// been active since the given time
+func (ads *AllDeviceSessions) CountActive(since time.Time) (int, error) {
+	var count int
+	if err := db().Model(&DeviceSession{}).Where("last_active > ?", since).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindAll fetches every device session of the user
+func (ads *AllDeviceSessions) FindAll(userID uint) error {
+	if err := db().Where("user_id = ?", userID).Order("last_active desc").Find(&ads).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// BeforeCreate sets the CreatedAt column to the current time
+func (ds *DeviceSession) BeforeCreate(scope *gorm.Scope) error {
+	scope.SetColumn("CreatedAt", time.Now())
+	return nil
+}
+
+// BeforeUpdate sets the UpdatedAt column to the current time
+func (ds *DeviceSession) BeforeUpdate(scope *gorm.Scope) error {
+	scope.SetColumn("UpdatedAt", time.Now())
+	return nil
+}
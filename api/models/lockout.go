@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type LockoutScope string
+type LockoutKind string
+type AllLockouts []Lockout
+
+var (
+	LockoutScopeLogin LockoutScope = "login"
+	LockoutScopeReset LockoutScope = "reset"
+
+	LockoutKindAccount LockoutKind = "account"
+	LockoutKindIP      LockoutKind = "ip"
+)
+
+// Lockout counts the recent failed attempts made against an account or
+// from an IP address, and when further attempts are refused until
+type Lockout struct {
+	BaseModel
+	Scope       LockoutScope `json:"scope"`
+	Kind        LockoutKind  `json:"kind"`
+	Key         string       `json:"key"`
+	UserID      uint         `json:"user_id"`
+	Failures    int          `json:"failures"`
+	LastFailure time.Time    `json:"last_failure"`
+	LockedUntil *time.Time   `json:"locked_until"`
+}
+
+func (l *Lockout) Find() error {
+	if err := db().Where(&l).First(&l).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindKey fetches the lockout for the scope, kind and key. They are matched
+// explicitly rather than through the struct so an empty key only matches
+// an empty key.
+func (l *Lockout) FindKey() error {
+	if err := db().Where("scope = ? AND kind = ? AND key = ?", l.Scope, l.Kind, l.Key).First(&l).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (l *Lockout) Save() error {
+	if err := db().Save(&l).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Delete removes the lockout, clearing its failed attempts
+func (l *Lockout) Delete() error {
+	if err := db().Unscoped().Delete(&l).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Locked reports whether attempts are refused outright at the given time
+func (l *Lockout) Locked(now time.Time) bool {
+	return l.LockedUntil != nil && l.LockedUntil.After(now)
+}
+
+// FindAll fetches the lockouts with failed attempts, those currently
+// locked first
+func (al *AllLockouts) FindAll(offset int) error {
+	limit := 20
+	if err := db().Order("locked_until desc nulls last, last_failure desc").Limit(limit).Offset(offset).Find(&al).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (al *AllLockouts) Count() (*int, error) {
+	var count int
+	if err := db().Model(&Lockout{}).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+// BeforeCreate sets the CreatedAt column to the current time
+func (l *Lockout) BeforeCreate(scope *gorm.Scope) error {
+	scope.SetColumn("CreatedAt", time.Now())
+	return nil
+}
+
+// BeforeUpdate sets the UpdatedAt column to the current time
+func (l *Lockout) BeforeUpdate(scope *gorm.Scope) error {
+	scope.SetColumn("UpdatedAt", time.Now())
+	return nil
+}
@@ -27,5 +27,14 @@ func Get() []interface{} {
 		&EmailToken{},
 		&ForgotPassword{},
 		&Connection{},
+		&ProxyCredential{},
+		&DataUsage{},
+		&DeviceSession{},
+		&RecoveryCode{},
+		&Lockout{},
+		&APIToken{},
+		&AuditLog{},
+		&Setting{},
+		&SettingChange{},
 	}
 }
@@ -28,6 +28,8 @@ type Plan struct {
 	IntervalCount   int64    `json:"interval_count" binding:"required"`
 	PlanType        PlanType `json:"plan_type" binding:"required"`
 	Currency        string   `json:"currency" binding:"required"`
+	DataAllowance   int64    `json:"data_allowance"`
+	MaxDevices      int      `json:"max_devices"`
 	StripePlanID    string   `json:"stripe_plan_id"`
 	StripeProductID string   `json:"stripe_product_id"`
 }
@@ -115,12 +117,14 @@ func (p *Plan) deleteStripePlan() error {
 // BeforeCreate sets the CreatedAt column to the current time
 func (p *Plan) String() string {
 	return fmt.Sprintf(
-		"ID: %d, Name: %s, Amount: %s, Interval: %d, IntervalCount: %d, Currency: %d",
+		"ID: %d, Name: %s, Amount: %d, Interval: %s, IntervalCount: %d, Currency: %s, DataAllowance: %d, MaxDevices: %d",
 		p.ID,
 		p.Name,
 		p.Amount,
 		p.Interval,
 		p.IntervalCount,
 		p.Currency,
+		p.DataAllowance,
+		p.MaxDevices,
 	)
 }
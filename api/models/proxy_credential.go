@@ -0,0 +1,168 @@
+package models
+
+import (
+	"eirevpn/api/integrations/proxy"
+	"eirevpn/api/util/random"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type AllProxyCredentials []ProxyCredential
+
+// ProxyCredential contains the username and password issued to a user
+// for connecting to a given proxy server
+type ProxyCredential struct {
+	BaseModel
+	UserID     uint   `json:"user_id"`
+	ServerID   uint   `json:"server_id"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	Suspended  bool   `json:"suspended"`
+	MaxDevices int    `json:"max_devices"`
+}
+
+func (pc *ProxyCredential) Find() error {
+	if err := db().Where(&pc).First(&pc).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Create generates a new username and password for the user, saves them
+// and then issues them to the proxy server. The saved credential is removed
+// again if the proxy server does not accept it, so the node never holds a
+// credential the API has no record of.
+func (pc *ProxyCredential) Create() error {
+	username, err := random.GenerateRandomString(12)
+	if err != nil {
+		return err
+	}
+	password, err := random.GenerateRandomString(24)
+	if err != nil {
+		return err
+	}
+	pc.Username = username
+	pc.Password = password
+	if err := db().Create(&pc).Error; err != nil {
+		return err
+	}
+	if err := pc.issue(); err != nil {
+		db().Unscoped().Delete(&pc)
+		return err
+	}
+	return nil
+}
+
+// Delete revokes the credential on the proxy server before removing it
+func (pc *ProxyCredential) Delete() error {
+	if err := pc.revoke(); err != nil {
+		return err
+	}
+	if err := db().Delete(&pc).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Suspend stops the proxy server accepting the credential
+func (pc *ProxyCredential) Suspend() error {
+	server, err := pc.server()
+	if err != nil {
+		return err
+	}
+	if err := proxy.SuspendCredential(server.Node(), pc.UserID); err != nil {
+		return err
+	}
+	pc.Suspended = true
+	return db().Save(&pc).Error
+}
+
+// Reissue issues the credential to the proxy server again, lifting
+// any suspension and applying the current device limit
+func (pc *ProxyCredential) Reissue() error {
+	if err := pc.issue(); err != nil {
+		return err
+	}
+	pc.Suspended = false
+	return db().Save(&pc).Error
+}
+
+// Rotate replaces the username and password, issuing the new ones to the
+// proxy server. A suspended credential stays suspended and gets the new
+// ones when it is reissued.
+func (pc *ProxyCredential) Rotate() error {
+	username, err := random.GenerateRandomString(12)
+	if err != nil {
+		return err
+	}
+	password, err := random.GenerateRandomString(24)
+	if err != nil {
+		return err
+	}
+	pc.Username = username
+	pc.Password = password
+	if !pc.Suspended {
+		if err := pc.issue(); err != nil {
+			return err
+		}
+	}
+	return db().Save(&pc).Error
+}
+
+// FindAll fetches every credential issued to the user
+func (apc *AllProxyCredentials) FindAll(userID uint) error {
+	if err := db().Where("user_id = ?", userID).Find(&apc).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindByServer fetches every credential issued for the server
+func (apc *AllProxyCredentials) FindByServer(serverID uint) error {
+	if err := db().Where("server_id = ?", serverID).Find(&apc).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pc *ProxyCredential) server() (*Server, error) {
+	var server Server
+	server.ID = pc.ServerID
+	if err := server.Find(); err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+func (pc *ProxyCredential) issue() error {
+	server, err := pc.server()
+	if err != nil {
+		return err
+	}
+	return proxy.AddCredential(server.Node(), pc.UserID, pc.Username, pc.Password, pc.MaxDevices)
+}
+
+func (pc *ProxyCredential) revoke() error {
+	server, err := pc.server()
+	if gorm.IsRecordNotFoundError(err) {
+		// the server has been removed so there is nothing to revoke
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return proxy.RevokeCredential(server.Node(), pc.UserID)
+}
+
+// BeforeCreate sets the CreatedAt column to the current time
+func (pc *ProxyCredential) BeforeCreate(scope *gorm.Scope) error {
+	scope.SetColumn("CreatedAt", time.Now())
+	return nil
+}
+
+// BeforeUpdate sets the UpdatedAt column to the current time
+func (pc *ProxyCredential) BeforeUpdate(scope *gorm.Scope) error {
+	scope.SetColumn("UpdatedAt", time.Now())
+	return nil
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"crypto/sha256"
+	"eirevpn/api/util/random"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// recoveryCodeCount is the number of recovery codes issued at once
+const recoveryCodeCount = 10
+
+type AllRecoveryCodes []RecoveryCode
+
+// RecoveryCode is a single use code which can be entered instead of
+// a TOTP code if the user loses their authenticator. Only a hash of
+// the code is stored.
+type RecoveryCode struct {
+	BaseModel
+	UserID uint       `json:"user_id"`
+	Hash   string     `json:"-"`
+	UsedAt *time.Time `json:"used_at"`
+}
+
+// Use marks the users matching unused recovery code as used. The code is
+// checked and marked in one statement so concurrent logins cannot both use
+// it, and gorm.ErrRecordNotFound is returned when no unused code matches.
+func (rc *RecoveryCode) Use(userID uint, code string) error {
+	now := time.Now()
+	result := db().Model(&RecoveryCode{}).
+		Where("user_id = ? AND hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		UpdateColumn("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return gorm.ErrRecordNotFound
+	}
+	rc.UserID = userID
+	rc.UsedAt = &now
+	return nil
+}
+
+// Generate replaces the users recovery codes with a fresh set and
+// returns them. This is the only time the codes are available.
+func (arc *AllRecoveryCodes) Generate(userID uint) ([]string, error) {
+	if err := arc.DeleteAll(userID); err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b, err := random.GenerateRandomBytes(5)
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		code = code[:4] + "-" + code[4:]
+		rc := RecoveryCode{UserID: userID, Hash: hashRecoveryCode(code)}
+		if err := db().Create(&rc).Error; err != nil {
+			return nil, err
+		}
+		*arc = append(*arc, rc)
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// CountUnused returns the number of recovery codes the user has left
+func (arc *AllRecoveryCodes) CountUnused(userID uint) (int, error) {
+	var count int
+	if err := db().Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DeleteAll removes every recovery code issued to the user
+func (arc *AllRecoveryCodes) DeleteAll(userID uint) error {
+	if err := db().Unscoped().Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes so codes can be
+// typed back however the user wrote them down
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// BeforeCreate sets the CreatedAt column to the current time
+func (rc *RecoveryCode) BeforeCreate(scope *gorm.Scope) error {
+	scope.SetColumn("CreatedAt", time.Now())
+	return nil
+}
+
+// BeforeUpdate sets the UpdatedAt column to the current time
+func (rc *RecoveryCode) BeforeUpdate(scope *gorm.Scope) error {
+	scope.SetColumn("UpdatedAt", time.Now())
+	return nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"eirevpn/api/integrations/proxy"
 	"time"
 
 	"github.com/jinzhu/gorm"
@@ -18,14 +19,29 @@ var (
 // Cart contains the details of which plans each user is signed trying to purchase
 type Server struct {
 	BaseModel
-	Country     string     `form:"country" json:"country" binding:"required"`
-	CountryCode string     `form:"country_code" json:"country_code" binding:"required"`
-	Type        ServerType `form:"type" json:"type"` // binding:"required" removed for time being
-	IP          string     `form:"ip" json:"ip" binding:"required"`
-	Port        int        `form:"port" json:"port" binding:"required"`
-	Username    string     `form:"username" json:"username" binding:"required"`
-	Password    string     `form:"password" json:"password" binding:"required"`
-	ImagePath   string     `form:"image_path" json:"image_path"`
+	Country      string     `form:"country" json:"country" binding:"required"`
+	CountryCode  string     `form:"country_code" json:"country_code" binding:"required"`
+	Type         ServerType `form:"type" json:"type"` // binding:"required" removed for time being
+	IP           string     `form:"ip" json:"ip" binding:"required"`
+	Port         int        `form:"port" json:"port" binding:"required"`
+	SocksPort    int        `form:"socks_port" json:"socks_port"`
+	Username     string     `form:"username" json:"username" binding:"required"`
+	Password     string     `form:"password" json:"password" binding:"required"`
+	ImagePath    string     `form:"image_path" json:"image_path"`
+	APIPort      int        `form:"api_port" json:"api_port"`
+	APISecret    string     `form:"api_secret" json:"api_secret"`
+	Healthy      bool       `json:"healthy"`
+	Latency      int64      `json:"latency"`
+	LastSeen     time.Time  `json:"last_seen"`
+	LastChecked  time.Time  `json:"last_checked"`
+	FailedChecks int        `json:"failed_checks"`
+	MaxUsers     int        `form:"max_users" json:"max_users"`
+	Connections  int        `json:"connections"`
+	Users        int        `json:"users"`
+	Throughput   int64      `json:"throughput"`
+	CPULoad      float64    `json:"cpu_load"`
+	LoadReported time.Time  `json:"load_reported"`
+	Disabled     bool       `json:"disabled"`
 }
 
 func (s *Server) Find() error {
@@ -56,6 +72,57 @@ func (s *Server) Delete() error {
 	return nil
 }
 
+// SaveHealth stores the result of the last health check without
+// touching the fields an admin may be editing
+func (s *Server) SaveHealth() error {
+	if err := db().Model(&s).UpdateColumns(map[string]interface{}{
+		"healthy":       s.Healthy,
+		"latency":       s.Latency,
+		"last_seen":     s.LastSeen,
+		"last_checked":  s.LastChecked,
+		"failed_checks": s.FailedChecks,
+	}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// SaveLoad stores the load last reported by the servers proxy node
+func (s *Server) SaveLoad() error {
+	if err := db().Model(&s).UpdateColumns(map[string]interface{}{
+		"connections":   s.Connections,
+		"users":         s.Users,
+		"throughput":    s.Throughput,
+		"cpu_load":      s.CPULoad,
+		"load_reported": s.LoadReported,
+	}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Unhealthy reports whether the server has been checked and found to be down.
+// Servers which have not been checked yet are given the benefit of the doubt.
+func (s *Server) Unhealthy() bool {
+	return !s.Healthy && !s.LastChecked.IsZero()
+}
+
+// Available reports whether users can connect to the server
+func (s *Server) Available() bool {
+	return !s.Disabled && !s.Unhealthy()
+}
+
+// Full reports whether the node has as many users connected as the
+// server allows. A MaxUsers of 0 means the server has no limit.
+func (s *Server) Full() bool {
+	return s.MaxUsers > 0 && s.Users >= s.MaxUsers
+}
+
+// Node returns the address and secret of the servers control API
+func (s *Server) Node() proxy.Node {
+	return proxy.Node{IP: s.IP, Port: s.APIPort, Secret: s.APISecret}
+}
+
 func (as *AllServers) FindAll() error {
 	if err := db().Find(&as).Error; err != nil {
 		return err
@@ -63,6 +130,43 @@ func (as *AllServers) FindAll() error {
 	return nil
 }
 
+// FindByCountry fetches every server in the country
+func (as *AllServers) FindByCountry(countryCode string) error {
+	if err := db().Where("upper(country_code) = upper(?)", countryCode).Find(&as).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// LeastLoaded returns the available server with the fewest active
+// connections, using CPU load to break ties. Servers at capacity are
+// skipped and nil is returned when no server can take the user.
+func (as AllServers) LeastLoaded() *Server {
+	var best *Server
+	for i := range as {
+		s := &as[i]
+		if !s.Available() || s.Full() {
+			continue
+		}
+		if best == nil || s.Connections < best.Connections ||
+			(s.Connections == best.Connections && s.CPULoad < best.CPULoad) {
+			best = s
+		}
+	}
+	return best
+}
+
+// Except returns the servers without the server with the given ID
+func (as AllServers) Except(serverID uint) AllServers {
+	servers := make(AllServers, 0, len(as))
+	for _, s := range as {
+		if s.ID != serverID {
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
+
 // BeforeCreate sets the CreatedAt column to the current time
 func (s *Server) BeforeCreate(scope *gorm.Scope) error {
 	scope.SetColumn("CreatedAt", time.Now())
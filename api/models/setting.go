@@ -0,0 +1,173 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type AllSettings []Setting
+type AllSettingChanges []SettingChange
+
+// Setting is a runtime setting or feature flag changed through the admin
+// dashboard. Value holds the JSON encoded value and Version counts the
+// changes made to it.
+type Setting struct {
+	BaseModel
+	Key       string `json:"key" gorm:"unique_index"`
+	Value     string `json:"value" gorm:"type:text"`
+	Version   int    `json:"version"`
+	UpdatedBy uint   `json:"updated_by"`
+}
+
+// SettingChange records who changed a setting and its values before and
+// after. Before is empty for the first change.
+type SettingChange struct {
+	BaseModel
+	Key       string `json:"key"`
+	Before    string `json:"before" gorm:"type:text"`
+	After     string `json:"after" gorm:"type:text"`
+	Version   int    `json:"version"`
+	ChangedBy uint   `json:"changed_by"`
+}
+
+// Update sets the value of each setting and records the changes in one
+// transaction, so either every change is made or none are. Settings whose
+// value is the same are left alone. The rows are locked in key order so
+// concurrent updates from other instances get consecutive versions. The
+// settings which changed are put in as.
+func (as *AllSettings) Update(values map[string]string, actorID uint) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tx := db().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	var changed AllSettings
+	for _, key := range keys {
+		s := Setting{Key: key}
+		ok, err := s.update(tx, values[key], actorID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if ok {
+			changed = append(changed, s)
+		}
+	}
+	if len(changed) == 0 {
+		tx.Rollback()
+		*as = nil
+		return nil
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	*as = changed
+	return nil
+}
+
+// update sets the value of the setting and records the change within the
+// transaction, reporting whether the value changed
+func (s *Setting) update(tx *gorm.DB, value string, actorID uint) (bool, error) {
+	// a new key gets an empty row first so there is always a row to lock,
+	// otherwise two instances adding the same key would both insert it and
+	// one would fail on the unique index
+	now := time.Now()
+	if err := tx.Exec("INSERT INTO settings (key, value, version, updated_by, created_at, updated_at) VALUES (?, '', 0, 0, ?, ?) ON CONFLICT (key) DO NOTHING", s.Key, now, now).Error; err != nil {
+		return false, err
+	}
+	var existing Setting
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("key = ?", s.Key).First(&existing).Error; err != nil {
+		return false, err
+	}
+	*s = existing
+	if existing.Value == value {
+		return false, nil
+	}
+	before := s.Value
+	s.Value = value
+	s.Version++
+	s.UpdatedBy = actorID
+	if err := tx.Save(s).Error; err != nil {
+		return false, err
+	}
+	change := SettingChange{
+		Key:       s.Key,
+		Before:    before,
+		After:     value,
+		Version:   s.Version,
+		ChangedBy: actorID,
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FindAll fetches every setting
+func (as *AllSettings) FindAll() error {
+	if err := db().Order("key").Find(&as).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindAll fetches the changes made to the setting, or to every setting
+// when key is empty, newest first
+func (sc *AllSettingChanges) FindAll(offset int, key string) error {
+	limit := 20
+	query := db()
+	if key != "" {
+		query = query.Where("key = ?", key)
+	}
+	if err := query.Order("id desc").Limit(limit).Offset(offset).Find(&sc).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (sc *AllSettingChanges) Count(key string) (*int, error) {
+	var count int
+	query := db().Model(&SettingChange{})
+	if key != "" {
+		query = query.Where("key = ?", key)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+// LatestRevision returns the ID of the most recent setting change, so
+// instances can tell when their cached settings are stale
+func (sc *AllSettingChanges) LatestRevision() (uint, error) {
+	var latest struct{ ID uint }
+	if err := db().Model(&SettingChange{}).Select("coalesce(max(id), 0) as id").Scan(&latest).Error; err != nil {
+		return 0, err
+	}
+	return latest.ID, nil
+}
+
+// BeforeCreate sets the CreatedAt column to the current time
+func (s *Setting) BeforeCreate(scope *gorm.Scope) error {
+	scope.SetColumn("CreatedAt", time.Now())
+	return nil
+}
+
+// BeforeUpdate sets the UpdatedAt column to the current time
+func (s *Setting) BeforeUpdate(scope *gorm.Scope) error {
+	scope.SetColumn("UpdatedAt", time.Now())
+	return nil
+}
+
+// BeforeCreate sets the CreatedAt column to the current time
+func (sc *SettingChange) BeforeCreate(scope *gorm.Scope) error {
+	scope.SetColumn("CreatedAt", time.Now())
+	return nil
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"eirevpn/api/integrations/stripe"
+	"eirevpn/api/util/totp"
 	"time"
 
 	stripego "github.com/stripe/stripe-go"
@@ -11,11 +12,16 @@ import (
 )
 
 type UserType string
+type UserRole string
 type AllUsers []User
 
 var (
 	UserTypeNormal UserType = "normal"
 	UserTypeAdmin  UserType = "admin"
+
+	UserRoleSuperAdmin UserRole = "superadmin"
+	UserRoleSupport    UserRole = "support"
+	UserRoleBilling    UserRole = "billing"
 )
 
 // User contains the users details
@@ -27,7 +33,11 @@ type User struct {
 	Password         string   `json:"password" binding:"required"`
 	StripeCustomerID string   `json:"stripe_customer_id"`
 	Type             UserType `json:"type"`
+	Role             UserRole `json:"role"`
 	EmailConfirmed   bool     `json:"email_confirmed"`
+	TwoFactorEnabled bool     `json:"two_factor_enabled"`
+	TOTPSecret       string   `json:"-"`
+	TOTPLastStep     int64    `json:"-"`
 }
 
 func (u *User) Find() error {
@@ -58,6 +68,39 @@ func (u *User) Delete() error {
 	return nil
 }
 
+// ValidateTOTP checks the code against the users TOTP secret. A code is
+// only accepted once so it cannot be replayed within its time window.
+func (u *User) ValidateTOTP(code string) bool {
+	if u.TOTPSecret == "" {
+		return false
+	}
+	step, ok := totp.Validate(code, u.TOTPSecret, time.Now())
+	if !ok || step <= u.TOTPLastStep {
+		return false
+	}
+	u.TOTPLastStep = step
+	if err := db().Model(&u).UpdateColumn("totp_last_step", step).Error; err != nil {
+		return false
+	}
+	return true
+}
+
+// DisableTwoFactor clears the users TOTP secret and recovery codes
+func (u *User) DisableTwoFactor() error {
+	u.TwoFactorEnabled = false
+	u.TOTPSecret = ""
+	u.TOTPLastStep = 0
+	if err := db().Model(&u).UpdateColumns(map[string]interface{}{
+		"two_factor_enabled": false,
+		"totp_secret":        "",
+		"totp_last_step":     0,
+	}).Error; err != nil {
+		return err
+	}
+	var codes AllRecoveryCodes
+	return codes.DeleteAll(u.ID)
+}
+
 func (u *User) CreateStripeCustomer() (*stripego.Customer, error) {
 	return stripe.CreateCustomer(u.Email, u.FirstName, u.LastName, u.ID)
 }
@@ -7,13 +7,23 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
-// UserAppSession contains the users session identifier token
+type AllUserAppSessions []UserAppSession
+
+// UserAppSession contains the users session identifier token along
+// with the details of the device the user logged in from
 type UserAppSession struct {
 	BaseModel
-	UserID      uint   `json:"user_id"`
-	Identifier  string `json:"indentifier"`
+	UserID     uint      `json:"user_id"`
+	Identifier string    `json:"-"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	LastUsed   time.Time `json:"last_used"`
 }
 
+// lastUsedInterval stops every request from writing to the session
+const lastUsedInterval = time.Minute
+
 func (us *UserAppSession) Find() error {
 	if err := db().Where(&us).First(&us).Error; err != nil {
 		return err
@@ -21,12 +31,18 @@ func (us *UserAppSession) Find() error {
 	return nil
 }
 
-// New adds a new user session and deletes all old sessions
-func (us *UserAppSession) New(UserID uint) error {
-	us.UserID = UserID
-	if err := us.DeleteAll(); err != nil {
+// FindByIdentifier fetches the users session matching the identifier
+func (us *UserAppSession) FindByIdentifier() error {
+	if err := db().Where("user_id = ? AND identifier = ?", us.UserID, us.Identifier).First(&us).Error; err != nil {
 		return err
 	}
+	return nil
+}
+
+// New adds a new user session alongside any the user already has
+func (us *UserAppSession) New(UserID uint) error {
+	us.UserID = UserID
+	us.LastUsed = time.Now()
 	if err := us.Create(); err != nil {
 		return err
 	}
@@ -41,6 +57,32 @@ func (us *UserAppSession) Create() error {
 	return nil
 }
 
+// Touch records that the session has been used from the ip and user agent
+func (us *UserAppSession) Touch(ip, userAgent string) error {
+	if time.Since(us.LastUsed) < lastUsedInterval && us.IP == ip && us.UserAgent == userAgent {
+		return nil
+	}
+	us.LastUsed = time.Now()
+	us.IP = ip
+	us.UserAgent = userAgent
+	if err := db().Model(&us).UpdateColumns(map[string]interface{}{
+		"last_used":  us.LastUsed,
+		"ip":         us.IP,
+		"user_agent": us.UserAgent,
+	}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Delete removes the session, logging the device out
+func (us *UserAppSession) Delete() error {
+	if err := db().Delete(&us).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
 // DeleteAll removes any existing user sessions
 func (us *UserAppSession) DeleteAll() error {
 	if err := db().Delete(UserAppSession{}, "user_id = ?", us.UserID).Error; err != nil {
@@ -49,6 +91,14 @@ func (us *UserAppSession) DeleteAll() error {
 	return nil
 }
 
+// FindAll fetches every session of the user, most recently used first
+func (aus *AllUserAppSessions) FindAll(userID uint) error {
+	if err := db().Where("user_id = ?", userID).Order("last_used desc").Find(&aus).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
 // BeforeCreate sets the CreatedAt column to the current time
 func (us *UserAppSession) BeforeCreate(scope *gorm.Scope) error {
 	scope.SetColumn("CreatedAt", time.Now())
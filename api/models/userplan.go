@@ -8,14 +8,19 @@ import (
 
 type AllUserPlans []UserPlan
 
-// UserPlan contains the details of which plans each user is signed up for
+// UserPlan contains the details of which plans each user is signed up for.
+// DataAllowance and DataRemaining are reset from the plan at the start of
+// each billing period, an allowance of 0 meaning the data is unlimited.
 type UserPlan struct {
 	BaseModel
-	UserID     uint      `json:"user_id" binding:"required"`
-	PlanID     uint      `json:"plan_id" binding:"required"`
-	Active     bool      `json:"active" binding:"required"`
-	StartDate  time.Time `json:"start_date" binding:"required"`
-	ExpiryDate time.Time `json:"expiry_date" binding:"required"`
+	UserID          uint      `json:"user_id" binding:"required"`
+	PlanID          uint      `json:"plan_id" binding:"required"`
+	Active          bool      `json:"active" binding:"required"`
+	StartDate       time.Time `json:"start_date" binding:"required"`
+	ExpiryDate      time.Time `json:"expiry_date" binding:"required"`
+	DataAllowance   int64     `json:"data_allowance"`
+	DataRemaining   int64     `json:"data_remaining"`
+	QuotaPeriodFrom time.Time `json:"quota_period_from"`
 }
 
 func (up *UserPlan) Find() error {
@@ -64,6 +69,73 @@ func (up *UserPlan) DeleteAll() error {
 	return nil
 }
 
+// CurrentPeriod returns the start and end of the monthly billing period
+// containing now. Periods run monthly from the plans StartDate and the
+// last period is cut short by the ExpiryDate.
+func (up *UserPlan) CurrentPeriod(now time.Time) (time.Time, time.Time) {
+	months := (now.Year()-up.StartDate.Year())*12 + int(now.Month()-up.StartDate.Month())
+	start := up.StartDate.AddDate(0, months, 0)
+	if start.After(now) {
+		start = up.StartDate.AddDate(0, months-1, 0)
+	}
+	end := start.AddDate(0, 1, 0)
+	if !up.ExpiryDate.IsZero() && up.ExpiryDate.Before(end) {
+		end = up.ExpiryDate
+	}
+	return start, end
+}
+
+// RefreshQuota resets the remaining data to the plans allowance when
+// a new billing period has started since it was last reset
+func (up *UserPlan) RefreshQuota(now time.Time) error {
+	start, _ := up.CurrentPeriod(now)
+	if up.QuotaPeriodFrom.Equal(start) {
+		return nil
+	}
+	var plan Plan
+	plan.ID = up.PlanID
+	if err := plan.Find(); err != nil {
+		return err
+	}
+	// only the quota columns are written, and only if no one else has
+	// reset them for this period, so a concurrent UseData is not undone
+	result := db().Model(&UserPlan{}).
+		Where("id = ? AND quota_period_from = ?", up.ID, up.QuotaPeriodFrom).
+		UpdateColumns(map[string]interface{}{
+			"data_allowance":    plan.DataAllowance,
+			"data_remaining":    plan.DataAllowance,
+			"quota_period_from": start,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// another request reset the quota first so load what it saved
+		return db().Where("id = ?", up.ID).First(&up).Error
+	}
+	up.DataAllowance = plan.DataAllowance
+	up.DataRemaining = plan.DataAllowance
+	up.QuotaPeriodFrom = start
+	return nil
+}
+
+// UseData takes the bytes from the remaining data of the plan
+func (up *UserPlan) UseData(bytes int64) error {
+	if up.DataAllowance == 0 {
+		return nil
+	}
+	if err := db().Model(&up).UpdateColumn("data_remaining", gorm.Expr("data_remaining - ?", bytes)).Error; err != nil {
+		return err
+	}
+	up.DataRemaining -= bytes
+	return nil
+}
+
+// QuotaExhausted reports whether a data capped plan has no data remaining
+func (up *UserPlan) QuotaExhausted() bool {
+	return up.DataAllowance > 0 && up.DataRemaining <= 0
+}
+
 // BeforeCreate sets the CreatedAt column to the current time
 func (up *UserPlan) BeforeCreate(scope *gorm.Scope) error {
 	scope.SetColumn("CreatedAt", time.Now())
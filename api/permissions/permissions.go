@@ -0,0 +1,104 @@
+package permissions
+
+import (
+	"eirevpn/api/models"
+)
+
+// Permission allows an admin to use a set of the protected routes
+type Permission string
+
+const (
+	UsersRead          Permission = "users:read"
+	UsersWrite         Permission = "users:write"
+	UsersRoles         Permission = "users:roles"
+	LockoutsRead       Permission = "lockouts:read"
+	LockoutsWrite      Permission = "lockouts:write"
+	PlansRead          Permission = "plans:read"
+	PlansWrite         Permission = "plans:write"
+	UserPlansRead      Permission = "userplans:read"
+	UserPlansWrite     Permission = "userplans:write"
+	ServersRead        Permission = "servers:read"
+	ServersWrite       Permission = "servers:write"
+	ServersCredentials Permission = "servers:credentials"
+	SettingsRead       Permission = "settings:read"
+	SettingsWrite      Permission = "settings:write"
+	AuditRead          Permission = "audit:read"
+)
+
+// All lists every permission, in the order they are shown to admins
+var All = []Permission{
+	UsersRead,
+	UsersWrite,
+	UsersRoles,
+	LockoutsRead,
+	LockoutsWrite,
+	PlansRead,
+	PlansWrite,
+	UserPlansRead,
+	UserPlansWrite,
+	ServersRead,
+	ServersWrite,
+	ServersCredentials,
+	SettingsRead,
+	SettingsWrite,
+	AuditRead,
+}
+
+// roles maps each role to the permissions it grants. Superadmins are
+// granted everything so are not listed.
+var roles = map[models.UserRole][]Permission{
+	models.UserRoleSupport: {
+		UsersRead,
+		LockoutsRead,
+		LockoutsWrite,
+		UserPlansRead,
+		ServersRead,
+	},
+	models.UserRoleBilling: {
+		UsersRead,
+		PlansRead,
+		PlansWrite,
+		UserPlansRead,
+		UserPlansWrite,
+	},
+}
+
+// ValidRole reports whether the role can be assigned to a user
+func ValidRole(role models.UserRole) bool {
+	_, ok := roles[role]
+	return ok || role == models.UserRoleSuperAdmin
+}
+
+// RoleOf returns the role of the user. Only admins hold a role, and
+// admins created before roles existed are treated as superadmins.
+func RoleOf(user models.User) models.UserRole {
+	if user.Type != models.UserTypeAdmin {
+		return ""
+	}
+	if user.Role == "" {
+		return models.UserRoleSuperAdmin
+	}
+	return user.Role
+}
+
+// Of returns the permissions granted by the role
+func Of(role models.UserRole) []Permission {
+	if role == models.UserRoleSuperAdmin {
+		return All
+	}
+	perms, ok := roles[role]
+	if !ok {
+		return []Permission{}
+	}
+	return perms
+}
+
+// Has reports whether the role grants the permission
+func Has(role models.UserRole, perm Permission) bool {
+	for _, p := range Of(role) {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
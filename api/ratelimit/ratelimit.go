@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"eirevpn/api/config"
+	"eirevpn/api/errors"
+	"eirevpn/api/logger"
+	"eirevpn/api/requestid"
+	"eirevpn/api/util/clientip"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limit is the number of requests allowed per window
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// defaultLimits are used for groups missing from the config. The email
+// group covers routes which send mail so is kept low.
+var defaultLimits = map[string]Limit{
+	"public":    {Requests: 300, Window: time.Minute},
+	"private":   {Requests: 300, Window: time.Minute},
+	"protected": {Requests: 600, Window: time.Minute},
+	"email":     {Requests: 5, Window: time.Hour},
+}
+
+var (
+	mu    sync.RWMutex
+	store Store = NewMemoryStore()
+)
+
+// SetStore replaces the backend the request counts are kept in
+func SetStore(s Store) {
+	mu.Lock()
+	defer mu.Unlock()
+	store = s
+}
+
+func currentStore() Store {
+	mu.RLock()
+	defer mu.RUnlock()
+	return store
+}
+
+// limitFor returns the configured limit of the group, falling back to
+// its default
+func limitFor(group string) Limit {
+	if l, ok := config.Load().RateLimit.Groups[group]; ok && l.Requests > 0 && l.Window > 0 {
+		return Limit{Requests: l.Requests, Window: time.Duration(l.Window) * time.Second}
+	}
+	return defaultLimits[group]
+}
+
+// Middleware limits the requests made to the routes of the group. Requests
+// are counted per user on routes behind auth and otherwise per IP address,
+// so it must be added after the auth middleware.
+func Middleware(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.Load().RateLimit.Enabled {
+			return
+		}
+		limit := limitFor(group)
+		if limit.Requests <= 0 {
+			return
+		}
+
+		key := group + ":ip:" + clientip.Get(c)
+		if userID, exists := c.Get("UserID"); exists {
+			key = fmt.Sprintf("%s:user:%v", group, userID)
+		}
+
+		now := time.Now()
+		remaining, reset, ok := currentStore().Take(key, limit.Requests, limit.Window, now)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		if ok {
+			return
+		}
+
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "ratelimit - Middleware()",
+			Code:      errors.RateLimited.Code,
+			Extra:     map[string]interface{}{"Key": key, "Path": c.Request.URL.Path},
+			Err:       errors.RateLimited.Detail,
+		})
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(reset.Sub(now).Seconds()))))
+		c.AbortWithStatusJSON(errors.RateLimited.Status, errors.RateLimited)
+	}
+}
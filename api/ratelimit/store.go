@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Store counts requests made under a key within a window. Swap it for a
+// shared backend with SetStore when running more than one API instance.
+type Store interface {
+	// Take counts a request against the key and reports how many requests
+	// remain in the current window, when it resets, and whether the
+	// request is within the limit
+	Take(key string, limit int, window time.Duration, now time.Time) (remaining int, reset time.Time, ok bool)
+}
+
+// sweepInterval is how often expired windows are removed from memory
+const sweepInterval = time.Minute
+
+// MemoryStore keeps fixed window counters in process memory
+type MemoryStore struct {
+	mu        sync.Mutex
+	windows   map[string]*counter
+	lastSweep time.Time
+}
+
+type counter struct {
+	count int
+	reset time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{windows: map[string]*counter{}}
+}
+
+// Take satisfies the Store interface
+func (s *MemoryStore) Take(key string, limit int, window time.Duration, now time.Time) (int, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+
+	c, ok := s.windows[key]
+	if !ok || !now.Before(c.reset) {
+		c = &counter{reset: now.Add(window)}
+		s.windows[key] = c
+	}
+	if c.count >= limit {
+		return 0, c.reset, false
+	}
+	c.count++
+	return limit - c.count, c.reset, true
+}
+
+// sweep drops windows which have reset. The caller must hold mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, c := range s.windows {
+		if !now.Before(c.reset) {
+			delete(s.windows, key)
+		}
+	}
+	s.lastSweep = now
+}
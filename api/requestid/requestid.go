@@ -0,0 +1,75 @@
+package requestid
+
+import (
+	"eirevpn/api/util/random"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header carries the ID of a request to and from clients
+const Header = "X-Request-ID"
+
+// Key is the context key the ID is stored under
+const Key = "RequestID"
+
+// valid limits the IDs accepted from clients so they are safe to log
+var valid = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Middleware gives every request an ID, reusing one sent by the client or
+// a proxy in front of the API. The ID is returned in the X-Request-ID
+// header and in the body of error responses so a user reporting a problem
+// can quote it.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if !valid.MatchString(id) {
+			id = generate()
+		}
+		c.Set(Key, id)
+		c.Header(Header, id)
+		c.Writer = &errorWriter{ResponseWriter: c.Writer, id: id}
+		c.Next()
+	}
+}
+
+// Get returns the ID of the request
+func Get(c *gin.Context) string {
+	return c.GetString(Key)
+}
+
+func generate() string {
+	b, err := random.GenerateRandomBytes(16)
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// errorWriter adds the request ID to JSON error responses
+type errorWriter struct {
+	gin.ResponseWriter
+	id string
+}
+
+func (w *errorWriter) Write(b []byte) (int, error) {
+	if w.Status() < 400 || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return w.ResponseWriter.Write(b)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(b, &body); err != nil {
+		return w.ResponseWriter.Write(b)
+	}
+	body["request_id"] = w.id
+	withID, err := json.Marshal(body)
+	if err != nil {
+		return w.ResponseWriter.Write(b)
+	}
+	if _, err := w.ResponseWriter.Write(withID); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -3,20 +3,28 @@ package router
 import (
 	"eirevpn/api/config"
 	"eirevpn/api/errors"
+	"eirevpn/api/handlers/audit"
+	"eirevpn/api/handlers/health"
 	"eirevpn/api/handlers/message"
 	"eirevpn/api/handlers/plan"
 	"eirevpn/api/handlers/server"
-	"eirevpn/api/handlers/settings"
+	settingsHandler "eirevpn/api/handlers/settings"
 	"eirevpn/api/handlers/user"
 	"eirevpn/api/handlers/userplan"
+	"eirevpn/api/integrations/stripe"
 	"eirevpn/api/logger"
+	"eirevpn/api/metrics"
 	"eirevpn/api/models"
+	"eirevpn/api/permissions"
+	"eirevpn/api/ratelimit"
+	"eirevpn/api/requestid"
+	"eirevpn/api/settings"
+	"eirevpn/api/util/clientip"
 	"eirevpn/api/util/jwt"
-	"fmt"
-	"io"
 	"io/ioutil"
-	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -26,91 +34,152 @@ const secretkey = "verysecretkey1995"
 
 func Init(logging bool) *gin.Engine {
 
-	conf := config.Load()
-
 	var router *gin.Engine
 
 	if logging {
-		var file, err = os.OpenFile(logger.LogFilePath, os.O_APPEND|os.O_WRONLY, 0644)
-		if err != nil {
-			fmt.Println(err.Error())
-		}
-		gin.DefaultWriter = io.MultiWriter(file, os.Stdout)
-		router = gin.Default()
+		gin.DefaultWriter = logger.Writer()
+		gin.DefaultErrorWriter = logger.Writer()
+		router = gin.New()
+		router.Use(requestid.Middleware(), metrics.Middleware(), accessLog(), gin.Recovery())
 	} else {
 		gin.SetMode(gin.ReleaseMode)
 		gin.DefaultWriter = ioutil.Discard
 		router = gin.New()
-		router.Use(gin.Recovery())
+		router.Use(requestid.Middleware(), metrics.Middleware(), gin.Recovery())
 	}
 
 	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = conf.App.AllowedOrigins
+	// origins are checked per request as they can be changed at runtime
+	corsConfig.AllowOriginFunc = func(origin string) bool {
+		for _, allowed := range settings.Strings(settings.AllowedOrigins) {
+			if allowed == origin {
+				return true
+			}
+		}
+		return false
+	}
 	corsConfig.AllowCredentials = true
 	corsConfig.AllowBrowserExtensions = true
-	corsConfig.ExposeHeaders = []string{"X-CSRF-Token", "X-Auth-Token"}
-	corsConfig.AddAllowHeaders("Origin", "Content-Length", "Content-Type", "Authorization", "X-CSRF-Token", "X-Auth-Token")
+	corsConfig.ExposeHeaders = []string{"X-CSRF-Token", "X-Auth-Token", "X-Request-ID", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
+	corsConfig.AddAllowHeaders("Origin", "Content-Length", "Content-Type", "Authorization", "X-CSRF-Token", "X-Auth-Token", "X-Device-Name", "X-Request-ID")
 	router.Use(cors.New(corsConfig))
 
 	public := router.Group("/api")
 	private := router.Group("/api/private")
 	protected := router.Group("/api/protected")
-	private.Use(auth(secretkey, false))
-	protected.Use(auth(secretkey, true))
+	public.Use(ratelimit.Middleware("public"))
+	private.Use(auth(secretkey, false), ratelimit.Middleware("private"))
+	protected.Use(auth(secretkey, true), ratelimit.Middleware("protected"))
+
+	// routes which send email get a lower limit on top of their group's
+	publicEmail := public.Group("", ratelimit.Middleware("email"))
+	privateEmail := private.Group("", ratelimit.Middleware("email"))
 
-	public.POST("/user/signup", user.SignUpUser)
+	publicEmail.POST("/user/signup", user.SignUpUser)
 	public.POST("/user/login", user.LoginUser)
+	public.POST("/user/login/2fa", user.LoginTwoFactor)
 	private.GET("/user/get/:id", user.User)
 	private.PUT("/user/changepassword", user.ChangePassword)
 	private.PUT("/user/update/:id", user.UpdateUser)
-	protected.DELETE("/users/delete/:id", user.DeleteUser)
-	protected.GET("/users", user.AllUsers)
+	private.GET("/user/usage/:id", user.Usage)
+	private.GET("/user/sessions", user.Sessions)
+	private.DELETE("/user/sessions/:id", user.DeleteSession)
+	private.GET("/user/permissions", user.Permissions)
+	private.GET("/user/tokens", user.APITokens)
+	private.POST("/user/tokens", user.CreateAPIToken)
+	private.DELETE("/user/tokens/:id", user.DeleteAPIToken)
+	private.GET("/user/2fa", user.TwoFactor)
+	private.POST("/user/2fa/setup", user.SetupTwoFactor)
+	private.POST("/user/2fa/enable", user.EnableTwoFactor)
+	private.POST("/user/2fa/disable", user.DisableTwoFactor)
+	private.POST("/user/2fa/recovery_codes", user.RegenerateRecoveryCodes)
+	protected.DELETE("/users/delete/:id", authorize(permissions.UsersWrite), user.DeleteUser)
+	protected.GET("/users", authorize(permissions.UsersRead), user.AllUsers)
+	protected.PUT("/users/role/:id", authorize(permissions.UsersRoles), user.UpdateRole)
+	protected.GET("/lockouts", authorize(permissions.LockoutsRead), user.Lockouts)
+	protected.DELETE("/lockouts/:id", authorize(permissions.LockoutsWrite), user.ClearLockout)
 	public.POST("/user/webhook", user.Webhook)
 	private.GET("/user/updatepayment", user.StripeUpdatePaymentSession)
 	private.GET("/user/session/:planid", user.StripeSession)
 	private.GET("/user/cancel", user.CancelSubscription)
 	public.GET("/user/logout", user.Logout) //public so this router can skip auth middleware
 
-	public.POST("/user/forgot_pass", user.ForgotPasswordToken)
+	publicEmail.POST("/user/forgot_pass", user.ForgotPasswordToken)
 	public.POST("/user/forgot_pass/:token", user.UpdatePassword)
 
 	public.GET("/user/confirm_email/:token", user.ConfirmEmail)
-	private.GET("/user/confirm_email_resend", user.ResendLink)
+	privateEmail.GET("/user/confirm_email_resend", user.ResendLink)
 
-	protected.GET("/plans/:id", plan.Plan)
-	protected.POST("/plans/create", plan.CreatePlan)
-	protected.PUT("/plans/update/:id", plan.UpdatePlan)
-	protected.DELETE("/plans/delete/:id", plan.DeletePlan)
-	protected.GET("/plans", plan.AllPlans)
+	protected.GET("/plans/:id", authorize(permissions.PlansRead), plan.Plan)
+	protected.POST("/plans/create", authorize(permissions.PlansWrite), plan.CreatePlan)
+	protected.PUT("/plans/update/:id", authorize(permissions.PlansWrite), plan.UpdatePlan)
+	protected.DELETE("/plans/delete/:id", authorize(permissions.PlansWrite), plan.DeletePlan)
+	protected.GET("/plans", authorize(permissions.PlansRead), plan.AllPlans)
 	public.GET("/plans", plan.AllPlansPublic)
 
 	private.GET("/userplans/:userid", userplan.UserPlan)
-	protected.POST("/userplans/create", userplan.CreateUserPlan)
-	protected.PUT("/userplans/update/:id", userplan.UpdateUserPlan)
-	protected.DELETE("/userplans/delete/:id", userplan.DeleteUserPlan)
-	protected.GET("/userplans", userplan.AllUserPlans)
-
-	protected.GET("/servers/:id", server.Server)
-	protected.POST("/servers/create", server.CreateServer)
-	protected.PUT("/servers/update/:id", server.UpdateServer)
-	protected.DELETE("/servers/delete/:id", server.DeleteServer)
-	protected.GET("/server_connections", server.Connections)
+	protected.POST("/userplans/create", authorize(permissions.UserPlansWrite), userplan.CreateUserPlan)
+	protected.PUT("/userplans/update/:id", authorize(permissions.UserPlansWrite), userplan.UpdateUserPlan)
+	protected.DELETE("/userplans/delete/:id", authorize(permissions.UserPlansWrite), userplan.DeleteUserPlan)
+	protected.GET("/userplans", authorize(permissions.UserPlansRead), userplan.AllUserPlans)
+
+	protected.GET("/servers/:id", authorize(permissions.ServersCredentials), server.Server)
+	protected.POST("/servers/create", authorize(permissions.ServersWrite), server.CreateServer)
+	protected.PUT("/servers/update/:id", authorize(permissions.ServersWrite), server.UpdateServer)
+	protected.DELETE("/servers/delete/:id", authorize(permissions.ServersWrite), server.DeleteServer)
+	protected.GET("/server_connections", authorize(permissions.ServersRead), server.Connections)
+	protected.GET("/server_status/:id", authorize(permissions.ServersRead), server.Status)
+	protected.DELETE("/servers/credentials/:userid", authorize(permissions.ServersWrite), server.RevokeCredentials)
 	private.GET("/servers/connect/:id", server.Connect)
+	public.POST("/servers/usage", server.ReportUsage)
 	private.GET("/servers", server.AllServers)
 
-	protected.GET("/settings", settings.Settings)
-	protected.PUT("/settings/update", settings.UpdateSettings)
+	protected.GET("/settings", authorize(permissions.SettingsRead), settingsHandler.Settings)
+	protected.PUT("/settings/update", authorize(permissions.SettingsWrite), settingsHandler.UpdateSettings)
+	protected.GET("/settings/history", authorize(permissions.SettingsRead), settingsHandler.SettingsHistory)
 
-	public.POST("/message", message.Message)
+	publicEmail.POST("/message", message.Message)
+
+	protected.GET("/audit", authorize(permissions.AuditRead), audit.AuditLogs)
+
+	router.GET("/metrics", metrics.Handler)
+	router.GET("/healthz", health.Healthz)
+	router.GET("/readyz", health.Readyz)
 
 	router.Static("/assets", "./assets")
+
+	metrics.SetRoutes(router.Routes())
+	metrics.SetActiveSessions(server.ActiveDevices)
+	stripe.SetEnabled(func() bool { return settings.Bool(settings.StripeIntegrationActive) })
 	return router
 }
 
+// accessLog logs every request at info level
+func accessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		logger.Info(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       c.Request.Method + " " + c.Request.URL.Path,
+			Extra: map[string]interface{}{
+				"Status":  c.Writer.Status(),
+				"Latency": time.Since(start).String(),
+				"IP":      clientip.Get(c),
+			},
+		})
+	}
+}
+
 func auth(secret string, protected bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		conf := config.Load()
-		if conf.App.EnableAuth {
+		if settings.Bool(settings.EnableAuth) {
+			if strings.HasPrefix(c.GetHeader("Authorization"), "Bearer ") {
+				tokenAuth(c, protected)
+				return
+			}
+
 			var usersession models.UserAppSession
 
 			// Fetch auth token
@@ -123,9 +192,10 @@ func auth(secret string, protected bool) gin.HandlerFunc {
 					errMsg = "Auth cookie missing"
 				}
 				logger.Log(logger.Fields{
-					Loc:  "router.go - auth()",
-					Code: errors.AuthCookieMissing.Code,
-					Err:  errMsg,
+					RequestID: requestid.Get(c),
+					Loc:       "router.go - auth()",
+					Code:      errors.AuthCookieMissing.Code,
+					Err:       errMsg,
 				})
 				c.AbortWithStatusJSON(errors.AuthCookieMissing.Status, errors.AuthCookieMissing)
 				return
@@ -145,9 +215,10 @@ func auth(secret string, protected bool) gin.HandlerFunc {
 						errMsg = "Refresh cookie missing"
 					}
 					logger.Log(logger.Fields{
-						Loc:  "router.go - auth()",
-						Code: errors.RefresCookieMissing.Code,
-						Err:  errMsg,
+						RequestID: requestid.Get(c),
+						Loc:       "router.go - auth()",
+						Code:      errors.RefresCookieMissing.Code,
+						Err:       errMsg,
 					})
 					clearCookies(c)
 					c.AbortWithStatusJSON(errors.RefresCookieMissing.Status, errors.RefresCookieMissing)
@@ -157,9 +228,10 @@ func auth(secret string, protected bool) gin.HandlerFunc {
 				refreshClaims, err := jwt.ValidateToken(refreshToken.Value)
 				if err != nil {
 					logger.Log(logger.Fields{
-						Loc:  "router.go - auth()",
-						Code: errors.RefresCookieMissing.Code,
-						Err:  err.Error(),
+						RequestID: requestid.Get(c),
+						Loc:       "router.go - auth()",
+						Code:      errors.RefresCookieMissing.Code,
+						Err:       err.Error(),
 					})
 					clearCookies(c)
 					c.AbortWithStatusJSON(errors.TokenInvalid.Status, errors.TokenInvalid)
@@ -170,23 +242,29 @@ func auth(secret string, protected bool) gin.HandlerFunc {
 					UserID:     refreshClaims.UserID,
 					Identifier: refreshClaims.SessionIdentifier,
 				}
-
-				if err := usersession.Find(); err != nil {
-					logger.Log(logger.Fields{
-						Loc:   "router.go - auth()",
-						Code:  errors.InvalidIdentifier.Code,
-						Extra: map[string]interface{}{"Identifier": usersession.Identifier},
-						Err:   err.Error(),
-					})
-					clearCookies(c)
-					c.AbortWithStatusJSON(errors.InvalidIdentifier.Status, errors.InvalidIdentifier)
-					return
+			} else {
+				usersession = models.UserAppSession{
+					UserID:     authClaims.UserID,
+					Identifier: authClaims.SessionIdentifier,
 				}
+			}
 
+			// Check the session has not been logged out or revoked
+			if err := usersession.FindByIdentifier(); err != nil {
+				logger.Log(logger.Fields{
+					RequestID: requestid.Get(c),
+					Loc:       "router.go - auth()",
+					Code:      errors.InvalidIdentifier.Code,
+					Extra:     map[string]interface{}{"UserID": usersession.UserID},
+					Err:       err.Error(),
+				})
+				clearCookies(c)
+				c.AbortWithStatusJSON(errors.InvalidIdentifier.Status, errors.InvalidIdentifier)
+				return
 			}
 
 			// Check CSRF token
-			if conf.App.EnableCSRF {
+			if settings.Bool(settings.EnableCSRF) {
 				if authClaims.CSRF != c.GetHeader("X-CSRF-Token") {
 					var reason string
 					authCSRF := ""
@@ -199,86 +277,200 @@ func auth(secret string, protected bool) gin.HandlerFunc {
 						authCSRF = authClaims.CSRF
 					}
 					logger.Log(logger.Fields{
-						Loc:   "router.go - auth()",
-						Code:  errors.CSRFTokenInvalid.Code,
-						Extra: map[string]interface{}{"auth-CSRF": authCSRF, "head-CSRF": c.GetHeader("X-CSRF-Token")},
-						Err:   reason,
+						RequestID: requestid.Get(c),
+						Loc:       "router.go - auth()",
+						Code:      errors.CSRFTokenInvalid.Code,
+						Extra:     map[string]interface{}{"auth-CSRF": authCSRF, "head-CSRF": c.GetHeader("X-CSRF-Token")},
+						Err:       reason,
 					})
 					c.AbortWithStatusJSON(errors.CSRFTokenInvalid.Status, errors.CSRFTokenInvalid)
 					return
 				}
 			}
 
-			if protected {
-				var user models.User
-				user.ID = authClaims.UserID
-				if err := user.Find(); err != nil {
-					logger.Log(logger.Fields{
-						Loc: "router.go - auth()",
-						Extra: map[string]interface{}{
-							"UserID": authClaims.UserID,
-							"Detail": "User Not found when checking user type",
-						},
-						Err: err.Error(),
-					})
-					c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
-					return
-				}
-
-				if user.Type != models.UserTypeAdmin {
-					logger.Log(logger.Fields{
-						Loc:  "router.go - auth()",
-						Code: errors.ProtectedRouted.Code,
-						Extra: map[string]interface{}{
-							"UserID": authClaims.UserID,
-						},
-						Err: "User does not have permission to access route",
-					})
-					c.AbortWithStatusJSON(errors.ProtectedRouted.Status, errors.ProtectedRouted)
-					return
-				}
+			if protected && !checkAdmin(c, usersession.UserID) {
+				return
 			}
 
-			// create a new user session
-			var newUserSession models.UserAppSession
-			if err := newUserSession.New(authClaims.UserID); err != nil {
+			// record the device is still using the session
+			if err := usersession.Touch(clientip.Get(c), c.Request.UserAgent()); err != nil {
 				logger.Log(logger.Fields{
-					Loc:   "/login - LoginUser() - Create session",
-					Code:  errors.InternalServerError.Code,
-					Extra: map[string]interface{}{"UserID": authClaims.UserID},
-					Err:   err.Error(),
+					RequestID: requestid.Get(c),
+					Loc:       "router.go - auth()",
+					Code:      errors.InternalServerError.Code,
+					Extra:     map[string]interface{}{"UserID": usersession.UserID},
+					Err:       err.Error(),
 				})
-				c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
-				return
 			}
 
 			// If all auth checks pass create fresh tokens
-			newAuthToken, newRefreshToken, newCsrfToken, err := jwt.Tokens(newUserSession)
+			newAuthToken, newRefreshToken, newCsrfToken, err := jwt.Tokens(usersession)
 			if err != nil {
 				logger.Log(logger.Fields{
-					Loc:   "router.go - auth()",
-					Code:  errors.InternalServerError.Code,
-					Extra: map[string]interface{}{"UserID": authClaims.UserID},
-					Err:   err.Error(),
+					RequestID: requestid.Get(c),
+					Loc:       "router.go - auth()",
+					Code:      errors.InternalServerError.Code,
+					Extra:     map[string]interface{}{"UserID": authClaims.UserID},
+					Err:       err.Error(),
 				})
 				c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
 				return
 			}
 
-			// Add user id to the context for use within the routes
-			c.Set("UserID", newUserSession.UserID)
+			// Add user and session id to the context for use within the routes
+			c.Set("UserID", usersession.UserID)
+			c.Set("SessionID", usersession.ID)
 
 			// TODO: Change the domain name and add correct maxAge time
 			authCookieMaxAge := 24 * 60 * conf.App.AuthCookieAge
 			refreshCookieMaxAge := 24 * 60 * conf.App.RefreshCookieAge
 			c.SetCookie(conf.App.AuthCookieName, newAuthToken, authCookieMaxAge, "/", conf.App.Domain, false, true)
 			c.SetCookie(conf.App.RefreshCookieName, newRefreshToken, refreshCookieMaxAge, "/", conf.App.Domain, false, true)
-			c.SetCookie("uid", strconv.FormatUint(uint64(newUserSession.UserID), 10), authCookieMaxAge, "/", conf.App.Domain, false, false)
+			c.SetCookie("uid", strconv.FormatUint(uint64(usersession.UserID), 10), authCookieMaxAge, "/", conf.App.Domain, false, false)
 			c.Header("X-CSRF-Token", newCsrfToken)
 		}
 	}
 }
 
+// tokenAuth authenticates a request made with an API token rather than
+// session cookies. Browsers never send the token on their own so the
+// CSRF check is not needed.
+func tokenAuth(c *gin.Context, protected bool) {
+	var token models.APIToken
+	bearer := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if err := token.FindByToken(bearer); err != nil || token.Expired(time.Now()) {
+		errMsg := "API token has expired"
+		if err != nil {
+			errMsg = err.Error()
+		}
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "router.go - tokenAuth()",
+			Code:      errors.APITokenInvalid.Code,
+			Err:       errMsg,
+		})
+		c.AbortWithStatusJSON(errors.APITokenInvalid.Status, errors.APITokenInvalid)
+		return
+	}
+
+	scope := models.APITokenScopeRead
+	if protected {
+		scope = models.APITokenScopeAdmin
+	} else if c.Request.Method != "GET" && c.Request.Method != "HEAD" {
+		scope = models.APITokenScopeWrite
+	}
+	if !token.HasScope(scope) {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "router.go - tokenAuth()",
+			Code:      errors.APITokenScope.Code,
+			Extra: map[string]interface{}{
+				"UserID":     token.UserID,
+				"APITokenID": token.ID,
+				"Scope":      scope,
+			},
+			Err: errors.APITokenScope.Detail,
+		})
+		c.AbortWithStatusJSON(errors.APITokenScope.Status, errors.APITokenScope)
+		return
+	}
+
+	if protected && !checkAdmin(c, token.UserID) {
+		return
+	}
+
+	if err := token.Touch(clientip.Get(c)); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "router.go - tokenAuth()",
+			Code:      errors.InternalServerError.Code,
+			Extra:     map[string]interface{}{"APITokenID": token.ID},
+			Err:       err.Error(),
+		})
+	}
+
+	c.Set("UserID", token.UserID)
+	c.Set("APITokenID", token.ID)
+}
+
+// checkAdmin aborts the request unless the user is an admin who meets
+// the requirements for using the protected routes
+func checkAdmin(c *gin.Context, userID uint) bool {
+	var user models.User
+	user.ID = userID
+	if err := user.Find(); err != nil {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "router.go - checkAdmin()",
+			Extra: map[string]interface{}{
+				"UserID": userID,
+				"Detail": "User Not found when checking user type",
+			},
+			Err: err.Error(),
+		})
+		c.AbortWithStatusJSON(errors.InternalServerError.Status, errors.InternalServerError)
+		return false
+	}
+
+	if user.Type != models.UserTypeAdmin {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "router.go - checkAdmin()",
+			Code:      errors.ProtectedRouted.Code,
+			Extra: map[string]interface{}{
+				"UserID": userID,
+			},
+			Err: "User does not have permission to access route",
+		})
+		c.AbortWithStatusJSON(errors.ProtectedRouted.Status, errors.ProtectedRouted)
+		return false
+	}
+
+	if settings.Bool(settings.AdminTwoFactorRequired) && !user.TwoFactorEnabled {
+		logger.Log(logger.Fields{
+			RequestID: requestid.Get(c),
+			Loc:       "router.go - checkAdmin()",
+			Code:      errors.TwoFactorRequired.Code,
+			Extra: map[string]interface{}{
+				"UserID": userID,
+			},
+			Err: "Admin does not have two factor authentication enabled",
+		})
+		c.AbortWithStatusJSON(errors.TwoFactorRequired.Status, errors.TwoFactorRequired)
+		return false
+	}
+
+	c.Set("Role", permissions.RoleOf(user))
+	return true
+}
+
+// authorize aborts the request unless the admins role grants the
+// permission. It must come after the auth middleware.
+func authorize(perm permissions.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !settings.Bool(settings.EnableAuth) {
+			return
+		}
+		role, _ := c.Get("Role")
+		userRole, _ := role.(models.UserRole)
+		if !permissions.Has(userRole, perm) {
+			userID, _ := c.Get("UserID")
+			logger.Log(logger.Fields{
+				RequestID: requestid.Get(c),
+				Loc:       "router.go - authorize()",
+				Code:      errors.ProtectedRouted.Code,
+				Extra: map[string]interface{}{
+					"UserID":     userID,
+					"Role":       userRole,
+					"Permission": perm,
+				},
+				Err: "Role does not grant the permission required by the route",
+			})
+			c.AbortWithStatusJSON(errors.ProtectedRouted.Status, errors.ProtectedRouted)
+		}
+	}
+}
+
 func clearCookies(c *gin.Context) {
 	conf := config.Load()
 	c.SetCookie(conf.App.AuthCookieName, "", -1, "/", conf.App.Domain, false, true)
@@ -0,0 +1,209 @@
+package settings
+
+import (
+	"eirevpn/api/config"
+	"eirevpn/api/db"
+	"eirevpn/api/models"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Keys of the built in settings. Until a setting is changed its value is
+// read from the config file.
+const (
+	EnableCSRF              = "EnableCSRF"
+	EnableSubscriptions     = "EnableSubscriptions"
+	EnableAuth              = "EnableAuth"
+	StripeIntegrationActive = "StripeIntegrationActive"
+	AdminTwoFactorRequired  = "AdminTwoFactorRequired"
+	AllowedOrigins          = "AllowedOrigins"
+)
+
+// refreshInterval is how often the latest change is checked for, so a
+// change made through another instance is seen within this long
+const refreshInterval = 5 * time.Second
+
+// definition describes a built in setting
+type definition struct {
+	// value reads the default from the config
+	value func(conf config.Config) interface{}
+}
+
+var builtin = map[string]definition{
+	EnableCSRF:              {func(conf config.Config) interface{} { return conf.App.EnableCSRF }},
+	EnableSubscriptions:     {func(conf config.Config) interface{} { return conf.App.EnableSubscriptions }},
+	EnableAuth:              {func(conf config.Config) interface{} { return conf.App.EnableAuth }},
+	StripeIntegrationActive: {func(conf config.Config) interface{} { return conf.Stripe.IntegrationActive }},
+	AdminTwoFactorRequired:  {func(conf config.Config) interface{} { return conf.App.AdminTwoFactorRequired }},
+	AllowedOrigins:          {func(conf config.Config) interface{} { return conf.App.AllowedOrigins }},
+}
+
+// flagName is the form feature flag keys must take
+var flagName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]{0,63}$`)
+
+var (
+	mu        sync.RWMutex
+	values    = map[string]string{}
+	revision  uint
+	checkedAt time.Time
+
+	refreshMu sync.Mutex
+)
+
+// Bool returns the value of a boolean setting or feature flag. Flags
+// which have never been set are off.
+func Bool(key string) bool {
+	var b bool
+	if raw, ok := stored(key); ok && json.Unmarshal([]byte(raw), &b) == nil {
+		return b
+	}
+	if def, ok := builtin[key]; ok {
+		b, _ = def.value(config.Load()).(bool)
+	}
+	return b
+}
+
+// Strings returns the value of a list setting
+func Strings(key string) []string {
+	var list []string
+	if raw, ok := stored(key); ok && json.Unmarshal([]byte(raw), &list) == nil {
+		return list
+	}
+	if def, ok := builtin[key]; ok {
+		list, _ = def.value(config.Load()).([]string)
+	}
+	return list
+}
+
+// Flags returns the names of every feature flag which has been set, in
+// order
+func Flags() []string {
+	refresh(false)
+	mu.RLock()
+	defer mu.RUnlock()
+	var names []string
+	for key := range values {
+		if _, ok := builtin[key]; !ok {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetAll validates and stores the values of the settings, recording the
+// changes against the actor. Keys which are not built in are feature flags
+// and must be booleans. The values are stored in one transaction, so either
+// every change is made or none are, and the settings are reloaded once. It
+// returns the keys which changed, in order.
+func SetAll(updates map[string]interface{}, actorID uint) ([]string, error) {
+	raw := make(map[string]string, len(updates))
+	for key, value := range updates {
+		if err := Validate(key, value); err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		raw[key] = string(b)
+	}
+	var changed models.AllSettings
+	if err := changed.Update(raw, actorID); err != nil {
+		return nil, err
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(changed))
+	for i, s := range changed {
+		keys[i] = s.Key
+	}
+	Reload()
+	return keys, nil
+}
+
+// Validate checks the value has the type the setting takes and that the
+// names of new feature flags are well formed
+func Validate(key string, value interface{}) error {
+	switch key {
+	case AllowedOrigins:
+		if _, ok := value.([]string); !ok {
+			return fmt.Errorf("%s must be a list of origins", key)
+		}
+	default:
+		if _, ok := builtin[key]; !ok && !flagName.MatchString(key) {
+			return fmt.Errorf("%q is not a valid feature flag name", key)
+		}
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be true or false", key)
+		}
+	}
+	return nil
+}
+
+// Reload loads the settings from the database straight away rather than
+// waiting for the next check
+func Reload() {
+	refresh(true)
+}
+
+// stored returns the raw value of the setting if it has been changed
+func stored(key string) (string, bool) {
+	refresh(false)
+	mu.RLock()
+	defer mu.RUnlock()
+	raw, ok := values[key]
+	return raw, ok
+}
+
+// refresh reloads the settings when another change has been made since
+// they were last loaded. The check is made at most once per refresh
+// interval unless forced, and a failed check keeps the cached settings.
+func refresh(force bool) {
+	if !force && !due() {
+		return
+	}
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	if (!force && !due()) || db.GetDB() == nil {
+		return
+	}
+
+	var changes models.AllSettingChanges
+	latest, err := changes.LatestRevision()
+	if err != nil {
+		return
+	}
+	mu.RLock()
+	loaded := values
+	stale := latest != revision || checkedAt.IsZero()
+	mu.RUnlock()
+
+	if stale {
+		var all models.AllSettings
+		if err := all.FindAll(); err != nil {
+			return
+		}
+		loaded = make(map[string]string, len(all))
+		for _, s := range all {
+			loaded[s.Key] = s.Value
+		}
+	}
+
+	mu.Lock()
+	values = loaded
+	revision = latest
+	checkedAt = time.Now()
+	mu.Unlock()
+}
+
+func due() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return time.Since(checkedAt) >= refreshInterval
+}
@@ -0,0 +1,83 @@
+package test
+
+import (
+	"bytes"
+	"eirevpn/api/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLogsRoute(t *testing.T) {
+	type auditResponse struct {
+		Data struct {
+			Count     int               `json:"count"`
+			AuditLogs []models.AuditLog `json:"audit_logs"`
+		} `json:"data"`
+	}
+
+	makeRequest := func(t *testing.T, user *models.User, query string) (int, auditResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/protected/audit"+query, nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		var resp auditResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	updatePlan := func(t *testing.T, user *models.User, planID uint) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		j, _ := json.Marshal(map[string]interface{}{"name": "Renamed Plan"})
+		url := fmt.Sprintf("/api/protected/plans/update/%d", planID)
+		req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(j))
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		assertCorrectStatus(t, 200, w.Code)
+	}
+
+	t.Run("Records plan update", func(t *testing.T) {
+		user := CreateAdminUser()
+		plan := CreatePlan()
+		updatePlan(t, user, plan.ID)
+		code, resp := makeRequest(t, user, "?action=plan.update")
+		assertCorrectStatus(t, 200, code)
+		if assert.Equal(t, 1, resp.Data.Count) {
+			log := resp.Data.AuditLogs[0]
+			assert.Equal(t, user.ID, log.ActorID)
+			assert.Equal(t, plan.ID, log.TargetID)
+			assert.Equal(t, "Renamed Plan", log.Changes["name"].After)
+		}
+		CreateCleanDB()
+	})
+
+	t.Run("Filters by actor", func(t *testing.T) {
+		user := CreateAdminUser()
+		plan := CreatePlan()
+		updatePlan(t, user, plan.ID)
+		code, resp := makeRequest(t, user, fmt.Sprintf("?actor_id=%d", user.ID+1))
+		assertCorrectStatus(t, 200, code)
+		assert.Equal(t, 0, resp.Data.Count)
+		CreateCleanDB()
+	})
+
+	t.Run("Invalid filter", func(t *testing.T) {
+		user := CreateAdminUser()
+		code, _ := makeRequest(t, user, "?since=yesterday")
+		assertCorrectStatus(t, 400, code)
+		CreateCleanDB()
+	})
+
+	t.Run("Support admin cannot read audit logs", func(t *testing.T) {
+		user := CreateAdminUserWithRole(models.UserRoleSupport)
+		code, _ := makeRequest(t, user, "")
+		assertCorrectStatus(t, 403, code)
+		CreateCleanDB()
+	})
+}
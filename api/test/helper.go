@@ -4,7 +4,9 @@ import (
 	"eirevpn/api/config"
 	"eirevpn/api/db"
 	"eirevpn/api/errors"
+	"eirevpn/api/migrations"
 	"eirevpn/api/models"
+	"eirevpn/api/settings"
 	"eirevpn/api/util/jwt"
 	"encoding/json"
 	"fmt"
@@ -56,8 +58,13 @@ func InitDB() {
 
 	conf := config.Load()
 
-	db.Init(conf, false, models.Get())
+	if err := db.Init(conf, false); err != nil {
+		log.Fatal(err)
+	}
 	dbInstance = db.GetDB()
+	if _, err := migrations.Up(dbInstance); err != nil {
+		log.Fatal(err)
+	}
 	log.Println("Testing Database connected")
 
 	CreateCleanDB()
@@ -88,6 +95,58 @@ func CreateAdminUser() *models.User {
 	return &user
 }
 
+// CreateAdminUserWithRole adds a new admin user with the given role
+func CreateAdminUserWithRole(role models.UserRole) *models.User {
+	user := models.User{
+		FirstName: "Dylan",
+		LastName:  "Kilkenny",
+		Email:     "support@email.com",
+		Password:  "password",
+		Type:      models.UserTypeAdmin,
+		Role:      role}
+	err := dbInstance.Create(&user).Error
+	if err != nil {
+		fmt.Println("CreateAdminUserWithRole() - ", err)
+	}
+	return &user
+}
+
+// CreateTwoFactorUser adds a new user with two factor authentication
+// enabled using the given TOTP secret
+func CreateTwoFactorUser(secret string) *models.User {
+	user := models.User{
+		FirstName:        "Dylan",
+		LastName:         "Kilkenny",
+		Email:            "email@email.com",
+		Password:         "password",
+		TwoFactorEnabled: true,
+		TOTPSecret:       secret,
+	}
+	err := dbInstance.Create(&user).Error
+	if err != nil {
+		fmt.Println("CreateTwoFactorUser() - ", err)
+	}
+	return &user
+}
+
+// CreateLockout locks the account out of logging in for the next hour
+func CreateLockout(email string) *models.Lockout {
+	until := time.Now().Add(time.Hour)
+	lockout := models.Lockout{
+		Scope:       models.LockoutScopeLogin,
+		Kind:        models.LockoutKindAccount,
+		Key:         email,
+		Failures:    10,
+		LastFailure: time.Now(),
+		LockedUntil: &until,
+	}
+	err := dbInstance.Create(&lockout).Error
+	if err != nil {
+		fmt.Println("CreateLockout() - ", err)
+	}
+	return &lockout
+}
+
 // CreatePlan creates a new plan record in the db
 func CreatePlan() *models.Plan {
 	plan := models.Plan{
@@ -128,6 +187,7 @@ func CreateServer() *models.Server {
 		Type:        models.ServerTypeProxy,
 		IP:          "127.0.0.1",
 		Port:        8080,
+		APISecret:   "secret",
 	}
 	err := dbInstance.Create(&server).Error
 	if err != nil {
@@ -136,6 +196,21 @@ func CreateServer() *models.Server {
 	return &server
 }
 
+// CreateProxyCredential issues a proxy credential for the user on the server
+func CreateProxyCredential(userID, serverID uint) *models.ProxyCredential {
+	cred := models.ProxyCredential{
+		UserID:   userID,
+		ServerID: serverID,
+		Username: "username",
+		Password: "password",
+	}
+	err := dbInstance.Create(&cred).Error
+	if err != nil {
+		fmt.Println("CreateProxyCredential() - ", err)
+	}
+	return &cred
+}
+
 // CreateCleanDB drops exisitng tables and recreates them
 func CreateCleanDB() {
 	dbInstance.DropTableIfExists(&models.User{})
@@ -143,6 +218,15 @@ func CreateCleanDB() {
 	dbInstance.DropTableIfExists(&models.UserAppSession{})
 	dbInstance.DropTableIfExists(&models.Server{})
 	dbInstance.DropTableIfExists(&models.UserPlan{})
+	dbInstance.DropTableIfExists(&models.ProxyCredential{})
+	dbInstance.DropTableIfExists(&models.DataUsage{})
+	dbInstance.DropTableIfExists(&models.DeviceSession{})
+	dbInstance.DropTableIfExists(&models.RecoveryCode{})
+	dbInstance.DropTableIfExists(&models.Lockout{})
+	dbInstance.DropTableIfExists(&models.APIToken{})
+	dbInstance.DropTableIfExists(&models.AuditLog{})
+	dbInstance.DropTableIfExists(&models.Setting{})
+	dbInstance.DropTableIfExists(&models.SettingChange{})
 
 	if !dbInstance.HasTable(&models.User{}) {
 		dbInstance.CreateTable(&models.User{})
@@ -163,6 +247,41 @@ func CreateCleanDB() {
 	if !dbInstance.HasTable(&models.UserPlan{}) {
 		dbInstance.CreateTable(&models.UserPlan{})
 	}
+
+	if !dbInstance.HasTable(&models.ProxyCredential{}) {
+		dbInstance.CreateTable(&models.ProxyCredential{})
+	}
+
+	if !dbInstance.HasTable(&models.DataUsage{}) {
+		dbInstance.CreateTable(&models.DataUsage{})
+	}
+
+	if !dbInstance.HasTable(&models.DeviceSession{}) {
+		dbInstance.CreateTable(&models.DeviceSession{})
+	}
+
+	if !dbInstance.HasTable(&models.RecoveryCode{}) {
+		dbInstance.CreateTable(&models.RecoveryCode{})
+	}
+
+	if !dbInstance.HasTable(&models.Lockout{}) {
+		dbInstance.CreateTable(&models.Lockout{})
+	}
+
+	if !dbInstance.HasTable(&models.APIToken{}) {
+		dbInstance.CreateTable(&models.APIToken{})
+	}
+
+	if !dbInstance.HasTable(&models.AuditLog{}) {
+		dbInstance.CreateTable(&models.AuditLog{})
+	}
+	if !dbInstance.HasTable(&models.Setting{}) {
+		dbInstance.CreateTable(&models.Setting{})
+	}
+	if !dbInstance.HasTable(&models.SettingChange{}) {
+		dbInstance.CreateTable(&models.SettingChange{})
+	}
+	settings.Reload()
 }
 
 // DropPlanTable dros the plan table from the db
@@ -206,6 +325,17 @@ func AddTokens(u *models.User, req *http.Request) {
 	req.Header.Set("X-CSRF-Token", csrfToken)
 }
 
+// CreateAPIToken mints an API token with the given scopes for the user
+// and returns the plain text token
+func CreateAPIToken(u *models.User, scopes string) string {
+	token := models.APIToken{Name: "test", Scopes: scopes}
+	plain, err := token.New(u.ID)
+	if err != nil {
+		fmt.Println("CreateAPIToken() - ", err)
+	}
+	return plain
+}
+
 // DeleteIdentifier removes the users session identifier
 func DeleteIdentifier(u *models.User) {
 	var usersession models.UserAppSession
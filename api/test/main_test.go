@@ -4,10 +4,13 @@ import (
 	"eirevpn/api/config"
 	"eirevpn/api/logger"
 	"eirevpn/api/router"
+	"encoding/json"
 	"flag"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -19,11 +22,13 @@ func TestMain(m *testing.M) {
 	flag.BoolVar(&logging, "logging", false, "enable logging")
 	flag.Parse()
 
-	config.Init("../config.test.yaml")
+	if err := config.Init("../config.test.yaml"); err != nil {
+		log.Fatal(err)
+	}
 
 	InitDB()
-	r = router.Init(logging)
 	logger.Init(logging)
+	r = router.Init(logging)
 	code := m.Run()
 
 	os.Exit(code)
@@ -118,3 +123,209 @@ func TestAuthTokens(t *testing.T) {
 		CreateCleanDB()
 	})
 }
+
+func TestRateLimit(t *testing.T) {
+	makeRequest := func(t *testing.T, forwardedFor string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/message", nil)
+		// a client of its own so other tests do not share its limit
+		req.RemoteAddr = "203.0.113.10:41000"
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Requests over the limit are refused", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			resp := makeRequest(t, "")
+			if resp.Header().Get("X-RateLimit-Limit") == "" {
+				t.Fatalf("Rate limit headers missing")
+			}
+			if resp.Header().Get("X-RateLimit-Remaining") == "0" {
+				break
+			}
+		}
+		resp := makeRequest(t, "")
+		apiErr := bindError(resp)
+		assertCorrectStatus(t, 429, apiErr.Status)
+		assertCorrectCode(t, "RATELIMITED", apiErr.Code)
+		if resp.Header().Get("Retry-After") == "" {
+			t.Errorf("Retry-After header missing")
+		}
+	})
+
+	t.Run("Forwarding headers from untrusted clients are ignored", func(t *testing.T) {
+		resp := makeRequest(t, "198.51.100.7")
+		apiErr := bindError(resp)
+		assertCorrectStatus(t, 429, apiErr.Status)
+		assertCorrectCode(t, "RATELIMITED", apiErr.Code)
+	})
+}
+
+func TestAPITokenAuth(t *testing.T) {
+	makeRequest := func(t *testing.T, method, url, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Successful token authentication", func(t *testing.T) {
+		user := CreateUser()
+		_ = CreateServer()
+		token := CreateAPIToken(user, "read")
+		want := 200
+		got := makeRequest(t, "GET", "/api/private/servers", token)
+		assertCorrectStatus(t, want, got.Code)
+		CreateCleanDB()
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		user := CreateUser()
+		token := CreateAPIToken(user, "read")
+		wantStatus := 401
+		wantCode := "APITOKENINVALID"
+		apiErr := bindError(makeRequest(t, "GET", "/api/private/servers", token+"x"))
+		assertCorrectStatus(t, wantStatus, apiErr.Status)
+		assertCorrectCode(t, wantCode, apiErr.Code)
+		CreateCleanDB()
+	})
+
+	t.Run("Read token cannot write", func(t *testing.T) {
+		user := CreateUser()
+		token := CreateAPIToken(user, "read")
+		wantStatus := 403
+		wantCode := "APITOKENSCOPE"
+		apiErr := bindError(makeRequest(t, "DELETE", "/api/private/user/sessions/1", token))
+		assertCorrectStatus(t, wantStatus, apiErr.Status)
+		assertCorrectCode(t, wantCode, apiErr.Code)
+		CreateCleanDB()
+	})
+
+	t.Run("Protected routes need the admin scope", func(t *testing.T) {
+		user := CreateAdminUser()
+		token := CreateAPIToken(user, "read,write")
+		wantStatus := 403
+		wantCode := "APITOKENSCOPE"
+		apiErr := bindError(makeRequest(t, "GET", "/api/protected/users", token))
+		assertCorrectStatus(t, wantStatus, apiErr.Status)
+		assertCorrectCode(t, wantCode, apiErr.Code)
+		CreateCleanDB()
+	})
+
+	t.Run("Admin token", func(t *testing.T) {
+		user := CreateAdminUser()
+		token := CreateAPIToken(user, "admin")
+		want := 200
+		got := makeRequest(t, "GET", "/api/protected/users", token)
+		assertCorrectStatus(t, want, got.Code)
+		CreateCleanDB()
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	makeRequest := func(t *testing.T, requestID string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/private/user/get/1", nil)
+		if requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Client request ID is returned", func(t *testing.T) {
+		resp := makeRequest(t, "client-id-123")
+		if got := resp.Header().Get("X-Request-ID"); got != "client-id-123" {
+			t.Errorf("got X-Request-ID %q, want %q", got, "client-id-123")
+		}
+		var body map[string]interface{}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if body["request_id"] != "client-id-123" {
+			t.Errorf("got request_id %v in error response, want %q", body["request_id"], "client-id-123")
+		}
+	})
+
+	t.Run("Request ID is generated", func(t *testing.T) {
+		resp := makeRequest(t, "not a valid id!")
+		if got := resp.Header().Get("X-Request-ID"); len(got) != 32 {
+			t.Errorf("got X-Request-ID %q, want a generated ID", got)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	makeRequest := func(t *testing.T, token string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Requires the metrics token", func(t *testing.T) {
+		resp := makeRequest(t, "wrongtoken")
+		apiErr := bindError(resp)
+		assertCorrectStatus(t, 401, apiErr.Status)
+		assertCorrectCode(t, "METRICSTOKENINVALID", apiErr.Code)
+	})
+
+	t.Run("Requests are counted by route", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/plans", nil)
+		r.ServeHTTP(w, req)
+
+		resp := makeRequest(t, config.Load().Metrics.Token)
+		assertCorrectStatus(t, 200, resp.Code)
+		body := resp.Body.String()
+		want := `eirevpn_http_requests_total{method="GET",route="/api/plans",status="200"}`
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %s", want)
+		}
+		if !strings.Contains(body, "eirevpn_active_sessions ") {
+			t.Errorf("metrics missing eirevpn_active_sessions")
+		}
+	})
+}
+
+func TestHealthRoutes(t *testing.T) {
+	makeRequest := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Liveness", func(t *testing.T) {
+		resp := makeRequest(t, "/healthz")
+		assertCorrectStatus(t, 200, resp.Code)
+	})
+
+	t.Run("Readiness checks the database and config", func(t *testing.T) {
+		resp := makeRequest(t, "/readyz")
+		assertCorrectStatus(t, 200, resp.Code)
+		var body struct {
+			Data struct {
+				Checks map[string]struct {
+					Status string `json:"status"`
+				} `json:"checks"`
+			} `json:"data"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		for _, name := range []string{"config", "database"} {
+			if got := body.Data.Checks[name].Status; got != "ok" {
+				t.Errorf("got %s check status %q, want %q", name, got, "ok")
+			}
+		}
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetServerRoute(t *testing.T) {
@@ -137,6 +138,33 @@ func TestAllServersRoute(t *testing.T) {
 		CreateCleanDB()
 	})
 
+	t.Run("Unhealthy servers hidden", func(t *testing.T) {
+		s := CreateServer()
+		s.Healthy = false
+		s.LastChecked = time.Now()
+		s.FailedChecks = 3
+		if err := s.SaveHealth(); err != nil {
+			t.Fatal(err)
+		}
+		_ = CreateServer()
+		user := CreateUser()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/private/servers", nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		assertCorrectStatus(t, 200, w.Code)
+		var resp struct {
+			Data struct {
+				Servers []models.Server `json:"servers"`
+			} `json:"data"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if len(resp.Data.Servers) != 1 {
+			t.Errorf("got %d servers want 1", len(resp.Data.Servers))
+		}
+		CreateCleanDB()
+	})
+
 	t.Run("Internal Server Error", func(t *testing.T) {
 		user := CreateUser()
 		want := 500
@@ -272,3 +300,222 @@ func TestConnectServerRoute(t *testing.T) {
 	})
 
 }
+
+func TestConnectFullServerRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User, serverID uint) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/api/private/servers/connect/%d", serverID)
+		req, _ := http.NewRequest("GET", url, nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Server Full With Alternative", func(t *testing.T) {
+		full := CreateServer()
+		full.MaxUsers = 10
+		full.Users = 10
+		full.Save()
+		alt := CreateServer()
+		user := CreateUser()
+		w := makeRequest(t, user, full.ID)
+		assertCorrectStatus(t, 503, w.Code)
+		var resp struct {
+			Code      string `json:"code"`
+			Suggested struct {
+				ID uint `json:"id"`
+			} `json:"suggested_server"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		assertCorrectCode(t, "SERVERFULL", resp.Code)
+		if resp.Suggested.ID != alt.ID {
+			t.Errorf("got suggested server %d want %d", resp.Suggested.ID, alt.ID)
+		}
+		CreateCleanDB()
+	})
+
+	t.Run("Server Disabled With Alternative", func(t *testing.T) {
+		disabled := CreateServer()
+		disabled.Disabled = true
+		disabled.Save()
+		alt := CreateServer()
+		user := CreateUser()
+		w := makeRequest(t, user, disabled.ID)
+		assertCorrectStatus(t, 503, w.Code)
+		var resp struct {
+			Code      string `json:"code"`
+			Suggested struct {
+				ID uint `json:"id"`
+			} `json:"suggested_server"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		assertCorrectCode(t, "SERVERDISABLED", resp.Code)
+		if resp.Suggested.ID != alt.ID {
+			t.Errorf("got suggested server %d want %d", resp.Suggested.ID, alt.ID)
+		}
+		CreateCleanDB()
+	})
+
+	t.Run("Server Below Capacity", func(t *testing.T) {
+		s := CreateServer()
+		s.MaxUsers = 10
+		s.Users = 9
+		// each user may have many connections open
+		s.Connections = 50
+		s.Save()
+		user := CreateUser()
+		w := makeRequest(t, user, s.ID)
+		assertCorrectStatus(t, 200, w.Code)
+		CreateCleanDB()
+	})
+}
+
+func TestConnectBestServerRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User, country string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/api/private/servers/connect/best?country=%s", country)
+		req, _ := http.NewRequest("GET", url, nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Least Loaded Server Chosen", func(t *testing.T) {
+		busy := CreateServer()
+		busy.Connections = 50
+		busy.SaveLoad()
+		idle := CreateServer()
+		idle.Connections = 2
+		idle.SaveLoad()
+		user := CreateUser()
+		w := makeRequest(t, user, "IE")
+		assertCorrectStatus(t, 200, w.Code)
+		var resp struct {
+			Data struct {
+				ServerID uint `json:"server_id"`
+			} `json:"data"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp.Data.ServerID != idle.ID {
+			t.Errorf("got server %d want %d", resp.Data.ServerID, idle.ID)
+		}
+		CreateCleanDB()
+	})
+
+	t.Run("No Server In Country", func(t *testing.T) {
+		_ = CreateServer()
+		user := CreateUser()
+		w := makeRequest(t, user, "FR")
+		assertCorrectStatus(t, 400, w.Code)
+		assertCorrectCode(t, "NOSERVERAVAIL", bindError(w).Code)
+		CreateCleanDB()
+	})
+}
+
+func TestRevokeCredentialsRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User, userID uint) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/api/protected/servers/credentials/%d", userID)
+		req, _ := http.NewRequest("DELETE", url, nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Successful Revoke Credentials", func(t *testing.T) {
+		s := CreateServer()
+		user := CreateAdminUser()
+		_ = CreateProxyCredential(user.ID, s.ID)
+		want := 200
+		got := makeRequest(t, user, user.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("No Credentials Issued", func(t *testing.T) {
+		user := CreateAdminUser()
+		want := 200
+		got := makeRequest(t, user, user.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Not Admin", func(t *testing.T) {
+		user := CreateUser()
+		want := 403
+		got := makeRequest(t, user, user.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+}
+
+func TestServerStatusRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User, serverID uint) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/api/protected/server_status/%d", serverID)
+		req, _ := http.NewRequest("GET", url, nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Successful Server Status", func(t *testing.T) {
+		s := CreateServer()
+		user := CreateAdminUser()
+		want := 200
+		got := makeRequest(t, user, s.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Server not found", func(t *testing.T) {
+		user := CreateAdminUser()
+		want := 400
+		got := makeRequest(t, user, 999)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+}
+
+func TestReportUsageRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, secret string, serverID, userID uint) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"server_id":%d,"usage":[{"user_id":%d,"bytes_up":100,"bytes_down":2000}]}`, serverID, userID)
+		req, _ := http.NewRequest("POST", "/api/servers/usage", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+secret)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Successful Usage Report", func(t *testing.T) {
+		s := CreateServer()
+		user := CreateUser()
+		want := 200
+		got := makeRequest(t, s.APISecret, s.ID, user.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Wrong Secret", func(t *testing.T) {
+		s := CreateServer()
+		user := CreateUser()
+		want := 401
+		got := makeRequest(t, "wrong", s.ID, user.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Server not found", func(t *testing.T) {
+		user := CreateUser()
+		want := 400
+		got := makeRequest(t, "secret", 999, user.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+}
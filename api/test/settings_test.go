@@ -0,0 +1,123 @@
+package test
+
+import (
+	"bytes"
+	"eirevpn/api/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingsRoutes(t *testing.T) {
+	type settingsFields struct {
+		EnableCSRF          string          `json:"enableCsrf"`
+		EnableSubscriptions string          `json:"enableSubscriptions"`
+		EnableAuth          string          `json:"enableAuth"`
+		IntegrationActive   string          `json:"enableStripe"`
+		AdminTwoFactor      string          `json:"requireAdminTwoFactor"`
+		AllowedOrigins      []string        `json:"allowedOrigins"`
+		Flags               map[string]bool `json:"flags"`
+	}
+
+	type settingsResponse struct {
+		Data struct {
+			Settings settingsFields `json:"settings"`
+		} `json:"data"`
+	}
+
+	type historyResponse struct {
+		Data struct {
+			Count   int                    `json:"count"`
+			Changes []models.SettingChange `json:"changes"`
+		} `json:"data"`
+	}
+
+	getSettings := func(t *testing.T, user *models.User) settingsFields {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/protected/settings", nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		assertCorrectStatus(t, 200, w.Code)
+		var resp settingsResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Data.Settings
+	}
+
+	updateSettings := func(t *testing.T, user *models.User, s settingsFields) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		j, _ := json.Marshal(s)
+		req, _ := http.NewRequest("PUT", "/api/protected/settings/update", bytes.NewBuffer(j))
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	getHistory := func(t *testing.T, user *models.User, query string) historyResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/protected/settings/history"+query, nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		assertCorrectStatus(t, 200, w.Code)
+		var resp historyResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	t.Run("Update settings and flags", func(t *testing.T) {
+		user := CreateAdminUser()
+		s := getSettings(t, user)
+		s.EnableSubscriptions = "false"
+		s.AllowedOrigins = []string{"https://eirevpn.ie"}
+		s.Flags = map[string]bool{"new-dashboard": true}
+		assertCorrectStatus(t, 200, updateSettings(t, user, s))
+
+		got := getSettings(t, user)
+		assert.Equal(t, "false", got.EnableSubscriptions)
+		assert.Equal(t, []string{"https://eirevpn.ie"}, got.AllowedOrigins)
+		assert.Equal(t, map[string]bool{"new-dashboard": true}, got.Flags)
+		CreateCleanDB()
+	})
+
+	t.Run("Records who changed each setting", func(t *testing.T) {
+		user := CreateAdminUser()
+		s := getSettings(t, user)
+		s.Flags = map[string]bool{"beta": true}
+		assertCorrectStatus(t, 200, updateSettings(t, user, s))
+		s.Flags = map[string]bool{"beta": false}
+		assertCorrectStatus(t, 200, updateSettings(t, user, s))
+
+		resp := getHistory(t, user, "?key=beta")
+		if assert.Equal(t, 2, resp.Data.Count) {
+			latest := resp.Data.Changes[0]
+			assert.Equal(t, "true", latest.Before)
+			assert.Equal(t, "false", latest.After)
+			assert.Equal(t, 2, latest.Version)
+			assert.Equal(t, user.ID, latest.ChangedBy)
+		}
+		CreateCleanDB()
+	})
+
+	t.Run("Unchanged settings are not recorded", func(t *testing.T) {
+		user := CreateAdminUser()
+		s := getSettings(t, user)
+		assertCorrectStatus(t, 200, updateSettings(t, user, s))
+		first := getHistory(t, user, "").Data.Count
+		assertCorrectStatus(t, 200, updateSettings(t, user, s))
+		assert.Equal(t, first, getHistory(t, user, "").Data.Count)
+		CreateCleanDB()
+	})
+
+	t.Run("Invalid flag name", func(t *testing.T) {
+		user := CreateAdminUser()
+		s := getSettings(t, user)
+		s.Flags = map[string]bool{"not a flag!": true}
+		assertCorrectStatus(t, 400, updateSettings(t, user, s))
+		CreateCleanDB()
+	})
+}
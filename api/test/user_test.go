@@ -3,11 +3,15 @@ package test
 import (
 	"bytes"
 	"eirevpn/api/models"
+	"eirevpn/api/util/totp"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestLoginRoute(t *testing.T) {
@@ -142,6 +146,93 @@ func TestGetUserRoute(t *testing.T) {
 	})
 }
 
+func TestUserUsageRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User, userID uint) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/api/private/user/usage/%d", userID)
+		req, _ := http.NewRequest("GET", url, nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Retrieve own usage", func(t *testing.T) {
+		user := CreateUser()
+		want := 200
+		got := makeRequest(t, user, user.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Other users usage", func(t *testing.T) {
+		user := CreateUser()
+		want := 403
+		got := makeRequest(t, user, user.ID+1)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+}
+
+func TestUserSessionsRoute(t *testing.T) {
+	t.Run("List sessions", func(t *testing.T) {
+		user := CreateUser()
+		GetTokens(user)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/private/user/sessions", nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		assertCorrectStatus(t, 200, w.Code)
+		var resp struct {
+			Data struct {
+				Sessions []struct {
+					Current bool `json:"current"`
+				} `json:"sessions"`
+			} `json:"data"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if len(resp.Data.Sessions) != 2 {
+			t.Errorf("got %d sessions want 2", len(resp.Data.Sessions))
+		}
+		CreateCleanDB()
+	})
+}
+
+func TestDeleteUserSessionRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User, sessionID uint) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/api/private/user/sessions/%d", sessionID)
+		req, _ := http.NewRequest("DELETE", url, nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Delete own session", func(t *testing.T) {
+		user := CreateUser()
+		GetTokens(user)
+		var session models.UserAppSession
+		dbInstance.Where("user_id = ?", user.ID).First(&session)
+		want := 200
+		got := makeRequest(t, user, session.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Delete other users session", func(t *testing.T) {
+		user := CreateUser()
+		other := CreateAdminUser()
+		GetTokens(other)
+		var session models.UserAppSession
+		dbInstance.Where("user_id = ?", other.ID).First(&session)
+		want := 400
+		got := makeRequest(t, user, session.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+}
+
 func TestUpdateUserRoute(t *testing.T) {
 	makeRequest := func(t *testing.T, user *models.User, userupdate map[string]interface{}, userId uint) int {
 		t.Helper()
@@ -217,3 +308,380 @@ func TestChangePasswordRoute(t *testing.T) {
 		CreateCleanDB()
 	})
 }
+
+func TestLoginTwoFactorRoute(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	login := func(t *testing.T) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		j, _ := json.Marshal(map[string]string{"email": "email@email.com", "password": "password"})
+		req, _ := http.NewRequest("POST", "/api/user/login", bytes.NewBuffer(j))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Data struct {
+				TwoFactorRequired bool   `json:"two_factor_required"`
+				TwoFactorToken    string `json:"two_factor_token"`
+			} `json:"data"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		if !resp.Data.TwoFactorRequired || len(w.Result().Cookies()) != 0 {
+			t.Errorf("Expected login to require a two factor code before issuing cookies")
+		}
+		return resp.Data.TwoFactorToken
+	}
+
+	makeRequest := func(t *testing.T, body map[string]string) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		j, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/api/user/login/2fa", bytes.NewBuffer(j))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Successful two factor login", func(t *testing.T) {
+		_ = CreateTwoFactorUser(secret)
+		code, _ := totp.Code(secret, time.Now())
+		want := 200
+		got := makeRequest(t, map[string]string{"token": login(t), "code": code})
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Code cannot be reused", func(t *testing.T) {
+		_ = CreateTwoFactorUser(secret)
+		code, _ := totp.Code(secret, time.Now())
+		_ = makeRequest(t, map[string]string{"token": login(t), "code": code})
+		want := 401
+		got := makeRequest(t, map[string]string{"token": login(t), "code": code})
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Recovery code login", func(t *testing.T) {
+		user := CreateTwoFactorUser(secret)
+		var rc models.AllRecoveryCodes
+		codes, _ := rc.Generate(user.ID)
+		want := 200
+		got := makeRequest(t, map[string]string{"token": login(t), "recovery_code": codes[0]})
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Wrong code", func(t *testing.T) {
+		_ = CreateTwoFactorUser(secret)
+		want := 401
+		got := makeRequest(t, map[string]string{"token": login(t), "code": "000000x"})
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		_ = CreateTwoFactorUser(secret)
+		code, _ := totp.Code(secret, time.Now())
+		want := 401
+		got := makeRequest(t, map[string]string{"token": "token", "code": code})
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+}
+
+func TestEnableTwoFactorRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User, url string, body map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		j, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", url, bytes.NewBuffer(j))
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Successful enable", func(t *testing.T) {
+		user := CreateUser()
+		var setup struct {
+			Data struct {
+				Secret string `json:"secret"`
+			} `json:"data"`
+		}
+		resp := makeRequest(t, user, "/api/private/user/2fa/setup", nil)
+		json.NewDecoder(resp.Body).Decode(&setup)
+		code, _ := totp.Code(setup.Data.Secret, time.Now())
+
+		var enable struct {
+			Data struct {
+				RecoveryCodes []string `json:"recovery_codes"`
+			} `json:"data"`
+		}
+		resp = makeRequest(t, user, "/api/private/user/2fa/enable", map[string]string{"code": code})
+		assertCorrectStatus(t, 200, resp.Code)
+		json.NewDecoder(resp.Body).Decode(&enable)
+		assert.Len(t, enable.Data.RecoveryCodes, 10)
+		CreateCleanDB()
+	})
+
+	t.Run("Wrong code", func(t *testing.T) {
+		user := CreateUser()
+		_ = makeRequest(t, user, "/api/private/user/2fa/setup", nil)
+		want := 401
+		got := makeRequest(t, user, "/api/private/user/2fa/enable", map[string]string{"code": "123456"})
+		assertCorrectStatus(t, want, got.Code)
+		CreateCleanDB()
+	})
+
+	t.Run("Already enabled", func(t *testing.T) {
+		user := CreateTwoFactorUser("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ")
+		want := 400
+		got := makeRequest(t, user, "/api/private/user/2fa/setup", nil)
+		assertCorrectStatus(t, want, got.Code)
+		CreateCleanDB()
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	makeRequest := func(t *testing.T, password string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		j, _ := json.Marshal(map[string]string{"email": "email@email.com", "password": password})
+		req, _ := http.NewRequest("POST", "/api/user/login", bytes.NewBuffer(j))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Repeated failures are delayed", func(t *testing.T) {
+		_ = CreateUser()
+		for i := 0; i < 3; i++ {
+			assertCorrectStatus(t, 401, makeRequest(t, "wrong").Code)
+		}
+		resp := makeRequest(t, "password")
+		apiErr := bindError(resp)
+		assertCorrectStatus(t, 429, apiErr.Status)
+		assertCorrectCode(t, "ACCOUNTLOCKED", apiErr.Code)
+		assert.NotEmpty(t, resp.Header().Get("Retry-After"))
+		CreateCleanDB()
+	})
+
+	t.Run("Locked account", func(t *testing.T) {
+		_ = CreateUser()
+		_ = CreateLockout("email@email.com")
+		want := 429
+		got := makeRequest(t, "password")
+		assertCorrectStatus(t, want, got.Code)
+		CreateCleanDB()
+	})
+}
+
+func TestLockoutsRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/protected/lockouts", nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Successful get all lockouts", func(t *testing.T) {
+		user := CreateAdminUser()
+		_ = CreateLockout("email@email.com")
+		want := 200
+		got := makeRequest(t, user)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+}
+
+func TestClearLockoutRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User, lockoutID uint) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/api/protected/lockouts/%d", lockoutID)
+		req, _ := http.NewRequest("DELETE", url, nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Successful clear lockout", func(t *testing.T) {
+		user := CreateAdminUser()
+		lockout := CreateLockout("email@email.com")
+		want := 200
+		got := makeRequest(t, user, lockout.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Lockout not found", func(t *testing.T) {
+		user := CreateAdminUser()
+		want := 400
+		got := makeRequest(t, user, 999)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+}
+
+func TestCreateAPITokenRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User, body map[string]interface{}) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		j, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/api/private/user/tokens", bytes.NewBuffer(j))
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Successful create token", func(t *testing.T) {
+		user := CreateUser()
+		want := 200
+		got := makeRequest(t, user, map[string]interface{}{"name": "ops", "scopes": []string{"read"}})
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Admin scope refused for normal users", func(t *testing.T) {
+		user := CreateUser()
+		want := 400
+		got := makeRequest(t, user, map[string]interface{}{"name": "ops", "scopes": []string{"admin"}})
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+}
+
+func TestDeleteAPITokenRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User, tokenID uint) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("/api/private/user/tokens/%d", tokenID)
+		req, _ := http.NewRequest("DELETE", url, nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Successful delete token", func(t *testing.T) {
+		user := CreateUser()
+		var token models.APIToken
+		_ = CreateAPIToken(user, "read")
+		dbInstance.Where("user_id = ?", user.ID).First(&token)
+		want := 200
+		got := makeRequest(t, user, token.ID)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Token not found", func(t *testing.T) {
+		user := CreateUser()
+		want := 400
+		got := makeRequest(t, user, 999)
+		assertCorrectStatus(t, want, got)
+		CreateCleanDB()
+	})
+}
+
+func TestPermissionsRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User) (int, []string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/private/user/permissions", nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Data struct {
+				Permissions []string `json:"permissions"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Data.Permissions
+	}
+
+	t.Run("Normal user has no permissions", func(t *testing.T) {
+		user := CreateUser()
+		code, perms := makeRequest(t, user)
+		assertCorrectStatus(t, 200, code)
+		assert.Empty(t, perms)
+		CreateCleanDB()
+	})
+
+	t.Run("Support admin permissions", func(t *testing.T) {
+		user := CreateAdminUserWithRole(models.UserRoleSupport)
+		code, perms := makeRequest(t, user)
+		assertCorrectStatus(t, 200, code)
+		assert.Contains(t, perms, "users:read")
+		assert.NotContains(t, perms, "settings:write")
+		CreateCleanDB()
+	})
+}
+
+func TestRoleRestrictedRoutes(t *testing.T) {
+	makeRequest := func(t *testing.T, user *models.User, url string) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		AddTokens(user, req)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Support admin can list users", func(t *testing.T) {
+		user := CreateAdminUserWithRole(models.UserRoleSupport)
+		got := makeRequest(t, user, "/api/protected/users")
+		assertCorrectStatus(t, 200, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Support admin cannot view settings", func(t *testing.T) {
+		user := CreateAdminUserWithRole(models.UserRoleSupport)
+		got := makeRequest(t, user, "/api/protected/settings")
+		assertCorrectStatus(t, 403, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Admin without a role can view settings", func(t *testing.T) {
+		user := CreateAdminUser()
+		got := makeRequest(t, user, "/api/protected/settings")
+		assertCorrectStatus(t, 200, got)
+		CreateCleanDB()
+	})
+}
+
+func TestUpdateRoleRoute(t *testing.T) {
+	makeRequest := func(t *testing.T, admin *models.User, userID uint, role string) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		j, _ := json.Marshal(map[string]string{"role": role})
+		url := fmt.Sprintf("/api/protected/users/role/%d", userID)
+		req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(j))
+		AddTokens(admin, req)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Successful role update", func(t *testing.T) {
+		admin := CreateAdminUser()
+		user := CreateAdminUserWithRole(models.UserRoleBilling)
+		got := makeRequest(t, admin, user.ID, "support")
+		assertCorrectStatus(t, 200, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Invalid role", func(t *testing.T) {
+		admin := CreateAdminUser()
+		user := CreateAdminUserWithRole(models.UserRoleBilling)
+		got := makeRequest(t, admin, user.ID, "owner")
+		assertCorrectStatus(t, 400, got)
+		CreateCleanDB()
+	})
+
+	t.Run("Support admin cannot assign roles", func(t *testing.T) {
+		admin := CreateAdminUserWithRole(models.UserRoleSupport)
+		user := CreateUser()
+		got := makeRequest(t, admin, user.ID, "billing")
+		assertCorrectStatus(t, 403, got)
+		CreateCleanDB()
+	})
+}
@@ -0,0 +1,77 @@
+package clientip
+
+import (
+	"eirevpn/api/config"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Get returns the IP address of the client making the request. The
+// X-Forwarded-For and X-Real-Ip headers are only believed when the request
+// comes from one of the App.TrustedProxies, as any client can set them.
+func Get(c *gin.Context) string {
+	remote := c.Request.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	trusted := parse(config.Load().App.TrustedProxies)
+	if !contains(trusted, remote) {
+		return remote
+	}
+
+	// each proxy appends the address it received the request from, so
+	// walk back from the nearest hop until one is not a trusted proxy
+	client := ""
+	hops := strings.Split(c.GetHeader("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(hops[i])
+		if net.ParseIP(ip) == nil {
+			break
+		}
+		client = ip
+		if !contains(trusted, ip) {
+			return ip
+		}
+	}
+	if client != "" {
+		return client
+	}
+	if ip := strings.TrimSpace(c.GetHeader("X-Real-Ip")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return remote
+}
+
+// parse reads the trusted proxies, a single address being treated as a
+// range of one. Config validation rejects invalid entries.
+func parse(list []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range list {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * len(ip)
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			}
+			continue
+		}
+		if _, n, err := net.ParseCIDR(entry); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
+
+func contains(nets []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
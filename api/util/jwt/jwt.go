@@ -16,6 +16,21 @@ type JWTClaims struct {
 	jwt_lib.StandardClaims
 }
 
+// TwoFactorClaims identify a user who has entered their password
+// but has not yet entered their second factor code
+type TwoFactorClaims struct {
+	UserID  uint   `json:"user_id"`
+	Purpose string `json:"purpose"`
+	jwt_lib.StandardClaims
+}
+
+// twoFactorPurpose stops any other token being accepted in place of a
+// two factor token
+const twoFactorPurpose = "2fa"
+
+// twoFactorExpiry is how long the user has to enter their code
+const twoFactorExpiry = time.Minute * 5
+
 // Tokens creates a jwt token from the user ID
 func Tokens(usersession models.UserAppSession) (string, string, string, error) {
 	conf := config.Load()
@@ -33,8 +48,9 @@ func Tokens(usersession models.UserAppSession) (string, string, string, error) {
 	csrfTokenString, err := csrfToken.SignedString([]byte(conf.App.JWTSecret))
 
 	authTokenClaims := JWTClaims{
-		UserID: usersession.UserID,
-		CSRF:   csrfTokenString,
+		UserID:            usersession.UserID,
+		CSRF:              csrfTokenString,
+		SessionIdentifier: usersession.Identifier,
 		StandardClaims: jwt_lib.StandardClaims{
 			ExpiresAt: time.Now().Add(authExpiry).Unix(),
 		},
@@ -81,6 +97,41 @@ func PasswordResetToken(id string) (string, error) {
 	return tokenString, nil
 }
 
+// TwoFactorToken creates a short lived token proving the user has
+// entered the correct password, to be exchanged along with their code
+func TwoFactorToken(userID uint) (string, error) {
+	conf := config.Load()
+	claims := TwoFactorClaims{
+		UserID:  userID,
+		Purpose: twoFactorPurpose,
+		StandardClaims: jwt_lib.StandardClaims{
+			ExpiresAt: time.Now().Add(twoFactorExpiry).Unix(),
+		},
+	}
+	token := jwt_lib.NewWithClaims(jwt_lib.GetSigningMethod("HS256"), claims)
+	return token.SignedString([]byte(conf.App.JWTSecret))
+}
+
+// ValidateTwoFactorToken returns the ID of the user the two factor token
+// was issued to
+func ValidateTwoFactorToken(tokenString string) (uint, error) {
+	conf := config.Load()
+	token, err := jwt_lib.ParseWithClaims(tokenString, &TwoFactorClaims{}, func(token *jwt_lib.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt_lib.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(conf.App.JWTSecret), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	claims, ok := token.Claims.(*TwoFactorClaims)
+	if !ok || !token.Valid || claims.Purpose != twoFactorPurpose {
+		return 0, fmt.Errorf("Token is not a two factor token")
+	}
+	return claims.UserID, nil
+}
+
 // ValidateAuthToken todo
 func ValidateToken(refreshToken string) (*JWTClaims, error) {
 	conf := config.Load()
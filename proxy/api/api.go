@@ -0,0 +1,172 @@
+package api
+
+import (
+	"crypto/subtle"
+	c "eirevpn/proxy/config"
+	"eirevpn/proxy/credentials"
+	"eirevpn/proxy/metrics"
+	"eirevpn/proxy/usage"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version of the proxy node, reported by the status endpoint
+const Version = "1.2.0"
+
+const prefix = "/v1"
+
+var started = time.Now()
+
+// Handler returns the control API used by the central API to manage the node.
+// Every control route requires the nodes APISecret as a bearer token. The
+// metrics route is checked against its own token instead so scrapers never
+// hold the secret that manages credentials.
+func Handler() http.Handler {
+	control := http.NewServeMux()
+	control.HandleFunc(prefix+"/status", status)
+	control.HandleFunc(prefix+"/credentials", credentialsRoot)
+	control.HandleFunc(prefix+"/credentials/", credential)
+	control.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, NotFound)
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", metricsRoot)
+	mux.Handle("/", authenticate(control))
+	return mux
+}
+
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := c.Load().App.APISecret
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			fmt.Printf("Unauthorised control API request from %s \n", r.RemoteAddr)
+			writeError(w, Unauthorised)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// status reports the version of the node and how many credentials it holds
+func status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, MethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": 200,
+		"data": map[string]interface{}{
+			"version":     Version,
+			"uptime":      int64(time.Since(started).Seconds()),
+			"credentials": len(credentials.All()),
+			"connections": usage.ActiveConnections(),
+		},
+	})
+}
+
+// metricsRoot serves the Prometheus metrics of the node. Scrapers must
+// send the metrics token as a bearer token, and metrics are not served at
+// all until a token is configured.
+func metricsRoot(w http.ResponseWriter, r *http.Request) {
+	conf := c.Load()
+	if !conf.Metrics.Enabled || conf.Metrics.Token == "" {
+		writeError(w, MetricsDisabled)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(conf.Metrics.Token)) != 1 {
+		fmt.Printf("Unauthorised metrics request from %s \n", r.RemoteAddr)
+		writeError(w, MetricsUnauth)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, MethodNotAllowed)
+		return
+	}
+	metrics.Handler(w, r)
+}
+
+// credentialsRoot lists the issued credentials on GET and issues
+// a new credential on POST
+func credentialsRoot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		type listedCredential struct {
+			UserID    uint   `json:"user_id"`
+			Username  string `json:"username"`
+			Suspended bool   `json:"suspended"`
+		}
+		list := make([]listedCredential, 0)
+		for _, cred := range credentials.All() {
+			list = append(list, listedCredential{cred.UserID, cred.Username, cred.Suspended})
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status": 200,
+			"data": map[string]interface{}{
+				"credentials": list,
+			},
+		})
+	case http.MethodPost:
+		cred := credentials.Credential{}
+		if err := json.NewDecoder(r.Body).Decode(&cred); err != nil {
+			writeError(w, InvalidBody)
+			return
+		}
+		if cred.UserID == 0 || cred.Username == "" || cred.Password == "" {
+			writeError(w, InvalidCred)
+			return
+		}
+		if err := credentials.Add(cred); err != nil {
+			fmt.Println("Error saving credentials: ", err)
+			writeError(w, CredSaveFailed)
+			return
+		}
+		fmt.Println("Issued credentials for user: ", cred.UserID)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": 200})
+	default:
+		writeError(w, MethodNotAllowed)
+	}
+}
+
+// credential revokes the credential issued to the user in the path on
+// DELETE, or suspends it on POST to its /suspend path
+func credential(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, prefix+"/credentials/")
+	suspend := strings.HasSuffix(path, "/suspend")
+	if (suspend && r.Method != http.MethodPost) || (!suspend && r.Method != http.MethodDelete) {
+		writeError(w, MethodNotAllowed)
+		return
+	}
+	userID, err := strconv.ParseUint(strings.TrimSuffix(path, "/suspend"), 10, 64)
+	if err != nil {
+		writeError(w, InvalidUserID)
+		return
+	}
+	if _, ok := credentials.Find(uint(userID)); !ok {
+		writeError(w, CredNotFound)
+		return
+	}
+	if suspend {
+		err = credentials.Suspend(uint(userID))
+	} else {
+		err = credentials.Revoke(uint(userID))
+	}
+	if err != nil {
+		fmt.Println("Error saving credentials: ", err)
+		writeError(w, CredSaveFailed)
+		return
+	}
+	usage.Disconnect(uint(userID))
+	if suspend {
+		fmt.Println("Suspended credentials for user: ", userID)
+	} else {
+		fmt.Println("Revoked credentials for user: ", userID)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": 200})
+}
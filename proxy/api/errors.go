@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// APIError is the JSON body returned for any failed request
+type APIError struct {
+	Status int    `json:"status"`
+	Code   string `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+var (
+	Unauthorised     = APIError{401, "UNAUTHORISED", "Unauthorised", "The API secret is missing or incorrect"}
+	NotFound         = APIError{404, "NOTFOUND", "Not Found", "No route matches the requested path"}
+	MethodNotAllowed = APIError{405, "METHODNOTALLOWED", "Method Not Allowed", "The method is not supported for the requested path"}
+	InvalidBody      = APIError{400, "INVALIDBODY", "Invalid Body", "The request body could not be decoded"}
+	InvalidCred      = APIError{400, "INVALIDCRED", "Invalid Credential", "A user_id, username and password are required"}
+	InvalidUserID    = APIError{400, "INVALIDUSERID", "Invalid User ID", "The user id in the path is not valid"}
+	CredNotFound     = APIError{404, "CREDNOTFOUND", "Credential Not Found", "No credential has been issued to the user"}
+	CredSaveFailed   = APIError{500, "CREDSAVEFAILED", "Credential Save Failed", "Failed to save the credential table"}
+	MetricsDisabled  = APIError{404, "METRICSDISABLED", "Metrics Disabled", "Metrics are not enabled or have no token configured on this node"}
+	MetricsUnauth    = APIError{401, "METRICSUNAUTHORISED", "Metrics Unauthorised", "The metrics token is missing or incorrect"}
+)
+
+func writeError(w http.ResponseWriter, apiErr APIError) {
+	writeJSON(w, apiErr.Status, apiErr)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
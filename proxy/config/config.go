@@ -3,17 +3,29 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"reflect"
 
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
 	App struct {
-		ProxyPort     string `yaml:"ProxyPort"`
-		RestPort      string `yaml:"RestPort"`
-		ProxyUsername string `yaml:"ProxyUsername"`
-		ProxyPassword string `yaml:"ProxyPassword"`
+		ProxyPort           string `yaml:"ProxyPort"`
+		SocksPort           string `yaml:"SocksPort"`
+		RestPort            string `yaml:"RestPort"`
+		CredentialsFile     string `yaml:"CredentialsFile"`
+		APISecret           string `yaml:"APISecret"`
+		ServerID            uint   `yaml:"ServerID"`
+		APIURL              string `yaml:"APIURL"`
+		UsageReportInterval int    `yaml:"UsageReportInterval"`
+		ShutdownTimeout     int    `yaml:"ShutdownTimeout"`
 	} `yaml:"App"`
+
+	Metrics struct {
+		Enabled bool   `yaml:"Enabled"`
+		Token   string `yaml:"Token"`
+	} `yaml:"Metrics"`
 }
 
 var configFilename string
@@ -22,7 +34,22 @@ func Init(filename string) {
 	configFilename = filename
 }
 
+// Filename returns the path of the config file being used
+func Filename() string {
+	return configFilename
+}
+
+// Load reads the config file then applies any environment overrides
 func Load() Config {
+	conf := readFile()
+	if _, err := applyEnv(&conf); err != nil {
+		fmt.Println(err)
+	}
+	return conf
+}
+
+// readFile reads the config file without environment overrides
+func readFile() Config {
 	conf := Config{}
 	yamlFile, err := ioutil.ReadFile(configFilename)
 	if err != nil {
@@ -35,12 +62,31 @@ func Load() Config {
 	return conf
 }
 
+// SaveConfig writes the config back to the file it was loaded from. Fields
+// set by environment variables keep the value already in the file so
+// secrets provided that way are never written to disk.
 func (c *Config) SaveConfig() error {
-	newConf, err := yaml.Marshal(&c)
+	onDisk := readFile()
+	overridden, err := applyEnv(&Config{})
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile("config.yaml", newConf, 0644)
+	save := *c
+	dst := reflect.ValueOf(&save).Elem()
+	src := reflect.ValueOf(&onDisk).Elem()
+	for _, index := range overridden {
+		dst.FieldByIndex(index).Set(src.FieldByIndex(index))
+	}
+
+	newConf, err := yaml.Marshal(&save)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(configFilename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	err = ioutil.WriteFile(configFilename, newConf, mode)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,153 @@
+package credentials
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Credential is a proxy username and password issued by the
+// API to a single user. A suspended credential is kept but no
+// longer accepted, for example once the user runs out of data.
+// MaxDevices limits how many devices may use it at once.
+type Credential struct {
+	UserID     uint   `yaml:"UserID" json:"user_id"`
+	Username   string `yaml:"Username" json:"username"`
+	Password   string `yaml:"Password" json:"password"`
+	Suspended  bool   `yaml:"Suspended" json:"suspended"`
+	MaxDevices int    `yaml:"MaxDevices" json:"max_devices"`
+}
+
+var (
+	mu                  sync.RWMutex
+	table               = map[uint]Credential{}
+	credentialsFilename string
+)
+
+// Init loads any previously issued credentials from the given file
+func Init(filename string) {
+	credentialsFilename = filename
+	yamlFile, err := ioutil.ReadFile(credentialsFilename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Println(err)
+		}
+		return
+	}
+	var creds []Credential
+	if err := yaml.Unmarshal(yamlFile, &creds); err != nil {
+		fmt.Println(err)
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, cred := range creds {
+		table[cred.UserID] = cred
+	}
+}
+
+// Add stores the credential for the user, replacing any
+// credential previously issued to them
+func Add(cred Credential) error {
+	mu.Lock()
+	defer mu.Unlock()
+	table[cred.UserID] = cred
+	return save()
+}
+
+// Revoke removes the credential issued to the user
+func Revoke(userID uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(table, userID)
+	return save()
+}
+
+// Suspend stops the credential issued to the user from being accepted
+// until it is issued again
+func Suspend(userID uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+	cred := table[userID]
+	cred.Suspended = true
+	table[userID] = cred
+	return save()
+}
+
+// Find returns the credential issued to the user
+func Find(userID uint) (Credential, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	cred, ok := table[userID]
+	return cred, ok
+}
+
+// Authenticate returns the ID of the user the username and password
+// were issued to, and false if they do not match any active credential
+func Authenticate(username, password string) (uint, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, cred := range table {
+		userMatch := subtle.ConstantTimeCompare([]byte(cred.Username), []byte(username)) == 1
+		passMatch := subtle.ConstantTimeCompare([]byte(cred.Password), []byte(password)) == 1
+		if userMatch && passMatch && !cred.Suspended {
+			return cred.UserID, true
+		}
+	}
+	return 0, false
+}
+
+// UserID returns the ID of the user the username was issued to
+func UserID(username string) (uint, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, cred := range table {
+		if cred.Username == username {
+			return cred.UserID, true
+		}
+	}
+	return 0, false
+}
+
+// Store checks logins against the credential table. It satisfies the
+// socks5.CredentialStore interface so the SOCKS listener can share it.
+type Store struct{}
+
+// Valid reports whether the username and password were issued to a user
+func (Store) Valid(username, password string) bool {
+	userID, ok := Authenticate(username, password)
+	if ok {
+		fmt.Printf("Authenticated user %d, allowing SOCKS connection.\n", userID)
+	} else {
+		fmt.Printf("Wrong SOCKS Credentials for username: %s \n", username)
+	}
+	return ok
+}
+
+// All returns every credential currently held by the proxy
+func All() []Credential {
+	mu.RLock()
+	defer mu.RUnlock()
+	creds := make([]Credential, 0, len(table))
+	for _, cred := range table {
+		creds = append(creds, cred)
+	}
+	return creds
+}
+
+// save writes the credential table to disk. The caller must hold mu.
+func save() error {
+	creds := make([]Credential, 0, len(table))
+	for _, cred := range table {
+		creds = append(creds, cred)
+	}
+	out, err := yaml.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(credentialsFilename, out, 0600)
+}
@@ -1,64 +1,259 @@
 package main
 
 import (
+	"context"
+	"eirevpn/proxy/api"
 	c "eirevpn/proxy/config"
-	"encoding/json"
+	"eirevpn/proxy/credentials"
+	"eirevpn/proxy/metrics"
+	"eirevpn/proxy/usage"
+	"encoding/base64"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/armon/go-socks5"
 	"github.com/elazarl/goproxy"
 	"github.com/elazarl/goproxy/ext/auth"
 )
 
-type credentials struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
+// defaultShutdownTimeout is the number of seconds open connections are
+// given to finish once a shutdown signal is received
+const defaultShutdownTimeout = 30
+
+// shuttingDown is set once a shutdown signal is received so the servers
+// closing is not treated as a failure
+var shuttingDown int32
 
 func main() {
 	appPath, _ := os.Getwd()
-	filename, _ := filepath.Abs(appPath + "/config.yaml")
+	defaultConfig := os.Getenv(c.EnvPrefix + "_CONFIG")
+	if defaultConfig == "" {
+		defaultConfig = appPath + "/config.yaml"
+	}
+	configPath := flag.String("config", defaultConfig, "path to the config file")
+	flag.Parse()
+	filename, _ := filepath.Abs(*configPath)
 	c.Init(filename)
-	go startProxy()
-	startAPI()
+	// a relative credentials file is kept alongside the config file
+	credsFilename := c.Load().App.CredentialsFile
+	if !filepath.IsAbs(credsFilename) {
+		credsFilename = filepath.Join(filepath.Dir(filename), credsFilename)
+	}
+	credentials.Init(credsFilename)
+	metrics.SetActiveConnections(func() float64 {
+		return float64(usage.ActiveConnections())
+	})
+	proxyServer := startProxy()
+	socksListener := startSocks()
+	stopReporting := make(chan struct{})
+	reportingDone := make(chan struct{})
+	go func() {
+		usage.StartReporting(stopReporting)
+		close(reportingDone)
+	}()
+	apiServer := startAPI()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	fmt.Printf("Received %s, shutting down\n", sig)
+	atomic.StoreInt32(&shuttingDown, 1)
+
+	timeout := c.Load().App.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	// stop accepting connections then wait for open requests and tunnels
+	// to finish, closing any left once the timeout is reached
+	if socksListener != nil {
+		socksListener.Close()
+	}
+	if err := proxyServer.Shutdown(ctx); err != nil {
+		fmt.Println("Error shutting down proxy: ", err)
+	}
+	usage.Drain(ctx)
+
+	// report the usage of the drained connections before the control
+	// API goes away
+	close(stopReporting)
+	select {
+	case <-reportingDone:
+	case <-ctx.Done():
+	}
+	if err := apiServer.Shutdown(ctx); err != nil {
+		fmt.Println("Error shutting down REST API: ", err)
+	}
+	fmt.Println("Shutdown complete")
 }
 
-func startAPI() {
-	http.HandleFunc("/update_creds", func(w http.ResponseWriter, r *http.Request) {
-		d := json.NewDecoder(r.Body)
-		cred := &credentials{}
-		err := d.Decode(cred)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		config := c.Load()
-		config.App.ProxyUsername = cred.Username
-		config.App.ProxyPassword = cred.Password
-		if err = config.SaveConfig(); err != nil {
-			fmt.Println("Error saving config: ", err)
-		}
-		fmt.Println("Updated Configuration: ", config)
-	})
+// serve runs fn and exits the process if it fails before shutdown
+func serve(name string, fn func() error) {
+	err := fn()
+	if atomic.LoadInt32(&shuttingDown) == 1 {
+		return
+	}
+	log.Fatalf("%s stopped: %v", name, err)
+}
+
+func startAPI() *http.Server {
 	config := c.Load()
+	if config.App.APISecret == "" {
+		log.Fatal("APISecret must be set in the config to start the REST API")
+	}
+	server := &http.Server{Addr: ":" + config.App.RestPort, Handler: api.Handler()}
 	fmt.Println("REST API Started")
-	http.ListenAndServe(":"+config.App.RestPort, nil)
+	go serve("REST API", server.ListenAndServe)
+	return server
 }
 
-func startProxy() {
+func startProxy() *http.Server {
 	proxy := goproxy.NewProxyHttpServer()
 	proxy.Verbose = true
-	auth.ProxyBasic(proxy, "Auth", func(user, passwd string) bool {
-		if user == c.Load().App.ProxyUsername && passwd == c.Load().App.ProxyPassword {
-			fmt.Println("Authenticated, allowing connection.")
-			return true
+	proxy.ConnectDial = metrics.Dial("connect", connectDial(proxy))
+	proxy.OnRequest().DoFunc(func(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
+		if !authenticate(req, "http") {
+			return nil, auth.BasicUnauthorized(req, "Auth")
 		}
-		fmt.Printf("Wrong Credentials: %s:%s \n", user, passwd)
-		return false
+		ctx.RoundTripper = goproxy.RoundTripperFunc(func(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Response, error) {
+			resp, err := proxy.Tr.RoundTrip(req)
+			if err != nil {
+				metrics.UpstreamError("http")
+			}
+			return resp, err
+		})
+		return req, nil
 	})
+	proxy.OnRequest().HandleConnectFunc(func(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+		if !authenticate(ctx.Req, "connect") {
+			ctx.Resp = auth.BasicUnauthorized(ctx.Req, "Auth")
+			return goproxy.RejectConnect, host
+		}
+		return goproxy.OkConnect, host
+	})
+	listener, err := net.Listen("tcp", ":"+c.Load().App.ProxyPort)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := &http.Server{Handler: proxy, ConnContext: usage.ConnContext}
 	fmt.Println("Proxy Started")
-	log.Fatal(http.ListenAndServe(":"+c.Load().App.ProxyPort, proxy))
+	go serve("Proxy", func() error {
+		return server.Serve(usage.Listener{Listener: listener})
+	})
+	return server
+}
+
+// connectDial returns the dialer goproxy would use for CONNECT requests
+func connectDial(proxy *goproxy.ProxyHttpServer) func(network, addr string) (net.Conn, error) {
+	if proxy.ConnectDial != nil {
+		return proxy.ConnectDial
+	}
+	if proxy.Tr.Dial != nil {
+		return proxy.Tr.Dial
+	}
+	return net.Dial
+}
+
+// authenticate checks the Proxy-Authorization header of the request against
+// the credential table and attributes the clients traffic to the user,
+// refusing devices beyond the limit of the users credential. Failures are
+// counted against the protocol.
+func authenticate(req *http.Request, protocol string) bool {
+	header := strings.SplitN(req.Header.Get("Proxy-Authorization"), " ", 2)
+	req.Header.Del("Proxy-Authorization")
+	if len(header) != 2 || header[0] != "Basic" {
+		metrics.AuthFailure(protocol, "missing_credentials")
+		return false
+	}
+	userpassraw, err := base64.StdEncoding.DecodeString(header[1])
+	if err != nil {
+		metrics.AuthFailure(protocol, "malformed_credentials")
+		return false
+	}
+	userpass := strings.SplitN(string(userpassraw), ":", 2)
+	if len(userpass) != 2 {
+		metrics.AuthFailure(protocol, "malformed_credentials")
+		return false
+	}
+	userID, ok := credentials.Authenticate(userpass[0], userpass[1])
+	if !ok {
+		fmt.Printf("Wrong Credentials for username: %s \n", userpass[0])
+		metrics.AuthFailure(protocol, "invalid_credentials")
+		return false
+	}
+	cred, _ := credentials.Find(userID)
+	if !usage.Admit(req.Context(), userID, cred.MaxDevices) {
+		fmt.Printf("Device limit reached for user %d, refusing connection.\n", userID)
+		metrics.AuthFailure(protocol, "device_limit")
+		return false
+	}
+	fmt.Printf("Authenticated user %d, allowing connection.\n", userID)
+	return true
+}
+
+// socksAuthenticator checks SOCKS logins against the credential table
+// and attributes the clients traffic to the user
+type socksAuthenticator struct {
+	socks5.UserPassAuthenticator
+}
+
+func (a socksAuthenticator) Authenticate(reader io.Reader, writer io.Writer) (*socks5.AuthContext, error) {
+	authCtx, err := a.UserPassAuthenticator.Authenticate(reader, writer)
+	if err != nil {
+		metrics.AuthFailure("socks", "invalid_credentials")
+		return nil, err
+	}
+	if conn, ok := writer.(*usage.Conn); ok {
+		if userID, ok := credentials.UserID(authCtx.Payload["Username"]); ok {
+			cred, _ := credentials.Find(userID)
+			if !conn.Admit(userID, cred.MaxDevices) {
+				fmt.Printf("Device limit reached for user %d, refusing SOCKS connection.\n", userID)
+				metrics.AuthFailure("socks", "device_limit")
+				return nil, fmt.Errorf("device limit of %d reached", cred.MaxDevices)
+			}
+		}
+	}
+	return authCtx, nil
+}
+
+// startSocks starts the SOCKS5 proxy, returning its listener so it can
+// be closed on shutdown, or nil when it is disabled
+func startSocks() net.Listener {
+	port := c.Load().App.SocksPort
+	if port == "" {
+		fmt.Println("SocksPort not set, SOCKS5 proxy disabled")
+		return nil
+	}
+	dialer := &net.Dialer{}
+	server, err := socks5.New(&socks5.Config{
+		AuthMethods: []socks5.Authenticator{
+			socksAuthenticator{socks5.UserPassAuthenticator{Credentials: credentials.Store{}}},
+		},
+		Dial: metrics.DialContext("socks", dialer.DialContext),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("SOCKS5 Proxy Started")
+	go serve("SOCKS5 Proxy", func() error {
+		return server.Serve(usage.Listener{Listener: listener})
+	})
+	return listener
 }
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	bytesTransferred = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eirevpn_proxy_bytes_transferred_total",
+		Help: "Bytes passed over user connections, by direction.",
+	}, []string{"direction"})
+	authFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eirevpn_proxy_auth_failures_total",
+		Help: "Connections refused during authentication, by protocol and reason.",
+	}, []string{"protocol", "reason"})
+	upstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "eirevpn_proxy_upstream_errors_total",
+		Help: "Failed requests or dials to upstream hosts, by protocol.",
+	}, []string{"protocol"})
+
+	// activeConnections holds the func() float64 which counts the open
+	// connections attributed to a user, set by main
+	activeConnections atomic.Value
+	_                 = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "eirevpn_proxy_active_connections",
+		Help: "Open connections attributed to a user.",
+	}, func() float64 {
+		if fn, ok := activeConnections.Load().(func() float64); ok {
+			return fn()
+		}
+		return 0
+	})
+)
+
+// SetActiveConnections sets the function which counts the open
+// connections attributed to a user
+func SetActiveConnections(fn func() float64) {
+	activeConnections.Store(fn)
+}
+
+// Bytes counts bytes passed over a connection. Direction is up for bytes
+// sent by the client and down for bytes sent to it.
+func Bytes(direction string, n uint64) {
+	bytesTransferred.WithLabelValues(direction).Add(float64(n))
+}
+
+// AuthFailure counts a connection refused during authentication
+func AuthFailure(protocol, reason string) {
+	authFailures.WithLabelValues(protocol, reason).Inc()
+}
+
+// UpstreamError counts a failed request or dial to an upstream host
+func UpstreamError(protocol string) {
+	upstreamErrors.WithLabelValues(protocol).Inc()
+}
+
+// Dial wraps dial so every failed dial is counted as an upstream error
+func Dial(protocol string, dial func(network, addr string) (net.Conn, error)) func(network, addr string) (net.Conn, error) {
+	return func(network, addr string) (net.Conn, error) {
+		conn, err := dial(network, addr)
+		if err != nil {
+			UpstreamError(protocol)
+		}
+		return conn, err
+	}
+}
+
+// DialContext wraps dial as Dial does for dialers taking a context
+func DialContext(protocol string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			UpstreamError(protocol)
+		}
+		return conn, err
+	}
+}
+
+// exposition serves the registered metrics
+var exposition = promhttp.Handler()
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler(w http.ResponseWriter, r *http.Request) {
+	exposition.ServeHTTP(w, r)
+}
@@ -0,0 +1,223 @@
+package usage
+
+import (
+	"context"
+	"eirevpn/proxy/metrics"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type contextKey struct{}
+
+// ConnKey is the request context key holding the client connection.
+// Set it with ConnContext on the http.Server so handlers can
+// attribute the connection to the user once authenticated.
+var ConnKey = contextKey{}
+
+// Conn counts the bytes passed over a client connection and adds them
+// to the usage of the user it has been assigned to
+type Conn struct {
+	net.Conn
+	userID uint64
+	closed int32
+}
+
+var (
+	liveMu sync.Mutex
+	live   = map[uint]map[*Conn]struct{}{}
+	// open holds every accepted connection which has not been closed,
+	// whether or not it has been attributed to a user yet
+	open = map[*Conn]struct{}{}
+)
+
+// SetUser attributes any further traffic on the connection to the user
+func (c *Conn) SetUser(userID uint) {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	c.setUser(userID)
+}
+
+// Admit attributes the connection to the user unless it would give them
+// more than maxDevices devices. Devices are counted as the distinct client
+// IPs the user has open connections from, so a maxDevices of 0 means the
+// user has no limit.
+func (c *Conn) Admit(userID uint, maxDevices int) bool {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	if maxDevices > 0 {
+		ip := remoteIP(c)
+		devices := map[string]struct{}{}
+		for conn := range live[userID] {
+			if conn != c {
+				devices[remoteIP(conn)] = struct{}{}
+			}
+		}
+		if _, ok := devices[ip]; !ok && len(devices) >= maxDevices {
+			return false
+		}
+	}
+	c.setUser(userID)
+	return true
+}
+
+// setUser tracks the connection against the user. The caller must hold liveMu.
+func (c *Conn) setUser(userID uint) {
+	if prev := c.user(); prev != 0 {
+		delete(live[prev], c)
+	}
+	if live[userID] == nil {
+		live[userID] = map[*Conn]struct{}{}
+	}
+	live[userID][c] = struct{}{}
+	atomic.StoreUint64(&c.userID, uint64(userID))
+}
+
+// Close closes the connection and stops tracking it
+func (c *Conn) Close() error {
+	c.untrack()
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		liveMu.Lock()
+		delete(open, c)
+		liveMu.Unlock()
+	}
+	return c.Conn.Close()
+}
+
+// untrack stops counting the connection as open for its user
+func (c *Conn) untrack() {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	if userID := c.user(); userID != 0 {
+		delete(live[userID], c)
+		if len(live[userID]) == 0 {
+			delete(live, userID)
+		}
+	}
+}
+
+// OpenConnections returns the number of accepted connections which
+// have not been closed
+func OpenConnections() int {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	return len(open)
+}
+
+// Drain waits for every open connection to be closed by its client or
+// the upstream host. Connections still open once ctx is done are closed.
+func Drain(ctx context.Context) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for OpenConnections() > 0 {
+		select {
+		case <-ctx.Done():
+			closeAll()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func closeAll() {
+	liveMu.Lock()
+	conns := make([]*Conn, 0, len(open))
+	for conn := range open {
+		conns = append(conns, conn)
+	}
+	liveMu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// Disconnect closes every open connection attributed to the user
+func Disconnect(userID uint) {
+	liveMu.Lock()
+	conns := make([]*Conn, 0, len(live[userID]))
+	for conn := range live[userID] {
+		conns = append(conns, conn)
+	}
+	liveMu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+func (c *Conn) user() uint {
+	return uint(atomic.LoadUint64(&c.userID))
+}
+
+// Read counts bytes sent by the client as upstream traffic. A failed read
+// does not stop the connection being tracked, since net/http interrupts
+// reads with a deadline when a CONNECT tunnel is hijacked or a keep-alive
+// connection goes idle. Only Close does.
+func (c *Conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if userID := c.user(); userID != 0 && n > 0 {
+		Add(userID, uint64(n), 0)
+		countBytes(uint64(n))
+		metrics.Bytes("up", uint64(n))
+	}
+	return n, err
+}
+
+// Write counts bytes sent to the client as downstream traffic
+func (c *Conn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if userID := c.user(); userID != 0 && n > 0 {
+		Add(userID, 0, uint64(n))
+		countBytes(uint64(n))
+		metrics.Bytes("down", uint64(n))
+	}
+	return n, err
+}
+
+// Listener wraps every accepted connection in a metered Conn
+type Listener struct {
+	net.Listener
+}
+
+// Accept waits for the next connection and wraps it
+func (l Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{Conn: conn}
+	liveMu.Lock()
+	open[c] = struct{}{}
+	liveMu.Unlock()
+	return c, nil
+}
+
+// ConnContext stores the client connection in the request context
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, ConnKey, conn)
+}
+
+// SetUser attributes the traffic on the connection of the request
+// context to the user. It does nothing for unmetered connections.
+func SetUser(ctx context.Context, userID uint) {
+	if conn, ok := ctx.Value(ConnKey).(*Conn); ok {
+		conn.SetUser(userID)
+	}
+}
+
+// Admit admits the connection of the request context for the user as
+// Conn.Admit does. Unmetered connections are always admitted.
+func Admit(ctx context.Context, userID uint, maxDevices int) bool {
+	if conn, ok := ctx.Value(ConnKey).(*Conn); ok {
+		return conn.Admit(userID, maxDevices)
+	}
+	return true
+}
+
+func remoteIP(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
@@ -0,0 +1,164 @@
+package usage
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elazarl/goproxy"
+)
+
+// tracked returns how many open connections are attributed to the user
+func tracked(userID uint) int {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	return len(live[userID])
+}
+
+// startEcho starts a TCP server which writes back whatever it reads
+func startEcho(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return l
+}
+
+// startProxy starts a metered goproxy which attributes every request and
+// CONNECT tunnel to the user
+func startProxy(t *testing.T, userID uint) (net.Listener, *http.Server) {
+	proxy := goproxy.NewProxyHttpServer()
+	proxy.OnRequest().DoFunc(func(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
+		SetUser(req.Context(), userID)
+		return req, nil
+	})
+	proxy.OnRequest().HandleConnectFunc(func(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+		SetUser(ctx.Req.Context(), userID)
+		return goproxy.OkConnect, host
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: proxy, ConnContext: ConnContext}
+	go server.Serve(Listener{Listener: l})
+	return l, server
+}
+
+func TestConnectTunnelTracking(t *testing.T) {
+	const userID = 4242
+	echo := startEcho(t)
+	defer echo.Close()
+	proxyListener, server := startProxy(t, userID)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", proxyListener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	target := echo.Addr().String()
+	if _, err := io.WriteString(conn, "CONNECT "+target+" HTTP/1.1\r\nHost: "+target+"\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("CONNECT returned %d, want 200", resp.StatusCode)
+	}
+
+	t.Run("Tunnel is tracked once hijacked", func(t *testing.T) {
+		if _, err := io.WriteString(conn, "ping"); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != "ping" {
+			t.Fatalf("tunnel echoed %q, want %q", buf, "ping")
+		}
+		if n := tracked(userID); n != 1 {
+			t.Fatalf("user has %d tracked connections, want 1", n)
+		}
+	})
+
+	t.Run("Disconnect closes the tunnel", func(t *testing.T) {
+		Disconnect(userID)
+		if _, err := reader.ReadByte(); err == nil {
+			t.Fatal("tunnel is still open after Disconnect")
+		} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			t.Fatal("tunnel was not closed by Disconnect")
+		}
+		if n := tracked(userID); n != 0 {
+			t.Fatalf("user has %d tracked connections after Disconnect, want 0", n)
+		}
+	})
+}
+
+func TestKeepAliveTracking(t *testing.T) {
+	const userID = 4243
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer upstream.Close()
+	proxyListener, server := startProxy(t, userID)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", proxyListener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := io.WriteString(conn, "GET "+upstream.URL+"/ HTTP/1.1\r\nHost: "+upstream.Listener.Addr().String()+"\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	t.Run("Idle connection is tracked after the request", func(t *testing.T) {
+		// give the server time to finish the request and wait for the next
+		time.Sleep(100 * time.Millisecond)
+		if n := tracked(userID); n != 1 {
+			t.Fatalf("user has %d tracked connections, want 1", n)
+		}
+	})
+
+	t.Run("Disconnect closes the idle connection", func(t *testing.T) {
+		Disconnect(userID)
+		if _, err := reader.ReadByte(); err == nil {
+			t.Fatal("connection is still open after Disconnect")
+		} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			t.Fatal("connection was not closed by Disconnect")
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package usage
+
+import (
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Load describes how busy the node is. Users is the number of users with
+// a connection open, each of which may have many.
+type Load struct {
+	Connections int     `json:"connections"`
+	Users       int     `json:"users"`
+	Throughput  uint64  `json:"throughput"`
+	CPULoad     float64 `json:"cpu_load"`
+}
+
+var (
+	totalBytes uint64
+
+	sampleMu    sync.Mutex
+	sampleBytes uint64
+	sampleTime  = time.Now()
+)
+
+// countBytes adds to the total bytes passed through the node
+func countBytes(n uint64) {
+	atomic.AddUint64(&totalBytes, n)
+}
+
+// ActiveConnections returns the number of open connections
+// which have been attributed to a user
+func ActiveConnections() int {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	count := 0
+	for _, conns := range live {
+		count += len(conns)
+	}
+	return count
+}
+
+// ActiveUsers returns the number of users with an open connection
+func ActiveUsers() int {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	return len(live)
+}
+
+// Sample returns the current load of the node. Throughput is the
+// average bytes per second since the previous sample was taken.
+func Sample() Load {
+	sampleMu.Lock()
+	defer sampleMu.Unlock()
+	now := time.Now()
+	total := atomic.LoadUint64(&totalBytes)
+	var throughput uint64
+	if elapsed := now.Sub(sampleTime).Seconds(); elapsed > 0 {
+		throughput = uint64(float64(total-sampleBytes) / elapsed)
+	}
+	sampleBytes = total
+	sampleTime = now
+	return Load{
+		Connections: ActiveConnections(),
+		Users:       ActiveUsers(),
+		Throughput:  throughput,
+		CPULoad:     cpuLoad(),
+	}
+}
+
+// cpuLoad returns the one minute load average divided by the number
+// of CPUs, so 1 means every CPU is fully busy. It is 0 where
+// /proc/loadavg is not available.
+func cpuLoad() float64 {
+	b, err := ioutil.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return 0
+	}
+	avg, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	return avg / float64(runtime.NumCPU())
+}
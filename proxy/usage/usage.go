@@ -0,0 +1,118 @@
+package usage
+
+import (
+	"bytes"
+	c "eirevpn/proxy/config"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Record is the traffic of a single user since the last report
+type Record struct {
+	UserID    uint   `json:"user_id"`
+	BytesUp   uint64 `json:"bytes_up"`
+	BytesDown uint64 `json:"bytes_down"`
+}
+
+var (
+	mu       sync.Mutex
+	counters = map[uint]*Record{}
+	client   = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Add increases the upstream and downstream byte counts of the user
+func Add(userID uint, up, down uint64) {
+	mu.Lock()
+	defer mu.Unlock()
+	rec, ok := counters[userID]
+	if !ok {
+		rec = &Record{UserID: userID}
+		counters[userID] = rec
+	}
+	rec.BytesUp += up
+	rec.BytesDown += down
+}
+
+// Flush returns the usage of every user since the last flush and
+// resets the counters
+func Flush() []Record {
+	mu.Lock()
+	defer mu.Unlock()
+	records := make([]Record, 0, len(counters))
+	for _, rec := range counters {
+		records = append(records, *rec)
+	}
+	counters = map[uint]*Record{}
+	return records
+}
+
+// restore adds records that failed to be reported back onto the counters
+func restore(records []Record) {
+	for _, rec := range records {
+		Add(rec.UserID, rec.BytesUp, rec.BytesDown)
+	}
+}
+
+// StartReporting flushes the usage counters to the API along with the
+// current load of the node on the interval set in the config. It blocks
+// until stop is closed, when any remaining usage is reported, so should
+// be run in a goroutine.
+func StartReporting(stop <-chan struct{}) {
+	config := c.Load()
+	if config.App.APIURL == "" || config.App.UsageReportInterval <= 0 {
+		fmt.Println("APIURL or UsageReportInterval not set, usage reporting disabled")
+		return
+	}
+	fmt.Println("Usage Reporting Started")
+	ticker := time.NewTicker(time.Duration(config.App.UsageReportInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			flush()
+		case <-stop:
+			flush()
+			return
+		}
+	}
+}
+
+// flush reports the usage counted since the last report, keeping it to
+// try again next time if the API cannot be reached
+func flush() {
+	records := Flush()
+	if err := report(records, Sample()); err != nil {
+		fmt.Println("Error reporting usage: ", err)
+		restore(records)
+	}
+}
+
+func report(records []Record, load Load) error {
+	config := c.Load()
+	body, err := json.Marshal(map[string]interface{}{
+		"server_id": config.App.ServerID,
+		"usage":     records,
+		"load":      load,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", config.App.APIURL+"/api/servers/usage", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+config.App.APISecret)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+import React, { useState } from 'react';
+import Card from 'react-bootstrap/Card';
+import Form from 'react-bootstrap/Form';
+import Table from 'react-bootstrap/Table';
+import dayjs from 'dayjs';
+import useAsync from '../hooks/useAsync';
+import API from '../service/APIService';
+import APIToken from '../interfaces/apitoken';
+import ButtonMain from './ButtonMain';
+import FormInput from './FormInput';
+import FormDropdown from './FormDropdown';
+import ErrorMessage from './ErrorMessage';
+
+const scopeOptions: { [key: string]: string[] } = {
+  read: ['read'],
+  'read and write': ['write'],
+  admin: ['write', 'admin']
+};
+
+const APITokensCard: React.FC = () => {
+  const [changed, setChanged] = useState(0);
+  const { data, loading } = useAsync(() => API.GetAPITokens(), [changed]);
+  const [name, setName] = useState('');
+  const [scope, setScope] = useState('read');
+  const [expiresInDays, setExpiresInDays] = useState('');
+  const [newToken, setNewToken] = useState('');
+  const [error, setError] = useState();
+
+  if (loading || data === undefined) {
+    return <div></div>;
+  }
+
+  const handleCreate = async () => {
+    const res = await API.CreateAPIToken(
+      JSON.stringify({
+        name,
+        scopes: scopeOptions[scope],
+        expires_in_days: parseInt(expiresInDays) || 0
+      })
+    );
+    if (res.status == 200) {
+      setError(undefined);
+      setName('');
+      setNewToken(res.data.token);
+      setChanged(changed + 1);
+    } else {
+      setError(res);
+    }
+  };
+
+  const handleRevoke = async (id: string) => {
+    await API.DeleteAPIToken(id);
+    setChanged(changed + 1);
+  };
+
+  const formatDate = (date: string | null) =>
+    date
+      ? dayjs(date)
+          .format('DD-MM-YYYY H:mm')
+          .toString()
+      : 'Never';
+
+  const tokens: APIToken[] = data.tokens;
+  return (
+    <Card className="dash-card">
+      <Card.Body>
+        <Card.Title>API Tokens</Card.Title>
+        <hr></hr>
+        <ErrorMessage show={!!error} error={error} />
+        {newToken && (
+          <div className="sub-card">
+            <p>Copy this token now, it will not be shown again.</p>
+            <pre>{newToken}</pre>
+          </div>
+        )}
+        <Table responsive size="sm">
+          <thead>
+            <tr>
+              <th>Name</th>
+              <th>Token</th>
+              <th>Scopes</th>
+              <th>Last Used</th>
+              <th>Expires</th>
+              <th></th>
+            </tr>
+          </thead>
+          <tbody>
+            {tokens.map(t => (
+              <tr key={t.id}>
+                <td>{t.name}</td>
+                <td>{t.prefix}…</td>
+                <td>{t.scopes}</td>
+                <td>{formatDate(t.last_used)}</td>
+                <td>{formatDate(t.expires_at)}</td>
+                <td>
+                  <ButtonMain value="Revoke" onClick={() => handleRevoke(t.id)} />
+                </td>
+              </tr>
+            ))}
+          </tbody>
+        </Table>
+        <Form>
+          <Form.Row>
+            <FormInput name="token_name" label="Name" value={name} onChange={setName} />
+            <FormDropdown
+              name="token_scope"
+              label="Access"
+              value={scope}
+              options={Object.keys(scopeOptions)}
+              onChange={setScope}
+            />
+            <FormInput
+              name="token_expires"
+              type="number"
+              label="Expires In Days"
+              value={expiresInDays}
+              onChange={setExpiresInDays}
+            />
+          </Form.Row>
+          <ButtonMain value="Create Token" onClick={handleCreate} />
+        </Form>
+      </Card.Body>
+    </Card>
+  );
+};
+
+export default APITokensCard;
@@ -13,6 +13,13 @@ const ErrorMessage: React.FC<EMProps> = ({ error, show }): JSX.Element => {
   return (
     <Alert className="center" variant="danger">
       {error?.detail}
+      {error?.request_id && <div className="request-id">Reference: {error.request_id}</div>}
+      <style jsx>{`
+        .request-id {
+          font-size: 0.75rem;
+          opacity: 0.7;
+        }
+      `}</style>
     </Alert>
   );
 };
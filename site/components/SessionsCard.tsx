@@ -0,0 +1,68 @@
+import React, { useState } from 'react';
+import Card from 'react-bootstrap/Card';
+import Table from 'react-bootstrap/Table';
+import Badge from 'react-bootstrap/Badge';
+import dayjs from 'dayjs';
+import useAsync from '../hooks/useAsync';
+import API from '../service/APIService';
+import Session from '../interfaces/session';
+import ButtonMain from './ButtonMain';
+
+const SessionsCard: React.FC = () => {
+  const [revoked, setRevoked] = useState(0);
+  const { data, loading, error } = useAsync(() => API.GetUserSessions(), [revoked]);
+
+  if (loading || data === undefined) {
+    return <div></div>;
+  }
+
+  const handleRevoke = async (id: string) => {
+    await API.DeleteUserSession(id);
+    setRevoked(revoked + 1);
+  };
+
+  const sessions: Session[] = data.sessions;
+  return (
+    <Card className="dash-card">
+      <Card.Body>
+        <Card.Title>Logged In Devices</Card.Title>
+        <hr></hr>
+        <Table responsive size="sm">
+          <thead>
+            <tr>
+              <th>Device</th>
+              <th>IP</th>
+              <th>Last Used</th>
+              <th></th>
+            </tr>
+          </thead>
+          <tbody>
+            {sessions.map(s => (
+              <tr key={s.id}>
+                <td>
+                  {s.device_name}
+                  {s.current && (
+                    <Badge className="sub-status" variant="info">
+                      This device
+                    </Badge>
+                  )}
+                </td>
+                <td>{s.ip}</td>
+                <td>
+                  {dayjs(s.last_used)
+                    .format('DD-MM-YYYY H:mm')
+                    .toString()}
+                </td>
+                <td>
+                  {!s.current && <ButtonMain value="Log Out" onClick={() => handleRevoke(s.id)} />}
+                </td>
+              </tr>
+            ))}
+          </tbody>
+        </Table>
+      </Card.Body>
+    </Card>
+  );
+};
+
+export default SessionsCard;
@@ -0,0 +1,119 @@
+import React, { useState } from 'react';
+import Card from 'react-bootstrap/Card';
+import Form from 'react-bootstrap/Form';
+import Badge from 'react-bootstrap/Badge';
+import useAsync from '../hooks/useAsync';
+import API from '../service/APIService';
+import ButtonMain from './ButtonMain';
+import FormInput from './FormInput';
+import ErrorMessage from './ErrorMessage';
+
+const TwoFactorCard: React.FC = () => {
+  const [changed, setChanged] = useState(0);
+  const { data, loading } = useAsync(() => API.GetTwoFactor(), [changed]);
+  const [setup, setSetup] = useState();
+  const [code, setCode] = useState('');
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+  const [error, setError] = useState();
+
+  if (loading || data === undefined) {
+    return <div></div>;
+  }
+
+  const handleResponse = (res: any) => {
+    setCode('');
+    if (res.status != 200) {
+      setError(res);
+      return false;
+    }
+    setError(undefined);
+    return true;
+  };
+
+  const handleSetup = async () => {
+    const res = await API.SetupTwoFactor();
+    if (handleResponse(res)) {
+      setSetup(res.data);
+    }
+  };
+
+  const handleEnable = async () => {
+    const res = await API.EnableTwoFactor(JSON.stringify({ code }));
+    if (handleResponse(res)) {
+      setSetup(undefined);
+      setRecoveryCodes(res.data.recovery_codes);
+      setChanged(changed + 1);
+    }
+  };
+
+  const handleRegenerate = async () => {
+    const res = await API.RegenerateRecoveryCodes(JSON.stringify({ code }));
+    if (handleResponse(res)) {
+      setRecoveryCodes(res.data.recovery_codes);
+      setChanged(changed + 1);
+    }
+  };
+
+  const handleDisable = async () => {
+    const res = await API.DisableTwoFactor(JSON.stringify({ code }));
+    if (handleResponse(res)) {
+      setRecoveryCodes([]);
+      setChanged(changed + 1);
+    }
+  };
+
+  return (
+    <Card className="dash-card">
+      <Card.Body>
+        <Card.Title>
+          Two Factor Authentication
+          <Badge className="sub-status" variant={data.enabled ? 'success' : 'secondary'}>
+            {data.enabled ? 'Enabled' : 'Disabled'}
+          </Badge>
+        </Card.Title>
+        <hr></hr>
+        <ErrorMessage show={!!error} error={error} />
+        {recoveryCodes.length > 0 && (
+          <div className="sub-card">
+            <p>
+              Store these recovery codes somewhere safe. Each can be used once to log in if you lose
+              your authenticator.
+            </p>
+            <pre>{recoveryCodes.join('\n')}</pre>
+          </div>
+        )}
+        {!data.enabled && !setup && (
+          <div>
+            {data.required && <p>Two factor authentication is required for your account.</p>}
+            <ButtonMain value="Set Up" onClick={handleSetup} />
+          </div>
+        )}
+        {!data.enabled && setup && (
+          <Form>
+            <p>
+              Scan the QR code for <a href={setup.provisioning_uri}>this link</a> with your
+              authenticator app, or enter the key <code>{setup.secret}</code>, then enter the code it
+              shows.
+            </p>
+            <Form.Row>
+              <FormInput name="code" label="Authentication Code" value={code} onChange={setCode} />
+            </Form.Row>
+            <ButtonMain value="Enable" onClick={handleEnable} />
+          </Form>
+        )}
+        {data.enabled && (
+          <Form>
+            <p>{data.recovery_codes_remaining} recovery codes remaining.</p>
+            <Form.Row>
+              <FormInput name="code" label="Authentication Code" value={code} onChange={setCode} />
+            </Form.Row>
+            <ButtonMain value="New Recovery Codes" onClick={handleRegenerate} />
+            {!data.required && <ButtonMain value="Disable" onClick={handleDisable} />}
+          </Form>
+        )}
+      </Card.Body>
+    </Card>
+  );
+};
+
+export default TwoFactorCard;
@@ -0,0 +1,61 @@
+import React from 'react';
+import Row from 'react-bootstrap/Row';
+import Col from 'react-bootstrap/Col';
+import Card from 'react-bootstrap/Card';
+import dayjs from 'dayjs';
+import useAsync from '../hooks/useAsync';
+import API from '../service/APIService';
+import formatBytes from '../util/formatBytes';
+
+interface UsageCardProps {
+  userid: string;
+}
+
+const UsageCard: React.FC<UsageCardProps> = ({ userid }) => {
+  const { data, loading, error } = useAsync(() => API.GetUserUsage(userid));
+
+  if (loading || data === undefined) {
+    return <div></div>;
+  }
+
+  return (
+    <Card className="dash-card">
+      <Card.Body>
+        <Card.Title>Data Usage</Card.Title>
+        <hr></hr>
+        <div className="sub-card">
+          <Row>
+            <Col>
+              <label htmlFor="period">Billing Period</label>
+              <div id="period">
+                {dayjs(data.period_start).format('DD-MM-YYYY')} - {dayjs(data.period_end).format('DD-MM-YYYY')}
+              </div>
+            </Col>
+          </Row>
+          <Row>
+            <Col>
+              <label htmlFor="bytes_up">Uploaded</label>
+              <div id="bytes_up">{formatBytes(data.bytes_up)}</div>
+            </Col>
+            <Col>
+              <label htmlFor="bytes_down">Downloaded</label>
+              <div id="bytes_down">{formatBytes(data.bytes_down)}</div>
+            </Col>
+          </Row>
+          {data.data_allowance > 0 && (
+            <Row>
+              <Col>
+                <label htmlFor="data_remaining">Remaining</label>
+                <div id="data_remaining">
+                  {formatBytes(Math.max(data.data_remaining, 0))} of {formatBytes(data.data_allowance)}
+                </div>
+              </Col>
+            </Row>
+          )}
+        </div>
+      </Card.Body>
+    </Card>
+  );
+};
+
+export default UsageCard;
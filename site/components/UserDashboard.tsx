@@ -6,6 +6,10 @@ import Row from 'react-bootstrap/Row';
 import Col from 'react-bootstrap/Col';
 import UserDetailsCard from './UserDetailsCard';
 import SubscriptionCard from './SubscriptionCard';
+import UsageCard from './UsageCard';
+import SessionsCard from './SessionsCard';
+import TwoFactorCard from './TwoFactorCard';
+import APITokensCard from './APITokensCard';
 
 interface UserDashboardProps {
   userid: string;
@@ -28,9 +32,13 @@ const UserDashboard: React.FC<UserDashboardProps> = ({ userid }) => {
       <Row>
         <Col sm={12} md={12} lg={4}>
           <SubscriptionCard userid={userid} />
+          <UsageCard userid={userid} />
         </Col>
         <Col sm={12} md={12} lg={8}>
           <UserDetailsCard userid={userid} />
+          <TwoFactorCard />
+          <SessionsCard />
+          <APITokensCard />
         </Col>
       </Row>
     </Container>
@@ -1,40 +1,38 @@
 import React from 'react';
 import Link from 'next/link';
+import API from '../../service/APIService';
+import useAsync from '../../hooks/useAsync';
+
+const links = [
+  { href: '/admin/users', name: 'Users', permission: 'users:read' },
+  { href: '/admin/userplans', name: 'User Plans', permission: 'userplans:read' },
+  { href: '/admin/plans', name: 'Plans', permission: 'plans:read' },
+  { href: '/admin/servers', name: 'Servers', permission: 'servers:read' },
+  { href: '/admin/connections', name: 'Connections', permission: 'servers:read' },
+  { href: '/admin/lockouts', name: 'Lockouts', permission: 'lockouts:read' },
+  { href: '/admin/audit', name: 'Audit Log', permission: 'audit:read' },
+  { href: '/admin/settings', name: 'Settings', permission: 'settings:read' }
+];
 
 export default function AdminSidePanel(): JSX.Element {
+  const { data } = useAsync(() => API.GetPermissions());
+
+  // show every link until the permissions load, the API refuses
+  // anything the admin is not allowed to do regardless
+  const allowed = (permission: string) => !data || data.permissions.includes(permission);
+
   return (
     <div className="admin-side-panel">
       <ul>
-        <li>
-          <Link href="/admin/users">
-            <a>Users</a>
-          </Link>
-        </li>
-        <li>
-          <Link href="/admin/userplans">
-            <a>User Plans</a>
-          </Link>
-        </li>
-        <li>
-          <Link href="/admin/plans">
-            <a>Plans</a>
-          </Link>
-        </li>
-        <li>
-          <Link href="/admin/servers">
-            <a>Servers</a>
-          </Link>
-        </li>
-        <li>
-          <Link href="/admin/connections">
-            <a>Connections</a>
-          </Link>
-        </li>
-        <li>
-          <Link href="/admin/settings">
-            <a>Settings</a>
-          </Link>
-        </li>
+        {links
+          .filter(link => allowed(link.permission))
+          .map(link => (
+            <li key={link.href}>
+              <Link href={link.href}>
+                <a>{link.name}</a>
+              </Link>
+            </li>
+          ))}
         <style jsx>{`
           ul {
             list-style-type: none;
@@ -6,6 +6,7 @@ import FormDropdown from '../../FormDropdown';
 import ButtonMain from '../../ButtonMain';
 import ErrorMessage from '../../ErrorMessage';
 import APIError from '../../../interfaces/error';
+import { GB } from '../../../util/formatBytes';
 
 interface PlanCreateFormProps {
   error: APIError;
@@ -20,6 +21,8 @@ const UserCreateForm: React.FC<PlanCreateFormProps> = ({ error, HandleSave }) =>
   const [interval, setInterval] = useState('');
   const [intervalCountString, setIntervalCount] = useState('');
   const [plan_type, setPlanType] = useState('');
+  const [dataAllowanceString, setDataAllowance] = useState('0');
+  const [maxDevicesString, setMaxDevices] = useState('0');
 
   const hasError = !!error;
 
@@ -27,7 +30,20 @@ const UserCreateForm: React.FC<PlanCreateFormProps> = ({ error, HandleSave }) =>
     const amount = parseInt(amountString);
     const interval_count = parseInt(intervalCountString);
     const currency = 'EUR';
-    HandleSave(JSON.stringify({ name, amount, interval, interval_count, plan_type, currency }));
+    const data_allowance = Math.round(parseFloat(dataAllowanceString || '0') * GB);
+    const max_devices = parseInt(maxDevicesString) || 0;
+    HandleSave(
+      JSON.stringify({
+        name,
+        amount,
+        interval,
+        interval_count,
+        plan_type,
+        currency,
+        data_allowance,
+        max_devices
+      })
+    );
   };
 
   return (
@@ -63,6 +79,18 @@ const UserCreateForm: React.FC<PlanCreateFormProps> = ({ error, HandleSave }) =>
                 options={['PAYG', 'SUB', 'FREE']}
                 onChange={setPlanType}
               />
+              <FormInput
+                name="dataAllowance"
+                label="Data Allowance GB (0 for unlimited)"
+                value={dataAllowanceString}
+                onChange={setDataAllowance}
+              />
+              <FormInput
+                name="maxDevices"
+                label="Max Devices (0 for unlimited)"
+                value={maxDevicesString}
+                onChange={setMaxDevices}
+              />
             </Form.Row>
           </Form>
         </Card.Body>
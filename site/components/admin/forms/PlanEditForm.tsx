@@ -8,6 +8,7 @@ import FormInput from '../../FormInput';
 import SuccessMessage from '../../SuccessMessage';
 import ErrorMessage from '../../ErrorMessage';
 import ButtonMain from '../../ButtonMain';
+import { GB } from '../../../util/formatBytes';
 
 interface PlanEditFormProps {
   plan: Plan;
@@ -26,9 +27,13 @@ const UserForm: React.FC<PlanEditFormProps> = ({
 }) => {
   const hasError = !!error;
   const [name, setName] = useState(plan.name);
+  const [dataAllowanceString, setDataAllowance] = useState((plan.data_allowance / GB).toString());
+  const [maxDevicesString, setMaxDevices] = useState((plan.max_devices || 0).toString());
 
   const handleSaveClick = () => {
-    HandleSave(JSON.stringify({ name }));
+    const data_allowance = Math.round(parseFloat(dataAllowanceString || '0') * GB);
+    const max_devices = parseInt(maxDevicesString) || 0;
+    HandleSave(JSON.stringify({ name, data_allowance, max_devices }));
   };
 
   const handleDeleteClick = () => {
@@ -85,6 +90,20 @@ const UserForm: React.FC<PlanEditFormProps> = ({
               <FormInput textOnly name="plan_type" label="Plan Type" value={plan.plan_type} />
               <FormInput textOnly name="currency" label="Currency" value={plan.currency} />
             </Form.Row>
+            <Form.Row>
+              <FormInput
+                name="data_allowance"
+                label="Data Allowance GB (0 for unlimited)"
+                value={dataAllowanceString}
+                onChange={setDataAllowance}
+              />
+              <FormInput
+                name="max_devices"
+                label="Max Devices (0 for unlimited)"
+                value={maxDevicesString}
+                onChange={setMaxDevices}
+              />
+            </Form.Row>
             <Form.Row>
               <FormInput
                 textOnly
@@ -20,8 +20,10 @@ const ServerCreateForm: React.FC<ServerCreateFormProps> = ({ HandleSave, error }
   const [countryCode, setCountryCode] = useState('');
   const [ip, setIp] = useState('');
   const [port, setPort] = useState('');
+  const [socksPort, setSocksPort] = useState('');
   const [username, setUsername] = useState('');
   const [password, setPassword] = useState('');
+  const [maxUsers, setMaxUsers] = useState('');
 
   const hasError = !!error;
   const handleSubmit = (event: TFormEvent) => {
@@ -61,10 +63,22 @@ const ServerCreateForm: React.FC<ServerCreateFormProps> = ({ HandleSave, error }
             <Form.Row>
               <FormInput name="ip" label="IP" value={ip} onChange={setIp} />
               <FormInput name="port" label="Port" value={port} onChange={setPort} />
+              <FormInput
+                name="socks_port"
+                label="SOCKS Port"
+                value={socksPort}
+                onChange={setSocksPort}
+              />
             </Form.Row>
             <Form.Row>
               <FormInput name="username" label="Username" value={username} onChange={setUsername} />
               <FormInput name="password" label="Password" value={password} onChange={setPassword} />
+              <FormInput
+                name="max_users"
+                label="Max Users (0 for unlimited)"
+                value={maxUsers}
+                onChange={setMaxUsers}
+              />
             </Form.Row>
             <Form.Row>
               <Form.Group as={Row} controlId="img">
@@ -29,12 +29,30 @@ const ServerEditForm: React.FC<ServerEditFormProps> = ({
   const hasError = !!error;
   const [ip, setIp] = useState(server.ip);
   const [portString, setPortString] = useState(server.port.toString());
+  const [socksPortString, setSocksPortString] = useState((server.socks_port || '').toString());
   const [username, setUsername] = useState(server.username);
   const [password, setPassword] = useState(server.password);
+  const [apiPortString, setApiPortString] = useState((server.api_port || '').toString());
+  const [apiSecret, setApiSecret] = useState(server.api_secret);
+  const [maxUsersString, setMaxUsersString] = useState((server.max_users || 0).toString());
 
   const handleSaveClick = () => {
     const port = parseInt(portString);
-    HandleSave(JSON.stringify({ ip, port, username, password }));
+    const socks_port = parseInt(socksPortString) || 0;
+    const api_port = parseInt(apiPortString) || 0;
+    const max_users = parseInt(maxUsersString) || 0;
+    HandleSave(
+      JSON.stringify({
+        ip,
+        port,
+        socks_port,
+        username,
+        password,
+        api_port,
+        api_secret: apiSecret,
+        max_users
+      })
+    );
   };
 
   const handleDeleteClick = () => {
@@ -90,10 +108,36 @@ const ServerEditForm: React.FC<ServerEditFormProps> = ({
                 value={portString.toString()}
                 onChange={setPortString}
               />
+              <FormInput
+                name="socks_port"
+                label="SOCKS Port"
+                value={socksPortString}
+                onChange={setSocksPortString}
+              />
             </Form.Row>
             <Form.Row>
               <FormInput name="svruser" label="Username" value={username} onChange={setUsername} />
               <FormInput name="svrpass" label="Password" value={password} onChange={setPassword} />
+              <FormInput
+                name="max_users"
+                label="Max Users (0 for unlimited)"
+                value={maxUsersString}
+                onChange={setMaxUsersString}
+              />
+            </Form.Row>
+            <Form.Row>
+              <FormInput
+                name="api_port"
+                label="API Port"
+                value={apiPortString}
+                onChange={setApiPortString}
+              />
+              <FormInput
+                name="api_secret"
+                label="API Secret"
+                value={apiSecret}
+                onChange={setApiSecret}
+              />
             </Form.Row>
             <Form.Row>
               <Form.Group as={Col} controlId="image_path">
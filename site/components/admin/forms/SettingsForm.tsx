@@ -22,6 +22,9 @@ const SettingsForm: React.FC<SettingsFormProps> = ({ settings, error, success, H
   const [enableSubscriptions, setEnableSubs] = useState(settings.enableSubscriptions);
   const [enableAuth, setEnableAuth] = useState(settings.enableAuth);
   const [enableStripe, setEnableStripe] = useState(settings.enableStripe);
+  const [requireAdminTwoFactor, setRequireAdminTwoFactor] = useState(
+    settings.requireAdminTwoFactor
+  );
   const [allowedOrigins, setAllowedOrigins] = useState(settings.allowedOrigins.join(',\n'));
 
   const handleSaveClick = () => {
@@ -31,6 +34,7 @@ const SettingsForm: React.FC<SettingsFormProps> = ({ settings, error, success, H
         enableSubscriptions,
         enableAuth,
         enableStripe,
+        requireAdminTwoFactor,
         allowedOrigins: allowedOrigins.replace(/\r?\n|\r/g, '').split(',')
       })
     );
@@ -81,6 +85,15 @@ const SettingsForm: React.FC<SettingsFormProps> = ({ settings, error, success, H
                 onChange={setEnableStripe}
               />
             </Form.Row>
+            <Form.Row>
+              <FormDropdown
+                name="requireAdminTwoFactor"
+                label="Require Admin Two Factor"
+                value={requireAdminTwoFactor}
+                options={['true', 'false']}
+                onChange={setRequireAdminTwoFactor}
+              />
+            </Form.Row>
             <Form.Row>
               <FormInput
                 textarea
@@ -8,6 +8,7 @@ import ErrorMessage from '../../ErrorMessage';
 import APIError from '../../../interfaces/error';
 import dayjs from 'dayjs';
 import FormInput from '../../FormInput';
+import FormDropdown from '../../FormDropdown';
 import SuccessMessage from '../../SuccessMessage';
 import Router from 'next/router';
 
@@ -17,6 +18,7 @@ interface UserEditFormProps {
   success: boolean;
   showCreateUserPlan: boolean;
   HandleSave: (body: string) => Promise<void>;
+  HandleRoleSave: (body: string) => Promise<void>;
   HandleDelete: () => Promise<void>;
 }
 
@@ -26,17 +28,26 @@ const UserForm: React.FC<UserEditFormProps> = ({
   success,
   showCreateUserPlan,
   HandleSave,
+  HandleRoleSave,
   HandleDelete
 }) => {
   const hasError = !!error;
   const [firstname, setFirstname] = useState(user.firstname);
   const [lastname, setLastname] = useState(user.lastname);
   const [email, setEmail] = useState(user.email);
+  // admins without a role predate roles and keep full access
+  const [role, setRole] = useState(
+    user.type === 'admin' && !user.role ? 'superadmin' : user.role
+  );
 
   const handleSaveClick = () => {
     HandleSave(JSON.stringify({ firstname, lastname, email }));
   };
 
+  const handleRoleSaveClick = () => {
+    HandleRoleSave(JSON.stringify({ role }));
+  };
+
   const handleDeleteClick = () => {
     HandleDelete();
   };
@@ -103,6 +114,20 @@ const UserForm: React.FC<UserEditFormProps> = ({
               <FormInput name="lastname" label="Lastname" value={lastname} onChange={setLastname} />
             </Form.Row>
             <Form.Row>
+              <FormDropdown
+                name="role"
+                label="Admin Role"
+                value={role}
+                optionsKV={[
+                  { value: 'superadmin', name: 'Super Admin' },
+                  { value: 'support', name: 'Support' },
+                  { value: 'billing', name: 'Billing' }
+                ]}
+                onChange={setRole}
+              />
+            </Form.Row>
+            <Form.Row>
+              <ButtonMain onClick={handleRoleSaveClick} value="Save Role" />
               {showCreateUserPlan ? (
                 <ButtonMain onClick={goToCreateUserPlan} value="Create User Plan" />
               ) : (
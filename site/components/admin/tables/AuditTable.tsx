@@ -0,0 +1,64 @@
+import React from 'react';
+import Table from 'react-bootstrap/Table';
+import AuditLog from '../../../interfaces/auditlog';
+import dayjs from 'dayjs';
+
+interface AuditTableProps {
+  auditLogs: AuditLog[];
+  show: boolean;
+}
+
+const AuditTable: React.FC<AuditTableProps> = ({ auditLogs, show }) => {
+  if (!show) {
+    return <div />;
+  }
+  const formatChanges = (changes: AuditLog['changes']) =>
+    Object.keys(changes || {})
+      .map(field => {
+        const { before, after } = changes[field];
+        return `${field}: ${JSON.stringify(before)} → ${JSON.stringify(after)}`;
+      })
+      .join('\n');
+  return (
+    <Table striped bordered hover responsive size="sm">
+      <thead>
+        <tr>
+          <th>#</th>
+          <th>Time</th>
+          <th>Actor</th>
+          <th>Action</th>
+          <th>Target</th>
+          <th>Changes</th>
+          <th>IP</th>
+        </tr>
+      </thead>
+      <tbody className="table-admin-list">
+        {auditLogs.map(a => (
+          <tr key={a.id}>
+            <td>{a.id}</td>
+            <td>
+              {dayjs(a.createdAt)
+                .format('DD-MM-YYYY H:mm:ss')
+                .toString()}
+            </td>
+            <td>{a.actor_id || ''}</td>
+            <td>{a.action}</td>
+            <td>
+              {a.target_type} {a.target_id || ''}
+            </td>
+            <td className="changes">{formatChanges(a.changes)}</td>
+            <td>{a.ip}</td>
+          </tr>
+        ))}
+      </tbody>
+      <style jsx>{`
+        .changes {
+          white-space: pre-wrap;
+          font-family: monospace;
+        }
+      `}</style>
+    </Table>
+  );
+};
+
+export default AuditTable;
@@ -3,6 +3,7 @@ import Table from 'react-bootstrap/Table';
 import Connection from '../../../interfaces/connection';
 import dayjs from 'dayjs';
 import Router from 'next/router';
+import formatBytes from '../../../util/formatBytes';
 
 interface ConnTableProps {
   connections: Connection[];
@@ -22,6 +23,8 @@ const ConnectionsTable: React.FC<ConnTableProps> = ({ connections, show }) => {
           <th>User ID</th>
           <th>Server ID</th>
           <th>Country</th>
+          <th>Data Up</th>
+          <th>Data Down</th>
         </tr>
       </thead>
       <tbody className="table-admin-list">
@@ -36,6 +39,8 @@ const ConnectionsTable: React.FC<ConnTableProps> = ({ connections, show }) => {
             <td>{c.user_id}</td>
             <td>{c.server_id}</td>
             <td>{c.server_country}</td>
+            <td>{formatBytes(c.bytes_up)}</td>
+            <td>{formatBytes(c.bytes_down)}</td>
           </tr>
         ))}
       </tbody>
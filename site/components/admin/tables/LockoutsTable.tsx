@@ -0,0 +1,57 @@
+import React from 'react';
+import Table from 'react-bootstrap/Table';
+import Lockout from '../../../interfaces/lockout';
+import dayjs from 'dayjs';
+import ButtonMain from '../../ButtonMain';
+
+interface LockoutsTableProps {
+  lockouts: Lockout[];
+  show: boolean;
+  HandleClear: (id: string) => Promise<void>;
+}
+
+const LockoutsTable: React.FC<LockoutsTableProps> = ({ lockouts, show, HandleClear }) => {
+  if (!show) {
+    return <div />;
+  }
+  const formatDate = (date: string | null) =>
+    date
+      ? dayjs(date)
+          .format('DD-MM-YYYY H:mm')
+          .toString()
+      : '';
+  return (
+    <Table striped bordered hover responsive size="sm">
+      <thead>
+        <tr>
+          <th>#</th>
+          <th>Scope</th>
+          <th>Type</th>
+          <th>Account / IP</th>
+          <th>Failures</th>
+          <th>Last Failure</th>
+          <th>Locked Until</th>
+          <th></th>
+        </tr>
+      </thead>
+      <tbody className="table-admin-list">
+        {lockouts.map(l => (
+          <tr key={l.id}>
+            <td>{l.id}</td>
+            <td>{l.scope}</td>
+            <td>{l.kind}</td>
+            <td>{l.key}</td>
+            <td>{l.failures}</td>
+            <td>{formatDate(l.last_failure)}</td>
+            <td>{formatDate(l.locked_until)}</td>
+            <td>
+              <ButtonMain value="Clear" onClick={() => HandleClear(l.id)} />
+            </td>
+          </tr>
+        ))}
+      </tbody>
+    </Table>
+  );
+};
+
+export default LockoutsTable;
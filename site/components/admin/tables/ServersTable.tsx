@@ -3,6 +3,8 @@ import Table from 'react-bootstrap/Table';
 import Server from '../../../interfaces/server';
 import dayjs from 'dayjs';
 import Router from 'next/router';
+import Badge from 'react-bootstrap/Badge';
+import formatBytes from '../../../util/formatBytes';
 
 interface ServersTableProps {
   servers: Server[];
@@ -26,6 +28,12 @@ const ServersTable: React.FC<ServersTableProps> = ({ servers, show }) => {
           <th>Port</th>
           <th>Username</th>
           <th>Password</th>
+          <th>Health</th>
+          <th>Latency</th>
+          <th>Last Seen</th>
+          <th>Connections</th>
+          <th>Throughput</th>
+          <th>CPU</th>
         </tr>
       </thead>
       <tbody className="table-admin-list">
@@ -47,6 +55,25 @@ const ServersTable: React.FC<ServersTableProps> = ({ servers, show }) => {
             <td>{server.port}</td>
             <td>{server.username}</td>
             <td>{server.password}</td>
+            <td>
+              {server.healthy ? (
+                <Badge variant="success">Healthy</Badge>
+              ) : (
+                <Badge variant="danger">Unhealthy</Badge>
+              )}
+            </td>
+            <td>{server.latency} ms</td>
+            <td>
+              {dayjs(server.last_seen)
+                .format('DD-MM-YYYY H:mm')
+                .toString()}
+            </td>
+            <td>
+              {server.connections}
+              {server.max_users > 0 && ` / ${server.max_users}`}
+            </td>
+            <td>{formatBytes(server.throughput)}/s</td>
+            <td>{Math.round(server.cpu_load * 100)}%</td>
           </tr>
         ))}
       </tbody>
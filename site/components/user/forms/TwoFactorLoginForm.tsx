@@ -0,0 +1,71 @@
+import React, { useState } from 'react';
+import Form from 'react-bootstrap/Form';
+import Card from 'react-bootstrap/Card';
+import ErrorMessage from '../../ErrorMessage';
+import APIError from '../../../interfaces/error';
+import ButtonMain from '../../ButtonMain';
+import FormInput from '../../FormInput';
+
+interface TwoFactorLoginFormProps {
+  token: string;
+  error: APIError;
+  HandleLogin: (body: string) => Promise<void>;
+}
+
+type TFormEvent = React.FormEvent<HTMLFormElement>;
+
+const TwoFactorLoginForm: React.FC<TwoFactorLoginFormProps> = ({ token, error, HandleLogin }) => {
+  const [code, setCode] = useState('');
+  const [useRecovery, setUseRecovery] = useState(false);
+  const [validated, setValidated] = useState(false);
+
+  const handleSubmit = (event: TFormEvent) => {
+    event.stopPropagation();
+    event.preventDefault();
+    const form = event.currentTarget;
+    if (form.checkValidity() === true) {
+      const body = useRecovery ? { token, recovery_code: code } : { token, code };
+      HandleLogin(JSON.stringify(body));
+    }
+    // this sets form validation feedback to visible
+    setValidated(true);
+  };
+
+  const toggleRecovery = () => {
+    setCode('');
+    setUseRecovery(!useRecovery);
+  };
+
+  return (
+    <Card style={{ padding: 20 }}>
+      <Card.Body>
+        <Card.Title className="card-title-form">
+          <h2 className="center">Two Factor Authentication</h2>
+        </Card.Title>
+        <Form noValidate validated={validated} onSubmit={(e: TFormEvent) => handleSubmit(e)}>
+          <Form.Row>
+            <FormInput
+              required
+              name="code"
+              label={useRecovery ? 'Recovery Code' : 'Authentication Code'}
+              value={code}
+              onChange={setCode}
+              feebackType="invalid"
+              feebackValue="Required"
+            />
+          </Form.Row>
+          <ErrorMessage show={!!error} error={error} />
+          <ButtonMain className="w-100" type="submit" value="Verify" />
+        </Form>
+
+        <div className="login-links-cont">
+          <a className="forgot-pass-link" href="#" onClick={toggleRecovery}>
+            {useRecovery ? 'Use authentication code' : 'Use a recovery code'}
+          </a>
+        </div>
+      </Card.Body>
+    </Card>
+  );
+};
+
+export default TwoFactorLoginForm;
@@ -0,0 +1,12 @@
+export default interface APIToken {
+  id: string;
+  createdAt: string;
+  updatedAt: string;
+  user_id: number;
+  name: string;
+  prefix: string;
+  scopes: string;
+  last_used: string | null;
+  last_used_ip: string;
+  expires_at: string | null;
+}
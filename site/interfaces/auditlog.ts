@@ -0,0 +1,11 @@
+export default interface AuditLog {
+  id: string;
+  createdAt: string;
+  updatedAt: string;
+  actor_id: number;
+  action: string;
+  target_type: string;
+  target_id: number;
+  changes: { [field: string]: { before: any; after: any } };
+  ip: string;
+}
@@ -5,4 +5,6 @@ export default interface Connection {
   server_id: number;
   user_id: number;
   server_country: string;
+  bytes_up: number;
+  bytes_down: number;
 }
@@ -3,4 +3,5 @@ export default interface APIError {
   code: string;
   title: string;
   detail: string;
+  request_id?: string;
 }
@@ -0,0 +1,12 @@
+export default interface Lockout {
+  id: string;
+  createdAt: string;
+  updatedAt: string;
+  scope: string;
+  kind: string;
+  key: string;
+  user_id: number;
+  failures: number;
+  last_failure: string;
+  locked_until: string | null;
+}
@@ -8,6 +8,8 @@ export default interface Plan {
   interval_count: number;
   plan_type: string;
   currency: string;
+  data_allowance: number;
+  max_devices: number;
   stripe_plan_id: string;
   stripe_product_id: string;
 }
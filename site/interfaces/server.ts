@@ -7,7 +7,20 @@ export default interface Server {
   type: string;
   ip: string;
   port: number;
+  socks_port: number;
   username: string;
   password: string;
   image_path: string;
+  api_port: number;
+  api_secret: string;
+  healthy: boolean;
+  latency: number;
+  last_seen: string;
+  last_checked: string;
+  failed_checks: number;
+  max_users: number;
+  connections: number;
+  throughput: number;
+  cpu_load: number;
+  load_reported: string;
 }
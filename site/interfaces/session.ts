@@ -0,0 +1,11 @@
+export default interface Session {
+  id: string;
+  createdAt: string;
+  updatedAt: string;
+  user_id: number;
+  device_name: string;
+  user_agent: string;
+  ip: string;
+  last_used: string;
+  current: boolean;
+}
@@ -3,6 +3,7 @@ export default interface Settings {
   enableSubscriptions: string;
   enableAuth: string;
   enableStripe: string;
+  requireAdminTwoFactor: string;
   authCookieAge: number;
   authCookieName: string;
   authTokenExpiry: number;
@@ -7,5 +7,6 @@ export default interface User {
   email: string;
   stripe_customer_id: string;
   type: string;
+  role: string;
   email_confirmed: boolean;
 }
@@ -9,4 +9,6 @@ export default interface UserPlan {
   active: boolean;
   start_date: string;
   expiry_date: string;
+  data_allowance: number;
+  data_remaining: number;
 }
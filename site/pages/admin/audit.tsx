@@ -0,0 +1,51 @@
+import React, { useState } from 'react';
+import { LayoutAdminDash } from '../../components/Layout';
+import AdminSidePanel from '../../components/admin/AdminSidePanel';
+import AuditTable from '../../components/admin/tables/AuditTable';
+import ErrorMessage from '../../components/ErrorMessage';
+import FormInput from '../../components/FormInput';
+import Pagination from '../../components/Pagination';
+import API from '../../service/APIService';
+import useAsync from '../../hooks/useAsync';
+import Form from 'react-bootstrap/Form';
+
+export default function Audit(): JSX.Element {
+  const [offset, setOffset] = useState(0);
+  const [actorID, setActorID] = useState('');
+  const [action, setAction] = useState('');
+  const [targetType, setTargetType] = useState('');
+  const { data, loading, error } = useAsync(
+    () => API.GetAuditLogs(offset, { actor_id: actorID, action, target_type: targetType }),
+    [offset, actorID, action, targetType]
+  );
+  const hasError = !!error;
+  const pageLimit = 20;
+
+  const handlePagination = (page_number: number) => {
+    setOffset((page_number - 1) * pageLimit);
+  };
+
+  if (loading) {
+    return <div></div>;
+  }
+
+  return (
+    <LayoutAdminDash AdminSidePanel={<AdminSidePanel />}>
+      <Form>
+        <Form.Row>
+          <FormInput name="actor_id" label="Actor ID" value={actorID} onChange={setActorID} />
+          <FormInput name="action" label="Action" value={action} onChange={setAction} />
+          <FormInput
+            name="target_type"
+            label="Target Type"
+            value={targetType}
+            onChange={setTargetType}
+          />
+        </Form.Row>
+      </Form>
+      <AuditTable show={!hasError} auditLogs={data?.audit_logs} />
+      <Pagination count={data?.count} handlePagination={handlePagination} pageLimit={pageLimit} />
+      <ErrorMessage show={hasError} error={error} />
+    </LayoutAdminDash>
+  );
+}
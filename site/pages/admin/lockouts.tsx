@@ -0,0 +1,37 @@
+import React, { useState } from 'react';
+import { LayoutAdminDash } from '../../components/Layout';
+import AdminSidePanel from '../../components/admin/AdminSidePanel';
+import LockoutsTable from '../../components/admin/tables/LockoutsTable';
+import ErrorMessage from '../../components/ErrorMessage';
+import Pagination from '../../components/Pagination';
+import API from '../../service/APIService';
+import useAsync from '../../hooks/useAsync';
+
+export default function Lockouts(): JSX.Element {
+  const [offset, setOffset] = useState(0);
+  const [cleared, setCleared] = useState(0);
+  const { data, loading, error } = useAsync(() => API.GetLockoutsList(offset), [offset, cleared]);
+  const hasError = !!error;
+  const pageLimit = 20;
+
+  const handlePagination = (page_number: number) => {
+    setOffset((page_number - 1) * pageLimit);
+  };
+
+  const handleClear = async (id: string) => {
+    await API.ClearLockout(id);
+    setCleared(cleared + 1);
+  };
+
+  if (loading) {
+    return <div></div>;
+  }
+
+  return (
+    <LayoutAdminDash AdminSidePanel={<AdminSidePanel />}>
+      <LockoutsTable show={!hasError} lockouts={data?.lockouts} HandleClear={handleClear} />
+      <Pagination count={data?.count} handlePagination={handlePagination} pageLimit={pageLimit} />
+      <ErrorMessage show={hasError} error={error} />
+    </LayoutAdminDash>
+  );
+}
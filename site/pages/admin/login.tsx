@@ -2,13 +2,26 @@ import React, { useState } from 'react';
 import { LayoutLogin } from '../../components/Layout';
 import LoginForm from '../../components/admin/forms/LoginForm';
 import Router from 'next/router';
+import TwoFactorLoginForm from '../../components/user/forms/TwoFactorLoginForm';
 import API from '../../service/APIService';
 
 export default function LoginPage(): JSX.Element {
   const [error, setError] = useState();
+  const [twoFactorToken, setTwoFactorToken] = useState('');
 
   async function HandleLogin(body: string) {
     const res = await API.Login(body);
+    if (res.status == 200 && res.data && res.data.two_factor_required) {
+      setTwoFactorToken(res.data.two_factor_token);
+    } else if (res.status == 200) {
+      Router.push('/admin/users');
+    } else {
+      setError(res);
+    }
+  }
+
+  async function HandleTwoFactor(body: string) {
+    const res = await API.LoginTwoFactor(body);
     if (res.status == 200) {
       Router.push('/admin/users');
     } else {
@@ -19,7 +32,11 @@ export default function LoginPage(): JSX.Element {
   return (
     <LayoutLogin>
       <div className="login-cont">
-        <LoginForm error={error} HandleLogin={HandleLogin} />
+        {twoFactorToken ? (
+          <TwoFactorLoginForm token={twoFactorToken} error={error} HandleLogin={HandleTwoFactor} />
+        ) : (
+          <LoginForm error={error} HandleLogin={HandleLogin} />
+        )}
       </div>
     </LayoutLogin>
   );
@@ -32,6 +32,17 @@ export default function UserEdit(): JSX.Element {
     }
   }
 
+  async function HandleRoleSave(body: string) {
+    const res = await API.UpdateUserRole(userID, body);
+    if (res.status == 200) {
+      setSuccess(true);
+      setRespError(false);
+    } else {
+      setRespError(res);
+      setSuccess(false);
+    }
+  }
+
   async function HandleDelete() {
     const res = await API.DeleteUser(userID);
     if (res.status == 200) {
@@ -53,6 +64,7 @@ export default function UserEdit(): JSX.Element {
           success={success}
           HandleDelete={HandleDelete}
           HandleSave={HandleSave}
+          HandleRoleSave={HandleRoleSave}
           error={respError}
           user={data.user}
         />
@@ -2,15 +2,28 @@ import React, { useState } from 'react';
 import { LayoutLogin } from '../components/Layout';
 import LoginForm from '../components/admin/forms/LoginForm';
 import { useRouter } from 'next/router';
+import TwoFactorLoginForm from '../components/user/forms/TwoFactorLoginForm';
 import API from '../service/APIService';
 
 export default function LoginPage(): JSX.Element {
   const router = useRouter();
   const signedup = router.query.signedup;
   const [error, setError] = useState();
+  const [twoFactorToken, setTwoFactorToken] = useState('');
 
   async function HandleLogin(body: string) {
     const res = await API.Login(body);
+    if (res.status == 200 && res.data && res.data.two_factor_required) {
+      setTwoFactorToken(res.data.two_factor_token);
+    } else if (res.status == 200) {
+      router.push('/account');
+    } else {
+      setError(res);
+    }
+  }
+
+  async function HandleTwoFactor(body: string) {
+    const res = await API.LoginTwoFactor(body);
     if (res.status == 200) {
       router.push('/account');
     } else {
@@ -21,11 +34,15 @@ export default function LoginPage(): JSX.Element {
   return (
     <LayoutLogin>
       <div className="signup-cont">
-        <LoginForm
-          signedup={signedup == '1' ? true : false}
-          error={error}
-          HandleLogin={HandleLogin}
-        />
+        {twoFactorToken ? (
+          <TwoFactorLoginForm token={twoFactorToken} error={error} HandleLogin={HandleTwoFactor} />
+        ) : (
+          <LoginForm
+            signedup={signedup == '1' ? true : false}
+            error={error}
+            HandleLogin={HandleLogin}
+          />
+        )}
       </div>
     </LayoutLogin>
   );
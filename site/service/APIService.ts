@@ -88,6 +88,10 @@ export default {
     return postRequest(`${process.env.apiDomain}/api/user/login`, body);
   },
 
+  async LoginTwoFactor(body: string) {
+    return postRequest(`${process.env.apiDomain}/api/user/login/2fa`, body);
+  },
+
   async Signup(body: string) {
     return postRequest(`${process.env.apiDomain}/api/user/signup`, body);
   },
@@ -132,6 +136,26 @@ export default {
     return getRequest(`${process.env.apiDomain}/api/private/servers`);
   },
 
+  async GetPermissions() {
+    return getRequest(`${process.env.apiDomain}/api/private/user/permissions`);
+  },
+
+  async GetLockoutsList(offset: number) {
+    return getRequest(`${process.env.apiDomain}/api/protected/lockouts?offset=${offset}`);
+  },
+
+  async ClearLockout(id: string) {
+    return deleteRequest(`${process.env.apiDomain}/api/protected/lockouts/${id}`);
+  },
+
+  async GetAuditLogs(offset: number, filters: { [key: string]: string }) {
+    const query = Object.keys(filters)
+      .filter(key => filters[key])
+      .map(key => `&${key}=${encodeURIComponent(filters[key])}`)
+      .join('');
+    return getRequest(`${process.env.apiDomain}/api/protected/audit?offset=${offset}${query}`);
+  },
+
   async GetConnectionsList(offset: number) {
     return getRequest(`${process.env.apiDomain}/api/protected/server_connections?offset=${offset}`);
   },
@@ -152,6 +176,50 @@ export default {
     return getRequest(`${process.env.apiDomain}/api/private/userplans/${id}`);
   },
 
+  async GetTwoFactor() {
+    return getRequest(`${process.env.apiDomain}/api/private/user/2fa`);
+  },
+
+  async SetupTwoFactor() {
+    return postRequest(`${process.env.apiDomain}/api/private/user/2fa/setup`, '{}');
+  },
+
+  async EnableTwoFactor(body: string) {
+    return postRequest(`${process.env.apiDomain}/api/private/user/2fa/enable`, body);
+  },
+
+  async DisableTwoFactor(body: string) {
+    return postRequest(`${process.env.apiDomain}/api/private/user/2fa/disable`, body);
+  },
+
+  async RegenerateRecoveryCodes(body: string) {
+    return postRequest(`${process.env.apiDomain}/api/private/user/2fa/recovery_codes`, body);
+  },
+
+  async GetAPITokens() {
+    return getRequest(`${process.env.apiDomain}/api/private/user/tokens`);
+  },
+
+  async CreateAPIToken(body: string) {
+    return postRequest(`${process.env.apiDomain}/api/private/user/tokens`, body);
+  },
+
+  async DeleteAPIToken(id: string) {
+    return deleteRequest(`${process.env.apiDomain}/api/private/user/tokens/${id}`);
+  },
+
+  async GetUserSessions() {
+    return getRequest(`${process.env.apiDomain}/api/private/user/sessions`);
+  },
+
+  async DeleteUserSession(id: string) {
+    return deleteRequest(`${process.env.apiDomain}/api/private/user/sessions/${id}`);
+  },
+
+  async GetUserUsage(id: string) {
+    return getRequest(`${process.env.apiDomain}/api/private/user/usage/${id}`);
+  },
+
   async GetPlanByID(id: string) {
     return getRequest(`${process.env.apiDomain}/api/protected/plans/${id}`);
   },
@@ -164,6 +232,10 @@ export default {
     return putRequest(`${process.env.apiDomain}/api/private/user/update/${id}`, body);
   },
 
+  async UpdateUserRole(id: string, body: string) {
+    return putRequest(`${process.env.apiDomain}/api/protected/users/role/${id}`, body);
+  },
+
   async UpdateUserPlan(user_id: string, body: string) {
     return putRequest(`${process.env.apiDomain}/api/protected/userplans/update/${user_id}`, body);
   },
@@ -0,0 +1,11 @@
+export const GB = Math.pow(1024, 3);
+
+const units = ['B', 'KB', 'MB', 'GB', 'TB'];
+
+export default function formatBytes(bytes: number) {
+  if (!bytes) {
+    return '0 B';
+  }
+  const i = Math.min(Math.floor(Math.log(bytes) / Math.log(1024)), units.length - 1);
+  return `${(bytes / Math.pow(1024, i)).toFixed(i == 0 ? 0 : 2)} ${units[i]}`;
+}